@@ -0,0 +1,256 @@
+package accountapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cfthoo/todo-app/api"
+	"github.com/cfthoo/todo-app/pkg/controller"
+)
+
+type errorMessage struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// mfaChallenge is returned by a password login of an account with two-factor authentication
+type mfaChallenge struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+type message struct {
+	Message string `json:"message"`
+}
+
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type timezoneRequest struct {
+	Timezone string `json:"timezone"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// RegisterHandler creates a local account and emails the verification link, taken emails get the same response
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	req := &credentialsRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	if err := controller.Register(r.Context(), req.Email, req.Password); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to register",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusAccepted, &message{Message: "please check your email to verify the account"})
+}
+
+// LoginHandler returns a token pair for a verified email and password
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	req := &credentialsRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	tokens, err := controller.Login(r.Context(), req.Email, req.Password)
+	var challenge *controller.SecondFactorChallenge
+	if errors.As(err, &challenge) {
+		msg := &mfaChallenge{
+			Error:     "mfa_required",
+			Message:   "please send a code of your authenticator to /auth/login/2fa",
+			MFAToken:  challenge.MFAToken,
+			ExpiresIn: challenge.ExpiresIn,
+		}
+		api.StdResponse(w, http.StatusUnauthorized, msg)
+		return
+	}
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to login",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	respondTokens(w, tokens)
+}
+
+// VerifyEmailHandler verifies the email with the token of the link sent by email
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "missing token", http.StatusBadRequest)
+		return
+	}
+
+	if err := controller.VerifyEmail(r.Context(), token); err != nil {
+		http.Error(w, "failed to verify email: "+err.Error(), statusOf(err))
+		return
+	}
+	w.Write([]byte("Email verified!\nYou can now login."))
+}
+
+// ResendVerificationHandler emails the verification link again
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	req := &emailRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	if err := controller.ResendVerification(r.Context(), req.Email); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to send verification email",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusAccepted, &message{Message: "if the email has an unverified account, a new link was sent"})
+}
+
+// ChangePasswordHandler changes the password of the current user, every session has to login again
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	req := &changePasswordRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	userId := controller.UserIDFromContext(r.Context())
+	if err := controller.ChangePassword(r.Context(), userId, req.CurrentPassword, req.NewPassword); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to change password",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// TimezoneHandler changes the timezone the recurring tasks of the user follow
+func TimezoneHandler(w http.ResponseWriter, r *http.Request) {
+	req := &timezoneRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	userId := controller.UserIDFromContext(r.Context())
+	if err := controller.SetTimezone(r.Context(), userId, req.Timezone); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to change timezone",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// ForgotPasswordHandler emails a password reset link
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	req := &emailRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	if err := controller.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to send password reset email",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusAccepted, &message{Message: "if the email has an account, a reset link was sent"})
+}
+
+// ResetPasswordHandler sets a new password with the token sent by email
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	req := &resetPasswordRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	if err := controller.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to reset password",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// respondTokens returns the tokens of a login, browser sessions keep them in cookies the page can not read
+func respondTokens(w http.ResponseWriter, tokens *controller.TokenPair) {
+	if controller.SessionsEnabled() {
+		if err := controller.SetSessionCookies(w, tokens); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to start session",
+			}
+			api.StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		api.StdResponse(w, http.StatusNoContent, nil)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, tokens)
+}
+
+// decode reads the json body into req, it responds 400 and returns false when it can not
+func decode(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "json decode error",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return false
+	}
+	return true
+}
+
+// statusOf maps the account errors of the controller to a http status
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, controller.ErrInvalidEmail), errors.Is(err, controller.ErrInvalidPassword),
+		errors.Is(err, controller.ErrInvalidAccountToken), errors.Is(err, controller.ErrTOTPNotEnabled),
+		errors.Is(err, controller.ErrInvalidTimezone):
+		return http.StatusBadRequest
+	case errors.Is(err, controller.ErrInvalidCredentials), errors.Is(err, controller.ErrInvalidSecondFactor),
+		errors.Is(err, controller.ErrInvalidMFAToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, controller.ErrEmailNotVerified), errors.Is(err, controller.ErrUserDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, controller.ErrTOTPEnabled):
+		return http.StatusConflict
+	case errors.Is(err, controller.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,143 @@
+package accountapi
+
+import (
+	"net/http"
+
+	"github.com/cfthoo/todo-app/api"
+	"github.com/cfthoo/todo-app/pkg/controller"
+)
+
+type codeRequest struct {
+	Code string `json:"code"`
+}
+
+type loginCodeRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type recoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTOTPHandler creates the secret of a new authenticator for the current user
+func EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := controller.EnrollTOTP(r.Context(), controller.UserIDFromContext(r.Context()))
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to enroll authenticator",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusCreated, enrollment)
+}
+
+// ConfirmTOTPHandler enables the enrolled authenticator with a first code and returns the recovery codes
+func ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	req := &codeRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	codes, err := controller.ConfirmTOTP(r.Context(), controller.UserIDFromContext(r.Context()), req.Code)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to confirm authenticator",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, &recoveryCodes{RecoveryCodes: codes})
+}
+
+// DisableTOTPHandler removes the authenticator and the recovery codes of the current user
+func DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	if err := controller.DisableTOTP(r.Context(), controller.UserIDFromContext(r.Context())); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to disable two-factor authentication",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// RecoveryCodesHandler replaces the recovery codes of the current user
+func RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	codes, err := controller.RegenerateRecoveryCodes(r.Context(), controller.UserIDFromContext(r.Context()))
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to create recovery codes",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, &recoveryCodes{RecoveryCodes: codes})
+}
+
+// VerifySecondFactorHandler checks a code of the logged in user and returns tokens allowing sensitive actions
+func VerifySecondFactorHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := controller.ClaimsFromContext(r.Context())
+	if !ok {
+		msg := &errorMessage{
+			Message: "please login to verify a code",
+		}
+		api.StdResponse(w, http.StatusForbidden, msg)
+		return
+	}
+	req := &codeRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	tokens, err := controller.VerifySecondFactor(r.Context(), claims, req.Code)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to verify code",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	respondTokens(w, tokens)
+}
+
+// LoginSecondFactorHandler completes a login with the mfa token and a code
+func LoginSecondFactorHandler(w http.ResponseWriter, r *http.Request) {
+	req := &loginCodeRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	tokens, err := controller.CompleteLogin(r.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to login",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	respondTokens(w, tokens)
+}
+
+// DeleteAccountHandler removes the current user with its tasks and logins
+func DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if err := controller.DeleteAccount(r.Context(), controller.UserIDFromContext(r.Context())); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to delete account",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	if controller.SessionsEnabled() {
+		controller.ClearSessionCookies(w)
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,134 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/cfthoo/todo-app/api"
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+type errorMessage struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// ListUsersHandler returns every user with its role and disabled state
+func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if controller.Users == nil {
+		msg := &errorMessage{
+			Message: "users are not configured",
+		}
+		api.StdResponse(w, http.StatusInternalServerError, msg)
+		return
+	}
+
+	users, err := controller.Users.ListUsers(r.Context())
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "database error",
+		}
+		api.StdResponse(w, http.StatusInternalServerError, msg)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, users)
+}
+
+// DisableUserHandler stops a user from logging in and ends all of its sessions
+func DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	adminId := controller.UserIDFromContext(r.Context())
+	userId := mux.Vars(r)["id"]
+
+	if err := controller.DisableUser(r.Context(), adminId, userId); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to disable user",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	log.Printf("Admin %s disabled user %s", adminId, userId)
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// EnableUserHandler lets a disabled user login again
+func EnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	adminId := controller.UserIDFromContext(r.Context())
+	userId := mux.Vars(r)["id"]
+
+	if err := controller.EnableUser(r.Context(), userId); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to enable user",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	log.Printf("Admin %s enabled user %s", adminId, userId)
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// SetRoleHandler changes the role of a user
+func SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	req := &roleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "json decode error",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	adminId := controller.UserIDFromContext(r.Context())
+	userId := mux.Vars(r)["id"]
+
+	if err := controller.SetRole(r.Context(), adminId, userId, req.Role); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to change role",
+		}
+		api.StdResponse(w, statusOf(err), msg)
+		return
+	}
+	log.Printf("Admin %s gave user %s the %s role", adminId, userId, req.Role)
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// RevokeSessionsHandler revokes every access and refresh token of a user, it has to login again
+func RevokeSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	adminId := controller.UserIDFromContext(r.Context())
+	userId := mux.Vars(r)["id"]
+
+	if err := controller.RevokeUser(r.Context(), userId); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to revoke sessions",
+		}
+		api.StdResponse(w, http.StatusInternalServerError, msg)
+		return
+	}
+	log.Printf("Admin %s revoked the sessions of user %s", adminId, userId)
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
+
+// statusOf maps the admin errors of the controller to a http status
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, controller.ErrInvalidRole), errors.Is(err, controller.ErrSelfAdministration):
+		return http.StatusBadRequest
+	case errors.Is(err, repo.ErrNotFound):
+		// the user does not exist
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
-	oauth2api "github.com/cfthoo/todo-app/api/oauth"
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/controller"
 	"github.com/cfthoo/todo-app/pkg/db/model"
 	"github.com/gorilla/mux"
 )
@@ -14,16 +16,35 @@ import (
 // DAO is the todoList data access object
 type DAO interface {
 	Create(ctx context.Context, task *model.Task) (*model.Task, error)
-	FetchAll(ctx context.Context, userId string) ([]model.Task, error)
+	FetchAll(ctx context.Context, userId string, filter model.TaskFilter) ([]model.Task, error)
 	FetchByID(ctx context.Context, id int) (*model.Task, error)
 	Update(ctx context.Context, task *model.Task, userId string) (*model.Task, error)
-	MarkComplete(ctx context.Context, id int, userId string) (*model.Task, error)
-	Delete(ctx context.Context, id int, userId string) error
+	MarkComplete(ctx context.Context, id int, userId string, opts model.CompleteOptions) (*model.Task, error)
+	MoveTask(ctx context.Context, id int, listId int, userId string) (*model.Task, error)
+	SetParent(ctx context.Context, id int, parentId *int, userId string) (*model.Task, error)
+	Reorder(ctx context.Context, id int, siblingId int, after bool, userId string) (*model.Task, error)
+	Delete(ctx context.Context, id int, userId string, opts model.DeleteOptions) error
+	Trash(ctx context.Context, userId string) ([]model.Task, error)
+	Restore(ctx context.Context, id int, userId string) (*model.Task, error)
+	Purge(ctx context.Context, id int, userId string) error
+	EmptyTrash(ctx context.Context, userId string) (int64, error)
+	SetArchived(ctx context.Context, id int, archived bool, userId string) (*model.Task, error)
+	ArchiveCompleted(ctx context.Context, listId int, userId string) (int64, error)
+	Completions(ctx context.Context, id int, userId string) ([]model.TaskCompletion, error)
+	AddDependency(ctx context.Context, blockerId int, blockedId int, userId string) error
+	RemoveDependency(ctx context.Context, blockerId int, blockedId int, userId string) error
 }
 
 // Handler provides all of the task handlers
 type Handler struct {
 	TodoListDAO DAO
+	ListDAO     ListDAO
+	LabelDAO    LabelDAO
+	ReminderDAO ReminderDAO
+	// ReminderChannels are the channels with a notifier, reminders can only be sent through them
+	ReminderChannels []string
+	// Subtasks is the default of the ?cascade= option of MarkComplete and Delete
+	Subtasks config.SubtaskConfig
 }
 
 type errorMessage struct {
@@ -45,56 +66,80 @@ func (h *Handler) Create() http.HandlerFunc {
 			return
 		}
 
+		if msg := validateTask(task); msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
 		// set currently logged in userId to createdBy
-		task.CreatedBy = oauth2api.UserId
+		task.CreatedBy = controller.UserIDFromContext(r.Context())
 		resp, err := h.TodoListDAO.Create(r.Context(), task)
 		if err != nil {
 			msg := &errorMessage{
 				Error:   err.Error(),
 				Message: "database error",
 			}
-			StdResponse(w, http.StatusInternalServerError, msg)
+			StdResponse(w, taskStatus(err), msg)
 			return
 		}
 		StdResponse(w, http.StatusCreated, resp)
 	}
 }
 
-// List will return all of the tasks
+// List will return all of the tasks, ?list_id= only returns the tasks of a list and
+// ?labels=1,2 the tasks with any of the labels, or all of them with &match=all.
+// ?tree=true nests the subtasks in their parent and adds their progress.
 func (h *Handler) List() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		// set currently logged in userId to userID
 		// here we only want to retrieve the task for a currently logged in user
-		userID := oauth2api.UserId
-
-		// // get by cookie
-		// userID, err := r.Cookie("UserId")
-		// if err != nil {
-		// 	switch {
-		// 	case errors.Is(err, http.ErrNoCookie):
-		// 		http.Error(w, "cookie not found", http.StatusBadRequest)
-		// 	default:
-		// 		log.Println(err)
-		// 		http.Error(w, "server error", http.StatusInternalServerError)
-		// 	}
-		// 	return
-		// }
-
-		tasks, err := h.TodoListDAO.FetchAll(r.Context(), userID)
+		userID := controller.UserIDFromContext(r.Context())
+
+		filter, msg := taskFilter(r)
+		if msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		tasks, err := h.TodoListDAO.FetchAll(r.Context(), userID, filter)
 		if err != nil {
 			msg := &errorMessage{
 				Error:   err.Error(),
 				Message: "json decode error",
 			}
 			StdResponse(w, http.StatusNotFound, msg)
+			return
 		}
 
+		if r.URL.Query().Get("tree") == "true" {
+			tasks = taskTree(tasks)
+		}
 		StdResponse(w, http.StatusOK, tasks)
 
 	}
 }
 
+// UserTasks will return all of the tasks of the user in the path, it is read only and meant for admins
+func (h *Handler) UserTasks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		userID := vars["id"]
+
+		tasks, err := h.TodoListDAO.FetchAll(r.Context(), userID, model.TaskFilter{IncludeArchived: true})
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+
+		StdResponse(w, http.StatusOK, tasks)
+	}
+}
+
 // FetchByID will return task by id
 func (h *Handler) FetchByID() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -113,6 +158,15 @@ func (h *Handler) FetchByID() http.HandlerFunc {
 
 		}
 
+		// users can only see their own tasks
+		if task.CreatedBy != controller.UserIDFromContext(r.Context()) {
+			msg := &errorMessage{
+				Message: "No record found",
+			}
+			StdResponse(w, http.StatusNotFound, msg)
+			return
+		}
+
 		StdResponse(w, http.StatusOK, task)
 
 	}
@@ -144,8 +198,12 @@ func (h *Handler) Update() http.HandlerFunc {
 			StdResponse(w, http.StatusBadRequest, msg)
 			return
 		}
+		if msg := validateTask(task); msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
 
-		userID := oauth2api.UserId
+		userID := controller.UserIDFromContext(r.Context())
 		resp, err := h.TodoListDAO.Update(r.Context(), task, userID)
 
 		if err != nil {
@@ -153,40 +211,55 @@ func (h *Handler) Update() http.HandlerFunc {
 				Error:   err.Error(),
 				Message: "database error",
 			}
-			StdResponse(w, http.StatusInternalServerError, msg)
+			StdResponse(w, taskStatus(err), msg)
 			return
 		}
 		StdResponse(w, http.StatusOK, resp)
 	}
 }
 
-// update will return the updated task
+// MarkComplete will set the status of the task to done and return it,
+// ?cascade=true also completes its open subtasks.
+// A recurring task moves on to its next occurrence, ?skip=true skips the occurrence and ?end_series=true ends the series.
+// A task with open blockers is refused unless ?force=true.
 func (h *Handler) MarkComplete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		opts, msg := completeOptions(r, h.Subtasks.CascadeComplete)
+		if msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
 		vars := mux.Vars(r)
 		id, _ := strconv.Atoi(vars["id"])
-		userID := oauth2api.UserId
-		resp, err := h.TodoListDAO.MarkComplete(r.Context(), id, userID)
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.MarkComplete(r.Context(), id, userID, opts)
 
 		if err != nil {
 			msg := &errorMessage{
 				Error:   err.Error(),
 				Message: "database error",
 			}
-			StdResponse(w, http.StatusInternalServerError, msg)
+			StdResponse(w, taskStatus(err), msg)
 			return
 		}
 		StdResponse(w, http.StatusOK, resp)
 	}
 }
 
-// delete will remove the task
+// delete will move the task to the trash, ?cascade=false moves its subtasks up to its parent instead of trashing them
 func (h *Handler) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		cascade, msg := cascadeOption(r, h.Subtasks.CascadeDelete)
+		if msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
 		vars := mux.Vars(r)
 		id, _ := strconv.Atoi(vars["id"])
-		userID := oauth2api.UserId
-		err := h.TodoListDAO.Delete(r.Context(), id, userID)
+		userID := controller.UserIDFromContext(r.Context())
+		err := h.TodoListDAO.Delete(r.Context(), id, userID, model.DeleteOptions{Cascade: cascade})
 
 		if err != nil {
 			msg := &errorMessage{
@@ -203,6 +276,61 @@ func (h *Handler) Delete() http.HandlerFunc {
 
 }
 
+// taskFilter reads the task filter from the query of the request
+func taskFilter(r *http.Request) (model.TaskFilter, *errorMessage) {
+	filter := model.TaskFilter{}
+	if listId := r.URL.Query().Get("list_id"); listId != "" {
+		id, err := strconv.Atoi(listId)
+		if err != nil || id <= 0 {
+			return filter, &errorMessage{
+				Message: "Invalid List Id",
+			}
+		}
+		filter.ListID = id
+	}
+	if labels := r.URL.Query().Get("labels"); labels != "" {
+		for _, label := range strings.Split(labels, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(label), 10, 64)
+			if err != nil || id <= 0 {
+				return filter, &errorMessage{
+					Message: "Invalid Label Id",
+				}
+			}
+			filter.LabelIDs = append(filter.LabelIDs, id)
+		}
+		filter.LabelIDs = uniqueIDs(filter.LabelIDs)
+	}
+	filter.IncludeArchived = r.URL.Query().Get("archived") == "true"
+	switch r.URL.Query().Get("match") {
+	case "", "any":
+	case "all":
+		filter.AllLabels = true
+	default:
+		return filter, &errorMessage{
+			Message: "Match must be any or all",
+		}
+	}
+	return filter, nil
+}
+
+// validateTask checks the status, the priority and the recurrence of a task, empty values get the default
+func validateTask(task *model.Task) *errorMessage {
+	if task.Status != "" && !model.ValidStatus(task.Status) {
+		return &errorMessage{
+			Message: "Status must be one of todo, in_progress, blocked, done or cancelled",
+		}
+	}
+	if task.Priority != "" && !model.ValidPriority(task.Priority) {
+		return &errorMessage{
+			Message: "Priority must be one of none, low, medium, high or urgent",
+		}
+	}
+	if task.Recurrence != "" {
+		return validateRecurrence(task)
+	}
+	return nil
+}
+
 // StdResponse will send a standard response with a json body
 func StdResponse(w http.ResponseWriter, status int, body interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/cfthoo/todo-app/pkg/controller"
 	"github.com/cfthoo/todo-app/pkg/db/model"
 )
 
@@ -24,8 +26,29 @@ func (m *mockTodoListDAO) Create(ctx context.Context, task *model.Task) (*model.
 	return task, nil
 }
 
-func (m *mockTodoListDAO) FetchAll(ctx context.Context, userId string) ([]model.Task, error) {
-	return m.tasks, m.err
+func (m *mockTodoListDAO) FetchAll(ctx context.Context, userId string, filter model.TaskFilter) ([]model.Task, error) {
+	if filter.ListID == 0 && len(filter.LabelIDs) == 0 {
+		return m.tasks, m.err
+	}
+	tasks := []model.Task{}
+	for _, task := range m.tasks {
+		if filter.ListID != 0 && task.ListID != filter.ListID {
+			continue
+		}
+		matched := 0
+		for _, label := range filter.LabelIDs {
+			for _, taskLabel := range task.LabelIDs {
+				if label == taskLabel {
+					matched++
+				}
+			}
+		}
+		if len(filter.LabelIDs) > 0 && (matched == 0 || filter.AllLabels && matched != len(filter.LabelIDs)) {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, m.err
 }
 
 func (m *mockTodoListDAO) FetchByID(ctx context.Context, id int) (*model.Task, error) {
@@ -33,16 +56,77 @@ func (m *mockTodoListDAO) FetchByID(ctx context.Context, id int) (*model.Task, e
 	return &m.task, nil
 }
 
-func (m *mockTodoListDAO) Update(ctx context.Context, task *model.Task) (*model.Task, error) {
-	//m.tasks = append(m.tasks, *task)
+func (m *mockTodoListDAO) Update(ctx context.Context, task *model.Task, userId string) (*model.Task, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
 	return task, nil
 }
 
-func (m *mockTodoListDAO) Delete(ctx context.Context, id int) error {
+func (m *mockTodoListDAO) MarkComplete(ctx context.Context, id int, userId string, opts model.CompleteOptions) (*model.Task, error) {
+	m.task.Complete = true
+	return &m.task, m.err
+}
+
+func (m *mockTodoListDAO) MoveTask(ctx context.Context, id int, listId int, userId string) (*model.Task, error) {
+	m.task.ListID = listId
+	return &m.task, m.err
+}
+
+func (m *mockTodoListDAO) SetParent(ctx context.Context, id int, parentId *int, userId string) (*model.Task, error) {
+	m.task.ParentID = parentId
+	return &m.task, m.err
+}
+
+func (m *mockTodoListDAO) Reorder(ctx context.Context, id int, siblingId int, after bool, userId string) (*model.Task, error) {
+	m.task.ID = id
+	return &m.task, m.err
+}
+
+func (m *mockTodoListDAO) Trash(ctx context.Context, userId string) ([]model.Task, error) {
+	return m.tasks, m.err
+}
+
+func (m *mockTodoListDAO) Restore(ctx context.Context, id int, userId string) (*model.Task, error) {
+	m.task.ID = id
+	return &m.task, m.err
+}
+
+func (m *mockTodoListDAO) Purge(ctx context.Context, id int, userId string) error {
+	return m.err
+}
+
+func (m *mockTodoListDAO) EmptyTrash(ctx context.Context, userId string) (int64, error) {
+	return int64(len(m.tasks)), m.err
+}
+
+func (m *mockTodoListDAO) SetArchived(ctx context.Context, id int, archived bool, userId string) (*model.Task, error) {
+	m.task.ID = id
+	m.task.Archived = archived
+	return &m.task, m.err
+}
+
+func (m *mockTodoListDAO) ArchiveCompleted(ctx context.Context, listId int, userId string) (int64, error) {
+	return int64(len(m.tasks)), m.err
+}
+
+func (m *mockTodoListDAO) Delete(ctx context.Context, id int, userId string, opts model.DeleteOptions) error {
 	//m.tasks = append(m.tasks, *task)
 	return nil
 }
 
+func (m *mockTodoListDAO) AddDependency(ctx context.Context, blockerId int, blockedId int, userId string) error {
+	return m.err
+}
+
+func (m *mockTodoListDAO) RemoveDependency(ctx context.Context, blockerId int, blockedId int, userId string) error {
+	return m.err
+}
+
+func (m *mockTodoListDAO) Completions(ctx context.Context, id int, userId string) ([]model.TaskCompletion, error) {
+	return []model.TaskCompletion{}, m.err
+}
+
 func TestHandler_Create(t *testing.T) {
 	type fields struct {
 		TodoListDAO DAO
@@ -86,6 +170,26 @@ func TestHandler_Create(t *testing.T) {
 				//	LastName:  "testison",
 			},
 		},
+		{
+			name: "invalid status",
+			fields: fields{
+				TodoListDAO: &mockTodoListDAO{},
+			},
+			args: args{
+				req: func() *http.Request {
+					u := model.Task{
+						Name:   "task1",
+						Status: "finished",
+					}
+					enc, _ := json.Marshal(u)
+					return httptest.NewRequest(http.MethodPost, "http://www.google.com", bytes.NewReader(enc))
+				}(),
+			},
+			status: http.StatusBadRequest,
+			body: errorMessage{
+				Message: "Status must be one of todo, in_progress, blocked, done or cancelled",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -160,6 +264,76 @@ func TestHandler_List(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "fetched by list",
+			fields: fields{
+				TodoListDAO: &mockTodoListDAO{
+					tasks: []model.Task{
+						{
+							ID:        1,
+							Name:      "task1",
+							CreatedBy: "1234",
+							ListID:    1,
+						},
+						{
+							ID:        2,
+							Name:      "task2",
+							CreatedBy: "1234",
+							ListID:    2,
+						},
+					},
+				},
+			},
+			args: args{
+				req: func() *http.Request {
+					return httptest.NewRequest(http.MethodGet, "http://www.google.com/?list_id=2", strings.NewReader(""))
+				}(),
+			},
+			status: http.StatusOK,
+			body: []*model.Task{
+				{
+					ID:        2,
+					Name:      "task2",
+					CreatedBy: "1234",
+					ListID:    2,
+				},
+			},
+		},
+		{
+			name: "fetched by all labels",
+			fields: fields{
+				TodoListDAO: &mockTodoListDAO{
+					tasks: []model.Task{
+						{
+							ID:        1,
+							Name:      "task1",
+							CreatedBy: "1234",
+							LabelIDs:  []int64{1},
+						},
+						{
+							ID:        2,
+							Name:      "task2",
+							CreatedBy: "1234",
+							LabelIDs:  []int64{1, 3},
+						},
+					},
+				},
+			},
+			args: args{
+				req: func() *http.Request {
+					return httptest.NewRequest(http.MethodGet, "http://www.google.com/?labels=1,3&match=all", strings.NewReader(""))
+				}(),
+			},
+			status: http.StatusOK,
+			body: []*model.Task{
+				{
+					ID:        2,
+					Name:      "task2",
+					CreatedBy: "1234",
+					LabelIDs:  []int64{1, 3},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -234,6 +408,28 @@ func TestHandler_FetchByID(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "other user's task",
+			fields: fields{
+				TodoListDAO: &mockTodoListDAO{
+					task: model.Task{
+						ID:        1,
+						Name:      "task1",
+						CreatedBy: "1234",
+					},
+				},
+			},
+			args: args{
+				req: func() *http.Request {
+					req := httptest.NewRequest(http.MethodGet, "http://www.google.com/1", strings.NewReader(""))
+					return req.WithContext(controller.WithUserID(req.Context(), "5678"))
+				}(),
+			},
+			status: http.StatusNotFound,
+			body: map[string]string{
+				"message": "No record found",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -307,6 +503,29 @@ func TestHandler_Update(t *testing.T) {
 				Name: "task1",
 			},
 		},
+		{
+			name: "other user's task",
+			fields: fields{
+				TodoListDAO: &mockTodoListDAO{
+					err: errors.New("No record found"),
+				},
+			},
+			args: args{
+				req: func() *http.Request {
+					u := model.Task{
+						ID:   1,
+						Name: "task1",
+					}
+					enc, _ := json.Marshal(u)
+					return httptest.NewRequest(http.MethodPatch, "http://www.google.com/1", bytes.NewReader(enc))
+				}(),
+			},
+			status: http.StatusNotFound,
+			body: map[string]string{
+				"error":   "No record found",
+				"message": "database error",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
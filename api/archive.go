@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/gorilla/mux"
+)
+
+type archiveResponse struct {
+	Archived int64 `json:"archived"`
+}
+
+// Archive will archive a task with its subtasks and return the task
+func (h *Handler) Archive() http.HandlerFunc {
+	return h.setArchived(true)
+}
+
+// Unarchive will take a task with its subtasks out of the archive and return the task
+func (h *Handler) Unarchive() http.HandlerFunc {
+	return h.setArchived(false)
+}
+
+func (h *Handler) setArchived(archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.SetArchived(r.Context(), id, archived, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to archive task",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// ArchiveCompleted will archive the done tasks of a list without open subtasks and return how many were archived
+func (h *Handler) ArchiveCompleted() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		if _, err := h.ListDAO.FetchList(r.Context(), id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, listStatus(err), msg)
+			return
+		}
+
+		archived, err := h.TodoListDAO.ArchiveCompleted(r.Context(), id, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, &archiveResponse{Archived: archived})
+	}
+}
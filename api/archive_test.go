@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/gorilla/mux"
+)
+
+func TestHandler_ArchiveCompleted(t *testing.T) {
+	// the user id of the context is empty without ValidateJWT
+	lists := &mockListDAO{lists: []model.List{
+		{ID: 2, Name: "Groceries"},
+		{ID: 3, Name: "Work", CreatedBy: "4321"},
+	}}
+	tests := []struct {
+		name   string
+		id     string
+		status int
+		body   string
+	}{
+		{name: "archived", id: "2", status: http.StatusOK, body: `{"archived":2}`},
+		{name: "other user's list", id: "3", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{ListDAO: lists, TodoListDAO: &mockTodoListDAO{tasks: []model.Task{{ID: 1}, {ID: 4}}}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/lists/"+tt.id+"/archive", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			h.ArchiveCompleted().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.ArchiveCompleted() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+			if tt.body != "" && writer.Body.String() != tt.body {
+				t.Errorf("Handler.ArchiveCompleted() body = %s, want %s", writer.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestHandler_Unarchive(t *testing.T) {
+	h := &Handler{TodoListDAO: &mockTodoListDAO{task: model.Task{Archived: true}}}
+	writer := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "http://www.google.com/todolist/1/archive", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	h.Unarchive().ServeHTTP(writer, req)
+
+	if writer.Result().StatusCode != http.StatusOK {
+		t.Errorf("Handler.Unarchive() = %v, want %v", writer.Result().StatusCode, http.StatusOK)
+	}
+}
+
+func TestTaskFilter_Archived(t *testing.T) {
+	tests := map[string]bool{"/todolist": false, "/todolist?archived=true": true, "/todolist?archived=false": false}
+	for url, want := range tests {
+		filter, msg := taskFilter(httptest.NewRequest(http.MethodGet, url, nil))
+		if msg != nil || filter.IncludeArchived != want {
+			t.Errorf("taskFilter(%s) = %+v, %v, want IncludeArchived %v", url, filter, msg, want)
+		}
+	}
+}
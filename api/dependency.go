@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/gorilla/mux"
+)
+
+// priorityRank orders the priorities from the most to the least urgent
+var priorityRank = map[string]int{
+	model.PriorityUrgent: 0,
+	model.PriorityHigh:   1,
+	model.PriorityMedium: 2,
+	model.PriorityLow:    3,
+	model.PriorityNone:   4,
+}
+
+type blockerRequest struct {
+	BlockerID int `json:"blocker_id"`
+}
+
+// AddBlocker will make another task block the task and return the task
+func (h *Handler) AddBlocker() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &blockerRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if req.BlockerID <= 0 {
+			msg := &errorMessage{
+				Message: "Invalid Blocker Id",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		if err := h.TodoListDAO.AddDependency(r.Context(), req.BlockerID, id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to add blocker",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+
+		task, err := h.TodoListDAO.FetchByID(r.Context(), id)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, task)
+	}
+}
+
+// RemoveBlocker will remove a blocker of the task
+func (h *Handler) RemoveBlocker() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		blockerID, _ := strconv.Atoi(vars["blocker_id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		if err := h.TodoListDAO.RemoveDependency(r.Context(), blockerID, id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to remove blocker",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+// Plan will return the open tasks of a list in an order they can be worked on, blockers come first
+func (h *Handler) Plan() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		if _, err := h.ListDAO.FetchList(r.Context(), id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, listStatus(err), msg)
+			return
+		}
+
+		tasks, err := h.TodoListDAO.FetchAll(r.Context(), userID, model.TaskFilter{ListID: id})
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, taskPlan(tasks))
+	}
+}
+
+// taskPlan sorts the open tasks topologically, a task comes after its blockers. Blockers in other
+// lists or already closed do not hold a task back. Among the tasks which can be done next the most urgent
+// priority, then the earliest due date and then the lowest id comes first.
+func taskPlan(tasks []model.Task) []model.Task {
+	open := map[int]bool{}
+	for _, task := range tasks {
+		if task.Status != model.StatusDone && task.Status != model.StatusCancelled {
+			open[task.ID] = true
+		}
+	}
+
+	// waiting counts the open blockers of a task, blocks links a blocker to the tasks it blocks
+	waiting := map[int]int{}
+	blocks := map[int][]int{}
+	byID := map[int]model.Task{}
+	ready := []model.Task{}
+	for _, task := range tasks {
+		if !open[task.ID] {
+			continue
+		}
+		byID[task.ID] = task
+		for _, blocker := range task.BlockedBy {
+			if open[int(blocker)] {
+				waiting[task.ID]++
+				blocks[int(blocker)] = append(blocks[int(blocker)], task.ID)
+			}
+		}
+		if waiting[task.ID] == 0 {
+			ready = append(ready, task)
+		}
+	}
+
+	plan := []model.Task{}
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool { return planBefore(ready[i], ready[j]) })
+		next := ready[0]
+		ready = ready[1:]
+		plan = append(plan, next)
+		for _, id := range blocks[next.ID] {
+			waiting[id]--
+			if waiting[id] == 0 {
+				ready = append(ready, byID[id])
+			}
+		}
+	}
+	return plan
+}
+
+// planBefore reports whether a is worked on before b when both can be done next
+func planBefore(a model.Task, b model.Task) bool {
+	if priorityRank[a.Priority] != priorityRank[b.Priority] {
+		return priorityRank[a.Priority] < priorityRank[b.Priority]
+	}
+	if (a.DueAt == nil) != (b.DueAt == nil) {
+		return a.DueAt != nil
+	}
+	if a.DueAt != nil && !a.DueAt.Equal(*b.DueAt) {
+		return a.DueAt.Before(*b.DueAt)
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+func TestTaskPlan(t *testing.T) {
+	soon := time.Now().Add(time.Hour)
+	later := soon.Add(time.Hour)
+	tasks := []model.Task{
+		{ID: 1, Name: "deploy", Priority: model.PriorityUrgent, Status: model.StatusTodo, BlockedBy: []int64{2, 3}},
+		{ID: 2, Name: "build", Priority: model.PriorityNone, Status: model.StatusTodo, BlockedBy: []int64{5}},
+		{ID: 3, Name: "write tests", Priority: model.PriorityNone, Status: model.StatusInProgress, DueAt: &later},
+		{ID: 4, Name: "announce", Priority: model.PriorityLow, Status: model.StatusTodo, BlockedBy: []int64{1, 9}},
+		{ID: 5, Name: "review", Priority: model.PriorityNone, Status: model.StatusTodo, DueAt: &soon},
+		{ID: 6, Name: "old release", Priority: model.PriorityHigh, Status: model.StatusDone},
+		{ID: 7, Name: "fix typo", Priority: model.PriorityHigh, Status: model.StatusTodo, BlockedBy: []int64{6}},
+	}
+
+	// 6 is done and 9 is in another list, they do not hold back 7 and 4
+	var got []int
+	for _, task := range taskPlan(tasks) {
+		got = append(got, task.ID)
+	}
+	if want := []int{7, 5, 3, 2, 1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("taskPlan() = %v, want %v", got, want)
+	}
+}
+
+func TestHandler_AddBlocker(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{name: "blocker", body: `{"blocker_id":2}`, status: http.StatusOK},
+		{name: "no blocker", body: `{}`, status: http.StatusBadRequest},
+		{name: "cycle", body: `{"blocker_id":2}`, err: repo.ErrDependencyCycle, status: http.StatusBadRequest},
+		{name: "another user's task", body: `{"blocker_id":2}`, err: errors.New("No record found"), status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{err: tt.err}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/todolist/1/blockers", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.AddBlocker().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.AddBlocker() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_MarkCompleteBlocked(t *testing.T) {
+	h := &Handler{TodoListDAO: &mockTodoListDAO{err: repo.ErrTaskBlocked}}
+	writer := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "http://www.google.com/todolist/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	h.MarkComplete().ServeHTTP(writer, req)
+
+	if writer.Result().StatusCode != http.StatusConflict {
+		t.Errorf("Handler.MarkComplete() = %v, want %v", writer.Result().StatusCode, http.StatusConflict)
+	}
+}
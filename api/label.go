@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+// LabelDAO is the label data access object
+type LabelDAO interface {
+	CreateLabel(ctx context.Context, label *model.Label) (*model.Label, error)
+	FetchLabels(ctx context.Context, userId string) ([]model.Label, error)
+	UpdateLabel(ctx context.Context, label *model.Label, userId string) (*model.Label, error)
+	MergeLabels(ctx context.Context, fromId int, intoId int, userId string) error
+	DeleteLabel(ctx context.Context, id int, userId string) error
+	SetTaskLabels(ctx context.Context, taskId int, labelIds []int64, userId string) error
+}
+
+type mergeRequest struct {
+	Into int `json:"into"`
+}
+
+type taskLabelsRequest struct {
+	LabelIDs []int64 `json:"label_ids"`
+}
+
+// CreateLabel will create a label for the current user
+func (h *Handler) CreateLabel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		label := &model.Label{}
+		if err := json.NewDecoder(r.Body).Decode(label); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if msg := validateLabel(label); msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		label.CreatedBy = controller.UserIDFromContext(r.Context())
+		resp, err := h.LabelDAO.CreateLabel(r.Context(), label)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to create label",
+			}
+			StdResponse(w, labelStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusCreated, resp)
+	}
+}
+
+// Labels will return the labels of the current user
+func (h *Handler) Labels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := controller.UserIDFromContext(r.Context())
+		labels, err := h.LabelDAO.FetchLabels(r.Context(), userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, labels)
+	}
+}
+
+// UpdateLabel will rename or recolor a label on all of its tasks
+func (h *Handler) UpdateLabel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		label := &model.Label{}
+		if err := json.NewDecoder(r.Body).Decode(label); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if msg := validateLabel(label); msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		label.ID, _ = strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.LabelDAO.UpdateLabel(r.Context(), label, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to update label",
+			}
+			StdResponse(w, labelStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// MergeLabel will move the tasks of a label to another label and delete it
+func (h *Handler) MergeLabel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &mergeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		if err := h.LabelDAO.MergeLabels(r.Context(), id, req.Into, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to merge label",
+			}
+			StdResponse(w, labelStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+// DeleteLabel will remove a label from all of its tasks
+func (h *Handler) DeleteLabel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		if err := h.LabelDAO.DeleteLabel(r.Context(), id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to delete label",
+			}
+			StdResponse(w, labelStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+// SetTaskLabels will replace the labels of a task and return the task
+func (h *Handler) SetTaskLabels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &taskLabelsRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		if err := h.LabelDAO.SetTaskLabels(r.Context(), id, uniqueIDs(req.LabelIDs), userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to set labels",
+			}
+			StdResponse(w, labelStatus(err), msg)
+			return
+		}
+
+		task, err := h.TodoListDAO.FetchByID(r.Context(), id)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, task)
+	}
+}
+
+// validateLabel checks the name and the color of a label, an empty color gets the default
+func validateLabel(label *model.Label) *errorMessage {
+	if len(label.Name) == 0 {
+		return &errorMessage{
+			Message: "Label must have a name",
+		}
+	}
+	if label.Color != "" && !model.ValidColor(label.Color) {
+		return &errorMessage{
+			Message: "Color must be a hex color like #e11d48",
+		}
+	}
+	return nil
+}
+
+// uniqueIDs drops the repeated ids keeping the order
+func uniqueIDs(ids []int64) []int64 {
+	seen := map[int64]bool{}
+	unique := []int64{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// labelStatus maps the label errors of the repo to a http status
+func labelStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrLabelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrLabelExists):
+		return http.StatusConflict
+	case errors.Is(err, repo.ErrSameLabel):
+		return http.StatusBadRequest
+	default:
+		return listStatus(err)
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+type mockLabelDAO struct {
+	labels []model.Label
+}
+
+func (m *mockLabelDAO) CreateLabel(ctx context.Context, label *model.Label) (*model.Label, error) {
+	for _, l := range m.labels {
+		if l.Name == label.Name {
+			return nil, repo.ErrLabelExists
+		}
+	}
+	label.ID = len(m.labels) + 1
+	m.labels = append(m.labels, *label)
+	return label, nil
+}
+
+func (m *mockLabelDAO) FetchLabels(ctx context.Context, userId string) ([]model.Label, error) {
+	return m.labels, nil
+}
+
+func (m *mockLabelDAO) UpdateLabel(ctx context.Context, label *model.Label, userId string) (*model.Label, error) {
+	return label, nil
+}
+
+func (m *mockLabelDAO) MergeLabels(ctx context.Context, fromId int, intoId int, userId string) error {
+	if fromId == intoId {
+		return repo.ErrSameLabel
+	}
+	return nil
+}
+
+func (m *mockLabelDAO) DeleteLabel(ctx context.Context, id int, userId string) error {
+	return nil
+}
+
+func (m *mockLabelDAO) SetTaskLabels(ctx context.Context, taskId int, labelIds []int64, userId string) error {
+	for _, id := range labelIds {
+		if int(id) > len(m.labels) {
+			return repo.ErrLabelNotFound
+		}
+	}
+	return nil
+}
+
+func TestHandler_CreateLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "created", body: `{"name": "work", "color": "#e11d48"}`, status: http.StatusCreated},
+		{name: "default color", body: `{"name": "home"}`, status: http.StatusCreated},
+		{name: "invalid color", body: `{"name": "home", "color": "red"}`, status: http.StatusBadRequest},
+		{name: "name taken", body: `{"name": "urgent"}`, status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{LabelDAO: &mockLabelDAO{labels: []model.Label{{ID: 1, Name: "urgent"}}}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/labels", strings.NewReader(tt.body))
+			h.CreateLabel().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.CreateLabel() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_MergeLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "merged", body: `{"into": 2}`, status: http.StatusNoContent},
+		{name: "into itself", body: `{"into": 1}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{LabelDAO: &mockLabelDAO{}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/labels/1/merge", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.MergeLabel().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.MergeLabel() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_SetTaskLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "set", body: `{"label_ids": [1, 2, 1]}`, status: http.StatusOK},
+		{name: "cleared", body: `{"label_ids": []}`, status: http.StatusOK},
+		{name: "unknown label", body: `{"label_ids": [9]}`, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				TodoListDAO: &mockTodoListDAO{task: model.Task{ID: 1, Name: "task1"}},
+				LabelDAO:    &mockLabelDAO{labels: []model.Label{{ID: 1, Name: "urgent"}, {ID: 2, Name: "work"}}},
+			}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "http://www.google.com/todolist/1/labels", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.SetTaskLabels().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.SetTaskLabels() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
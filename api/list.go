@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+// ListDAO is the list data access object
+type ListDAO interface {
+	CreateList(ctx context.Context, list *model.List) (*model.List, error)
+	FetchLists(ctx context.Context, userId string) ([]model.List, error)
+	FetchList(ctx context.Context, id int, userId string) (*model.List, error)
+	UpdateList(ctx context.Context, list *model.List, userId string) (*model.List, error)
+	DeleteList(ctx context.Context, id int, userId string) error
+}
+
+type moveRequest struct {
+	ListID int `json:"list_id"`
+}
+
+// CreateList will create a list for the current user
+func (h *Handler) CreateList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := &model.List{}
+		if err := json.NewDecoder(r.Body).Decode(list); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if len(list.Name) == 0 {
+			msg := &errorMessage{
+				Message: "List must have a name",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		list.CreatedBy = controller.UserIDFromContext(r.Context())
+		resp, err := h.ListDAO.CreateList(r.Context(), list)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusCreated, resp)
+	}
+}
+
+// Lists will return the lists of the current user, the inbox comes first
+func (h *Handler) Lists() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := controller.UserIDFromContext(r.Context())
+		lists, err := h.ListDAO.FetchLists(r.Context(), userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, lists)
+	}
+}
+
+// FetchList will return a list by id
+func (h *Handler) FetchList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		list, err := h.ListDAO.FetchList(r.Context(), id, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, listStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, list)
+	}
+}
+
+// UpdateList will rename a list
+func (h *Handler) UpdateList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list := &model.List{}
+		if err := json.NewDecoder(r.Body).Decode(list); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if len(list.Name) == 0 {
+			msg := &errorMessage{
+				Message: "List must have a name",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		list.ID, _ = strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.ListDAO.UpdateList(r.Context(), list, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, listStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// DeleteList will remove a list, its tasks go to the trash
+func (h *Handler) DeleteList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		if err := h.ListDAO.DeleteList(r.Context(), id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to delete list",
+			}
+			StdResponse(w, listStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+// MoveTask will move a task to another list
+func (h *Handler) MoveTask() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &moveRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if req.ListID <= 0 {
+			msg := &errorMessage{
+				Message: "Invalid List Id",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.MoveTask(r.Context(), id, req.ListID, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to move task",
+			}
+			StdResponse(w, listStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// listStatus maps the list errors of the repo to a http status
+func listStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrListNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrInboxList):
+		return http.StatusBadRequest
+	case err.Error() == "No record found":
+		// the task does not exist or belongs to another user
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
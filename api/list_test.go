@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+type mockListDAO struct {
+	lists []model.List
+}
+
+func (m *mockListDAO) CreateList(ctx context.Context, list *model.List) (*model.List, error) {
+	list.ID = len(m.lists) + 1
+	m.lists = append(m.lists, *list)
+	return list, nil
+}
+
+func (m *mockListDAO) FetchLists(ctx context.Context, userId string) ([]model.List, error) {
+	return m.lists, nil
+}
+
+func (m *mockListDAO) FetchList(ctx context.Context, id int, userId string) (*model.List, error) {
+	for _, list := range m.lists {
+		if list.ID == id && list.CreatedBy == userId {
+			return &list, nil
+		}
+	}
+	return nil, repo.ErrListNotFound
+}
+
+func (m *mockListDAO) UpdateList(ctx context.Context, list *model.List, userId string) (*model.List, error) {
+	return list, nil
+}
+
+func (m *mockListDAO) DeleteList(ctx context.Context, id int, userId string) error {
+	list, err := m.FetchList(ctx, id, userId)
+	if err != nil {
+		return err
+	}
+	if list.Inbox {
+		return repo.ErrInboxList
+	}
+	return nil
+}
+
+func TestHandler_CreateList(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "created", body: `{"name": "Groceries"}`, status: http.StatusCreated},
+		{name: "without name", body: `{}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{ListDAO: &mockListDAO{}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/lists", strings.NewReader(tt.body))
+			h.CreateList().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.CreateList() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_DeleteList(t *testing.T) {
+	// the user id of the context is empty without ValidateJWT
+	dao := &mockListDAO{lists: []model.List{
+		{ID: 1, Name: repo.InboxName, Inbox: true},
+		{ID: 2, Name: "Groceries"},
+		{ID: 3, Name: "Work", CreatedBy: "4321"},
+	}}
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{name: "deleted", id: "2", status: http.StatusNoContent},
+		{name: "inbox", id: "1", status: http.StatusBadRequest},
+		{name: "other user's list", id: "3", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{ListDAO: dao}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "http://www.google.com/lists/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			h.DeleteList().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.DeleteList() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_MoveTask(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "moved", body: `{"list_id": 2}`, status: http.StatusOK},
+		{name: "without list", body: `{}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{task: model.Task{ID: 1, Name: "task1", ListID: 1}}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "http://www.google.com/todolist/1/list", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.MoveTask().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.MoveTask() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
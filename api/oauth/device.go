@@ -0,0 +1,176 @@
+package oauth2api
+
+import (
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+
+	"github.com/cfthoo/todo-app/api"
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/oidc"
+)
+
+// tokenError is the error response of the token endpoint (RFC 6749 section 5.2)
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type loginLink struct {
+	Name  string
+	Label string
+}
+
+type devicePage struct {
+	UserCode  string
+	DenyToken string
+	Error     string
+	Message   string
+	Providers []loginLink
+}
+
+var devicePageTemplate = template.Must(template.New("device").Parse(`
+        <html>
+            <body>
+                {{if .Message}}<p>{{.Message}}</p>{{end}}
+                {{if .Error}}<p>{{.Error}}</p>{{end}}
+                {{if .UserCode}}
+                <p>Log in to approve the device showing the code <b>{{.UserCode}}</b>.</p>
+                {{range .Providers}}<a href="/{{.Name}}/login?user_code={{$.UserCode}}">{{.Label}} Log In</a><br>
+                {{end}}
+                <form method="post" action="/auth/device">
+                    <input type="hidden" name="deny_token" value="{{.DenyToken}}">
+                    <button type="submit">This was not me</button>
+                </form>
+                {{else if not .Message}}
+                <form method="get" action="/auth/device">
+                    Enter the code shown on your device: <input name="user_code" autofocus>
+                    <button type="submit">Continue</button>
+                </form>
+                {{end}}
+            </body>
+        </html>`))
+
+// DeviceCodeHandler starts the device authorization of a terminal client (RFC 8628 section 3.1)
+func DeviceCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		api.StdResponse(w, http.StatusBadRequest, &tokenError{Error: "invalid_request", ErrorDescription: err.Error()})
+		return
+	}
+
+	authorization, err := controller.RequestDeviceCode(r.Context(), r.PostForm.Get("client_id"))
+	if err != nil {
+		api.StdResponse(w, http.StatusInternalServerError, &tokenError{Error: "server_error", ErrorDescription: err.Error()})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	api.StdResponse(w, http.StatusOK, authorization)
+}
+
+// DevicePageHandler is the verification page where the user enters the code shown on the
+// device and picks the provider to login with
+func DevicePageHandler(w http.ResponseWriter, r *http.Request) {
+	page := &devicePage{}
+	if userCode := r.URL.Query().Get("user_code"); userCode != "" {
+		code, err := controller.LookupDeviceCode(r.Context(), clientAddr(r), userCode)
+		if err == nil {
+			page.DenyToken, err = controller.CreateDenyToken(code)
+		}
+		if err != nil {
+			page.Error = err.Error()
+		} else {
+			page.UserCode = controller.FormatUserCode(code.UserCode)
+			page.Providers = loginLinks()
+		}
+	}
+	renderDevicePage(w, page)
+}
+
+// DeviceDenyHandler refuses the device authorization of the user code signed in the posted page
+func DeviceDenyHandler(w http.ResponseWriter, r *http.Request) {
+	page := &devicePage{}
+	if err := controller.DenyDevice(r.Context(), r.PostFormValue("deny_token")); err != nil {
+		page.Error = err.Error()
+	} else {
+		page.Message = "The device was not logged in, you can close this page."
+	}
+	renderDevicePage(w, page)
+}
+
+// TokenHandler is the token endpoint polled by devices, it also accepts refresh tokens
+// so standard OAuth clients can renew their access token
+func TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		api.StdResponse(w, http.StatusBadRequest, &tokenError{Error: "invalid_request", ErrorDescription: err.Error()})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.PostForm.Get("grant_type") {
+	case controller.DeviceCodeGrantType:
+		deviceCode := r.PostForm.Get("device_code")
+		if deviceCode == "" {
+			api.StdResponse(w, http.StatusBadRequest, &tokenError{Error: "invalid_request", ErrorDescription: "device_code is required"})
+			return
+		}
+		tokens, err := controller.PollDeviceToken(r.Context(), deviceCode)
+		if respondChallenge(w, err) {
+			return
+		}
+		if err != nil {
+			status, tokenErr := http.StatusInternalServerError, &tokenError{Error: "server_error", ErrorDescription: err.Error()}
+			switch {
+			case errors.Is(err, controller.ErrAuthorizationPending), errors.Is(err, controller.ErrSlowDown),
+				errors.Is(err, controller.ErrAccessDenied), errors.Is(err, controller.ErrExpiredDeviceCode),
+				errors.Is(err, controller.ErrInvalidDeviceCode):
+				status, tokenErr = http.StatusBadRequest, &tokenError{Error: err.Error()}
+			}
+			api.StdResponse(w, status, tokenErr)
+			return
+		}
+		api.StdResponse(w, http.StatusOK, tokens)
+	case "refresh_token":
+		tokens, err := controller.RefreshJWT(r.Context(), r.PostForm.Get("refresh_token"))
+		if err != nil {
+			status, tokenErr := http.StatusInternalServerError, &tokenError{Error: "server_error", ErrorDescription: err.Error()}
+			if errors.Is(err, controller.ErrInvalidRefreshToken) || errors.Is(err, controller.ErrRefreshTokenReused) {
+				status, tokenErr = http.StatusBadRequest, &tokenError{Error: "invalid_grant", ErrorDescription: err.Error()}
+			}
+			api.StdResponse(w, status, tokenErr)
+			return
+		}
+		api.StdResponse(w, http.StatusOK, tokens)
+	default:
+		api.StdResponse(w, http.StatusBadRequest, &tokenError{Error: "unsupported_grant_type"})
+	}
+}
+
+// loginLinks returns the providers a user can login with
+func loginLinks() []loginLink {
+	links := []loginLink{
+		{Name: "google", Label: "Google"},
+		{Name: "fb", Label: "Facebook"},
+		{Name: "github", Label: "Github"},
+	}
+	for _, provider := range oidc.Providers() {
+		links = append(links, loginLink{Name: provider.Name, Label: provider.Name})
+	}
+	return links
+}
+
+// clientAddr returns the address of the client without its port, the user code lookups are limited per address
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func renderDevicePage(w http.ResponseWriter, page *devicePage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := devicePageTemplate.Execute(w, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,14 +2,25 @@ package oauth2api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
+	"github.com/cfthoo/todo-app/api"
 	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/cfthoo/todo-app/pkg/oidc"
 	"github.com/cfthoo/todo-app/pkg/utils"
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
 )
 
 type UserInfo struct {
@@ -22,7 +33,23 @@ type GithubUserInfo struct {
 	Email string `json:"email"`
 }
 
-var UserId string
+// stateCookieName is the cookie binding the oauth state to the browser that started the login
+const stateCookieName = "oauth_state"
+
+// stateMaxAge is how long a user has to finish the login with the provider
+const stateMaxAge = 10 * time.Minute
+
+// loginState is stored in the signed state cookie between login and callback
+type loginState struct {
+	State    string `json:"state"`
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier,omitempty"`
+	Nonce    string `json:"nonce,omitempty"`
+	// LinkUserID is set when a logged in user links this provider to its account
+	LinkUserID string `json:"link_user_id,omitempty"`
+	// UserCode is set when the login approves a device
+	UserCode string `json:"user_code,omitempty"`
+}
 
 // LoginHandler handles and redirects to login page based on loginType ie google/fb/github
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
@@ -33,9 +60,71 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// set configuration based on loginType
 	configs, _, err := utils.SetOuathConfigByLoginType(loginType)
 	if err != nil {
-		fmt.Fprintln(w, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
-	url := configs.AuthCodeURL("randomstate")
+
+	// random state per login, bound to this browser with a signed cookie
+	state, err := utils.RandomString(16)
+	if err != nil {
+		http.Error(w, "failed to create state", http.StatusInternalServerError)
+		return
+	}
+	loginSt := &loginState{
+		State:    state,
+		Provider: loginType,
+	}
+	// a logged in user linking another provider to its account
+	if linkToken := r.URL.Query().Get("link_token"); linkToken != "" {
+		loginSt.LinkUserID, err = controller.ParseLinkToken(r, linkToken, loginType)
+		if err != nil {
+			http.Error(w, "link expired, please try again", http.StatusBadRequest)
+			return
+		}
+	}
+	// a user approving a terminal client from the device page
+	if userCode := r.URL.Query().Get("user_code"); userCode != "" {
+		code, err := controller.LookupDeviceCode(r.Context(), clientAddr(r), userCode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		loginSt.UserCode = code.UserCode
+	}
+
+	opts := []oauth2.AuthCodeOption{}
+	if utils.SupportsPKCE(loginType) {
+		loginSt.Verifier, err = utils.RandomString(32)
+		if err != nil {
+			http.Error(w, "failed to create code verifier", http.StatusInternalServerError)
+			return
+		}
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", utils.PKCEChallenge(loginSt.Verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+	}
+
+	// OpenID Connect providers echo the nonce back in the id token
+	if _, ok := oidc.Lookup(loginType); ok {
+		loginSt.Nonce, err = utils.RandomString(16)
+		if err != nil {
+			http.Error(w, "failed to create nonce", http.StatusInternalServerError)
+			return
+		}
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", loginSt.Nonce))
+	}
+
+	value, err := json.Marshal(loginSt)
+	if err != nil {
+		http.Error(w, "failed to create state", http.StatusInternalServerError)
+		return
+	}
+	if err := controller.SetSignedCookie(w, stateCookieName, string(value), stateMaxAge); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	url := configs.AuthCodeURL(state, opts...)
 
 	http.Redirect(w, r, url, http.StatusSeeOther)
 
@@ -47,80 +136,403 @@ func CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	// get loginType from url
 	loginType := utils.StringExtractor(r.URL.String(), "/")
 
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "login failed: "+providerErr, http.StatusBadRequest)
+		return
+	}
+
 	//state
-	state := r.URL.Query()["state"][0]
-	if state != "randomstate" {
-		fmt.Fprintln(w, "states dont match")
+	state := query.Get("state")
+	if state == "" {
+		http.Error(w, "missing state", http.StatusBadRequest)
 		return
 	}
 
 	//code
-	code := r.URL.Query()["code"][0]
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		return
+	}
+
+	// the state has to match the one stored in this browser by LoginHandler
+	value, err := controller.ReadSignedCookie(r, stateCookieName)
+	if err != nil {
+		http.Error(w, "login expired, please login again", http.StatusBadRequest)
+		return
+	}
+	controller.ClearCookie(w, stateCookieName)
+	loginSt := &loginState{}
+	if err := json.Unmarshal([]byte(value), loginSt); err != nil {
+		http.Error(w, "login expired, please login again", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(loginSt.State)) != 1 || loginSt.Provider != loginType {
+		http.Error(w, "states dont match", http.StatusBadRequest)
+		return
+	}
 
 	// set configuration based on loginType
 	configs, oauthUrl, err := utils.SetOuathConfigByLoginType(loginType)
 	if err != nil {
-		fmt.Fprintln(w, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
 
 	// exchange code for token
-	token, err := configs.Exchange(context.Background(), code)
+	opts := []oauth2.AuthCodeOption{}
+	if loginSt.Verifier != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", loginSt.Verifier))
+	}
+	token, err := configs.Exchange(context.Background(), code, opts...)
+	if err != nil {
+		http.Error(w, "Code-Token Exchange Failed", http.StatusBadGateway)
+		return
+	}
+
+	// get userId and embed it in the token so every request carries
+	// its own identity, ie listTodoList by userId
+	userInfo, err := fetchUserInfo(r.Context(), loginType, oauthUrl, token, loginSt.Nonce)
 	if err != nil {
-		fmt.Fprintln(w, "Code-Token Exchange Failed")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	identity := &model.Identity{
+		Provider:       loginType,
+		ProviderUserID: userInfo.Id,
+		Email:          userInfo.Email,
+	}
+
+	if loginSt.LinkUserID != "" {
+		if err := controller.LinkIdentity(r.Context(), loginSt.LinkUserID, identity); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, repo.ErrIdentityLinked) {
+				status = http.StatusConflict
+			}
+			http.Error(w, "failed to link account: "+err.Error(), status)
+			return
+		}
+		fmt.Fprintf(w, "Linked %s to your account!\n", loginType)
+		return
+	}
+
+	if controller.Users == nil {
+		http.Error(w, "users are not configured", http.StatusInternalServerError)
+		return
+	}
+	user, err := controller.Users.FindOrCreateUser(r.Context(), identity)
+	if err != nil {
+		http.Error(w, "failed to find user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user.DisabledAt != nil {
+		http.Error(w, controller.ErrUserDisabled.Error(), http.StatusForbidden)
+		return
+	}
+	userId := strconv.Itoa(user.ID)
+
+	if loginSt.UserCode != "" {
+		if err := controller.ApproveDevice(r.Context(), loginSt.UserCode, userId, loginType); err != nil {
+			http.Error(w, "failed to approve device: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Device approved!\nYou can close this page and return to your device.")
+		return
+	}
+
+	// get jwtToken and the refresh token used to renew it, users with an authenticator send a code first
+	tokens, err := controller.IssueLoginTokens(r.Context(), userId, loginType)
+	if respondChallenge(w, err) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(w, "failed to create token: %s", err.Error())
+		return
+	}
+
+	// browser sessions keep the tokens in cookies the page can not read
+	if controller.SessionsEnabled() {
+		if err := controller.SetSessionCookies(w, tokens); err != nil {
+			http.Error(w, "failed to start session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, controller.SessionRedirectURL(), http.StatusSeeOther)
+		return
+	}
+
+	responseBody := "Authorized!\nPlease copy the token :" + tokens.AccessToken +
+		"\nRefresh token :" + tokens.RefreshToken
+	// send back response to browser
+	fmt.Fprintln(w, responseBody)
+
+}
+
+// fetchUserInfo returns the user of the provider token, OpenID Connect providers are
+// trusted through their verified id token, the others through their user info api
+func fetchUserInfo(ctx context.Context, loginType string, oauthUrl string, token *oauth2.Token, nonce string) (*UserInfo, error) {
+	if provider, ok := oidc.Lookup(loginType); ok {
+		rawIDToken, _ := token.Extra("id_token").(string)
+		if rawIDToken == "" {
+			return nil, errors.New("provider did not return an id token")
+		}
+		idClaims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id token: %w", err)
+		}
+		return &UserInfo{Id: idClaims.Subject, Email: idClaims.Email}, nil
 	}
 
 	// fetch user info with oauth api
 	resp, err := utils.FetchUserData(loginType, oauthUrl, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse user data JSON Object
 	defer resp.Body.Close()
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Fprintf(w, "failed read response: %s", err.Error())
-		return
+		return nil, fmt.Errorf("failed read response: %w", err)
 	}
 
-	// get userId and to be used in other api ,ie listTodoList by userId
-	// in real world scenario please use cookie to store the userId
 	if loginType == "github" {
-		var userInfo GithubUserInfo
-		err = json.Unmarshal(contents, &userInfo)
+		var githubUserInfo GithubUserInfo
+		err = json.Unmarshal(contents, &githubUserInfo)
 		if err != nil {
-			fmt.Fprintf(w, "failed to unmarshal JSON: %s", err.Error())
-			return
+			return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 		}
-		UserId = strconv.Itoa(userInfo.Id)
-	} else {
-		var userInfo UserInfo
-		err = json.Unmarshal(contents, &userInfo)
+		return &UserInfo{Id: strconv.Itoa(githubUserInfo.Id), Email: githubUserInfo.Email}, nil
+	}
+
+	var userInfo UserInfo
+	err = json.Unmarshal(contents, &userInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if userInfo.Id == "" {
+		return nil, errors.New("provider did not return a user id")
+	}
+	return &userInfo, nil
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type errorMessage struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type mfaChallenge struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// respondChallenge responds 401 with the mfa token and returns true when err is a *SecondFactorChallenge
+func respondChallenge(w http.ResponseWriter, err error) bool {
+	var challenge *controller.SecondFactorChallenge
+	if !errors.As(err, &challenge) {
+		return false
+	}
+	msg := &mfaChallenge{
+		Error:     "mfa_required",
+		Message:   "please send a code of your authenticator to /auth/login/2fa",
+		MFAToken:  challenge.MFAToken,
+		ExpiresIn: challenge.ExpiresIn,
+	}
+	api.StdResponse(w, http.StatusUnauthorized, msg)
+	return true
+}
+
+// RefreshHandler exchanges a refresh token for a new access token and refresh token
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	req := &refreshRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "json decode error",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// browser sessions send the refresh token in a cookie
+	fromCookie := false
+	if req.RefreshToken == "" {
+		refreshToken, err := controller.RefreshTokenFromCookie(r)
 		if err != nil {
-			fmt.Fprintf(w, "failed to unmarshal JSON: %s", err.Error())
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to refresh token",
+			}
+			api.StdResponse(w, http.StatusForbidden, msg)
+			return
+		}
+		req.RefreshToken, fromCookie = refreshToken, refreshToken != ""
+	}
+	if req.RefreshToken == "" {
+		msg := &errorMessage{
+			Message: "refresh_token is required",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tokens, err := controller.RefreshJWT(r.Context(), req.RefreshToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, controller.ErrInvalidRefreshToken) || errors.Is(err, controller.ErrRefreshTokenReused) {
+			status = http.StatusUnauthorized
+		}
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to refresh token",
+		}
+		api.StdResponse(w, status, msg)
+		return
+	}
+
+	if fromCookie {
+		if err := controller.SetSessionCookies(w, tokens); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to refresh session",
+			}
+			api.StdResponse(w, http.StatusInternalServerError, msg)
 			return
 		}
+		api.StdResponse(w, http.StatusNoContent, nil)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, tokens)
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutHandler revokes the current token, and the refresh token when it is sent in the body
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := controller.ClaimsFromContext(r.Context())
+	if !ok {
+		msg := &errorMessage{
+			Message: "not authorized",
+		}
+		api.StdResponse(w, http.StatusUnauthorized, msg)
+		return
+	}
 
-		// it is recommeded to set user_id in cookies
-		UserId = userInfo.Id
+	req := &logoutRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "json decode error",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return
+	}
 
-		// cookie := http.Cookie{
-		// 	Name:     "UserId",
-		// 	Value:    userInfo.Id,
-		// 	Path:     "/",
-		// 	MaxAge:   3600,
-		// 	HttpOnly: true,
-		// 	Secure:   true,
-		// 	SameSite: http.SameSiteLaxMode,
-		// }
+	if req.RefreshToken == "" && controller.SessionsEnabled() {
+		if cookie, err := r.Cookie(controller.RefreshCookieName); err == nil {
+			req.RefreshToken = cookie.Value
+		}
+	}
 
-		// http.SetCookie(w, &cookie)
+	if err := controller.Logout(r.Context(), claims, req.RefreshToken); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, controller.ErrInvalidRefreshToken) {
+			status = http.StatusBadRequest
+		}
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to logout",
+		}
+		api.StdResponse(w, status, msg)
+		return
+	}
 
+	if controller.SessionsEnabled() {
+		controller.ClearSessionCookies(w)
 	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
 
-	// get jwtToken
-	// in real world scenario please save the token somewhere ie DB ,cookie/session
-	jwtToken, err := controller.CreateJWT([]byte(token.AccessToken))
+// LinkHandler returns the login url used to link another provider to the current user's account
+func LinkHandler(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	if _, _, err := utils.SetOuathConfigByLoginType(provider); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "unknown provider",
+		}
+		api.StdResponse(w, http.StatusNotFound, msg)
+		return
+	}
 
-	responseBody := "Authorized!\nPlease copy the token :" + jwtToken
-	// send back response to browser
-	fmt.Fprintln(w, responseBody)
+	linkToken, err := controller.CreateLinkToken(w, controller.UserIDFromContext(r.Context()), provider)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to create link",
+		}
+		api.StdResponse(w, http.StatusInternalServerError, msg)
+		return
+	}
+
+	loginUrl := fmt.Sprintf("/%s/login?%s", provider, url.Values{"link_token": {linkToken}}.Encode())
+	api.StdResponse(w, http.StatusOK, map[string]string{"url": loginUrl})
+}
+
+// IdentitiesHandler returns the providers linked to the current user's account
+func IdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(controller.UserIDFromContext(r.Context()))
+	if err != nil || controller.Users == nil {
+		msg := &errorMessage{
+			Message: "not authorized",
+		}
+		api.StdResponse(w, http.StatusUnauthorized, msg)
+		return
+	}
+
+	identities, err := controller.Users.ListIdentities(r.Context(), userId)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "database error",
+		}
+		api.StdResponse(w, http.StatusInternalServerError, msg)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, identities)
+}
 
+// UnlinkHandler removes a provider from the current user's account
+func UnlinkHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(controller.UserIDFromContext(r.Context()))
+	if err != nil || controller.Users == nil {
+		msg := &errorMessage{
+			Message: "not authorized",
+		}
+		api.StdResponse(w, http.StatusUnauthorized, msg)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err := controller.Users.DeleteIdentity(r.Context(), id, userId); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repo.ErrLastIdentity) {
+			status = http.StatusConflict
+		}
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to unlink",
+		}
+		api.StdResponse(w, status, msg)
+		return
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
 }
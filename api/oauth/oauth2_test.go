@@ -0,0 +1,101 @@
+package oauth2api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/controller"
+)
+
+func setupTestCookies(t *testing.T) {
+	if err := controller.SetupCookies(&config.CookieConfig{Secret: []byte("a-test-cookie-secret-long-enough!!")}); err != nil {
+		t.Fatalf("failed to setup cookies: %v", err)
+	}
+}
+
+func TestLoginHandler(t *testing.T) {
+	setupTestCookies(t)
+
+	writer := httptest.NewRecorder()
+	LoginHandler(writer, httptest.NewRequest(http.MethodGet, "/google/login", nil))
+
+	if writer.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("LoginHandler() = %v, want %v", writer.Result().StatusCode, http.StatusSeeOther)
+	}
+	location, err := url.Parse(writer.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse redirect: %v", err)
+	}
+	query := location.Query()
+	if len(query.Get("state")) < 32 || query.Get("state") == "randomstate" {
+		t.Errorf("expected a random state, got %v", query.Get("state"))
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("expected a S256 code challenge, got %v", query)
+	}
+	if len(writer.Result().Cookies()) != 1 || writer.Result().Cookies()[0].Name != stateCookieName {
+		t.Errorf("expected the state cookie to be set")
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	setupTestCookies(t)
+
+	// start a login to get a valid state cookie
+	writer := httptest.NewRecorder()
+	LoginHandler(writer, httptest.NewRequest(http.MethodGet, "/google/login", nil))
+	stateCookie := writer.Result().Cookies()[0]
+
+	tests := []struct {
+		name   string
+		url    string
+		cookie *http.Cookie
+		status int
+	}{
+		{
+			name:   "missing state",
+			url:    "/google/callback?code=abc",
+			cookie: stateCookie,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing code",
+			url:    "/google/callback?state=abc",
+			cookie: stateCookie,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing cookie",
+			url:    "/google/callback?state=abc&code=abc",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "state mismatch",
+			url:    "/google/callback?state=randomstate&code=abc",
+			cookie: stateCookie,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "provider error",
+			url:    "/google/callback?error=access_denied",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			writer := httptest.NewRecorder()
+			CallbackHandler(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("CallbackHandler() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package oauth2api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfthoo/todo-app/api"
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/gorilla/mux"
+)
+
+type createTokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateTokenHandler mints a personal access token for the current user
+func CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	req := &createTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "json decode error",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	userId := controller.UserIDFromContext(r.Context())
+	token, err := controller.CreatePersonalAccessToken(r.Context(), userId, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to create token",
+		}
+		api.StdResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	api.StdResponse(w, http.StatusCreated, token)
+}
+
+// ListTokensHandler returns the personal access tokens of the current user
+func ListTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(controller.UserIDFromContext(r.Context()))
+	if err != nil || controller.AccessTokens == nil {
+		msg := &errorMessage{
+			Message: "not authorized",
+		}
+		api.StdResponse(w, http.StatusUnauthorized, msg)
+		return
+	}
+
+	tokens, err := controller.AccessTokens.ListAccessTokens(r.Context(), userId)
+	if err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "database error",
+		}
+		api.StdResponse(w, http.StatusInternalServerError, msg)
+		return
+	}
+	api.StdResponse(w, http.StatusOK, tokens)
+}
+
+// RevokeTokenHandler revokes a personal access token of the current user
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.Atoi(controller.UserIDFromContext(r.Context()))
+	if err != nil || controller.AccessTokens == nil {
+		msg := &errorMessage{
+			Message: "not authorized",
+		}
+		api.StdResponse(w, http.StatusUnauthorized, msg)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if err := controller.AccessTokens.RevokeAccessToken(r.Context(), id, userId); err != nil {
+		msg := &errorMessage{
+			Error:   err.Error(),
+			Message: "failed to revoke token",
+		}
+		api.StdResponse(w, http.StatusNotFound, msg)
+		return
+	}
+	api.StdResponse(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/gorilla/mux"
+)
+
+// reorderRequest places a task before or after a sibling, exactly one of them is set
+type reorderRequest struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+// Reorder will move a task right before or after another task with the same list and parent
+func (h *Handler) Reorder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &reorderRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if (req.Before == nil) == (req.After == nil) {
+			msg := &errorMessage{
+				Message: "Either before or after is required",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		siblingID, after := req.Before, false
+		if req.After != nil {
+			siblingID, after = req.After, true
+		}
+
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.Reorder(r.Context(), id, *siblingID, after, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to move task",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+func TestHandler_Reorder(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{name: "before", body: `{"before":2}`, status: http.StatusOK},
+		{name: "after", body: `{"after":2}`, status: http.StatusOK},
+		{name: "no sibling", body: `{}`, status: http.StatusBadRequest},
+		{name: "before and after", body: `{"before":2,"after":3}`, status: http.StatusBadRequest},
+		{name: "not a sibling", body: `{"after":2}`, err: repo.ErrNotSibling, status: http.StatusBadRequest},
+		{name: "sibling not found", body: `{"after":2}`, err: repo.ErrSiblingNotFound, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{err: tt.err}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/todolist/1/move", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.Reorder().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.Reorder() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/rrule"
+	"github.com/gorilla/mux"
+)
+
+// Completions will return the completed and skipped occurrences of a recurring task
+func (h *Handler) Completions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		completions, err := h.TodoListDAO.Completions(r.Context(), id, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, completions)
+	}
+}
+
+// validateRecurrence checks the rule of a recurring task, the first occurrence is its due date
+func validateRecurrence(task *model.Task) *errorMessage {
+	rule, err := rrule.Parse(task.Recurrence, time.UTC)
+	if err != nil {
+		return &errorMessage{
+			Error:   err.Error(),
+			Message: "Recurrence must be a rule like FREQ=WEEKLY;BYDAY=MO",
+		}
+	}
+	if task.DueAt == nil {
+		return &errorMessage{
+			Message: "A recurring task must have a due date",
+		}
+	}
+
+	// a rule like FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30 never occurs, a series ended by COUNT or UNTIL is fine
+	unlimited := *rule
+	unlimited.Count, unlimited.Until = 0, time.Time{}
+	if _, ok := unlimited.Next(*task.DueAt, 1); !ok {
+		return &errorMessage{
+			Message: "Recurrence must have an occurrence after the due date",
+		}
+	}
+	return nil
+}
+
+// completeOptions reads the ?cascade=, ?skip=, ?end_series= and ?force= options of MarkComplete
+func completeOptions(r *http.Request, cascade bool) (model.CompleteOptions, *errorMessage) {
+	opts := model.CompleteOptions{}
+	var msg *errorMessage
+	if opts.Cascade, msg = cascadeOption(r, cascade); msg != nil {
+		return opts, msg
+	}
+
+	flags := []struct {
+		key   string
+		value *bool
+	}{
+		{key: "skip", value: &opts.Skip},
+		{key: "end_series", value: &opts.EndSeries},
+		{key: "force", value: &opts.Force},
+	}
+	for _, flag := range flags {
+		switch r.URL.Query().Get(flag.key) {
+		case "", "false":
+		case "true":
+			*flag.value = true
+		default:
+			return opts, &errorMessage{
+				Message: fmt.Sprintf("%s must be true or false", flag.key),
+			}
+		}
+	}
+	if opts.Skip && opts.EndSeries {
+		return opts, &errorMessage{
+			Message: "skip and end_series can not be used together",
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+func TestHandler_CreateRecurring(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "weekly", body: `{"name":"standup","due_at":"2023-06-05T09:00:00+02:00","recurrence":"FREQ=WEEKLY;BYDAY=MO"}`, status: http.StatusCreated},
+		{name: "without due date", body: `{"name":"standup","recurrence":"FREQ=WEEKLY"}`, status: http.StatusBadRequest},
+		{name: "invalid rule", body: `{"name":"standup","due_at":"2023-06-05T09:00:00+02:00","recurrence":"FREQ=HOURLY"}`, status: http.StatusBadRequest},
+		{name: "single occurrence", body: `{"name":"standup","due_at":"2023-06-05T09:00:00+02:00","recurrence":"FREQ=DAILY;COUNT=1"}`, status: http.StatusCreated},
+		{name: "leap day", body: `{"name":"party","due_at":"2024-02-29T20:00:00+02:00","recurrence":"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29"}`, status: http.StatusCreated},
+		{name: "never occurs", body: `{"name":"party","due_at":"2024-02-29T20:00:00+02:00","recurrence":"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/todolist", bytes.NewBufferString(tt.body))
+			h.Create().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.Create() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_MarkCompleteOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		err    error
+		status int
+	}{
+		{name: "complete", query: "", status: http.StatusOK},
+		{name: "skip", query: "?skip=true", status: http.StatusOK},
+		{name: "end series", query: "?end_series=true", status: http.StatusOK},
+		{name: "skip and end series", query: "?skip=true&end_series=true", status: http.StatusBadRequest},
+		{name: "invalid skip", query: "?skip=yes", status: http.StatusBadRequest},
+		{name: "skip a task without recurrence", query: "?skip=true", err: repo.ErrNotRecurring, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{err: tt.err}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "http://www.google.com/todolist/1"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.MarkComplete().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.MarkComplete() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
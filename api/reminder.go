@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/gorilla/mux"
+)
+
+// ReminderDAO is the reminder data access object
+type ReminderDAO interface {
+	CreateReminder(ctx context.Context, reminder *model.Reminder, userId string) (*model.Reminder, error)
+	FetchReminders(ctx context.Context, taskId int, userId string) ([]model.Reminder, error)
+	DeleteReminder(ctx context.Context, id int, userId string) error
+}
+
+// CreateReminder will add a reminder to a task, it fires at remind_at or offset_minutes from the due date
+func (h *Handler) CreateReminder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reminder := &model.Reminder{}
+		if err := json.NewDecoder(r.Body).Decode(reminder); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+		if msg := h.validateReminder(reminder); msg != nil {
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		reminder.TaskID, _ = strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.ReminderDAO.CreateReminder(r.Context(), reminder, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to create reminder",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusCreated, resp)
+	}
+}
+
+// Reminders will return the reminders of a task
+func (h *Handler) Reminders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		reminders, err := h.ReminderDAO.FetchReminders(r.Context(), id, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, reminders)
+	}
+}
+
+// DeleteReminder will remove a reminder
+func (h *Handler) DeleteReminder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+
+		if err := h.ReminderDAO.DeleteReminder(r.Context(), id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to delete reminder",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+// validateReminder checks the time and the channel of a reminder, an empty channel is email
+func (h *Handler) validateReminder(reminder *model.Reminder) *errorMessage {
+	if (reminder.RemindAt == nil) == (reminder.OffsetMinutes == nil) {
+		return &errorMessage{
+			Message: "Reminder must have either remind_at or offset_minutes",
+		}
+	}
+	if reminder.Channel == "" {
+		reminder.Channel = model.ChannelEmail
+	}
+	for _, channel := range h.ReminderChannels {
+		if channel == reminder.Channel {
+			return nil
+		}
+	}
+	return &errorMessage{
+		Message: "Channel must be one of " + strings.Join(h.ReminderChannels, ", "),
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/gorilla/mux"
+)
+
+type mockReminderDAO struct {
+	err error
+}
+
+func (m *mockReminderDAO) CreateReminder(ctx context.Context, reminder *model.Reminder, userId string) (*model.Reminder, error) {
+	reminder.ID = 1
+	return reminder, m.err
+}
+
+func (m *mockReminderDAO) FetchReminders(ctx context.Context, taskId int, userId string) ([]model.Reminder, error) {
+	return []model.Reminder{}, m.err
+}
+
+func (m *mockReminderDAO) DeleteReminder(ctx context.Context, id int, userId string) error {
+	return m.err
+}
+
+func TestHandler_CreateReminder(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{name: "at a time", body: `{"remind_at":"2023-06-05T08:30:00+02:00"}`, status: http.StatusCreated},
+		{name: "before the due date", body: `{"offset_minutes":-30,"channel":"log"}`, status: http.StatusCreated},
+		{name: "without a time", body: `{"channel":"email"}`, status: http.StatusBadRequest},
+		{name: "with both times", body: `{"remind_at":"2023-06-05T08:30:00+02:00","offset_minutes":-30}`, status: http.StatusBadRequest},
+		{name: "channel without notifier", body: `{"offset_minutes":-30,"channel":"webhook"}`, status: http.StatusBadRequest},
+		{name: "another user's task", body: `{"offset_minutes":-30}`, err: errors.New("No record found"), status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{ReminderDAO: &mockReminderDAO{err: tt.err}, ReminderChannels: []string{model.ChannelEmail, model.ChannelLog}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/todolist/1/reminders", bytes.NewBufferString(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.CreateReminder().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.CreateReminder() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
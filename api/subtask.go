@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/gorilla/mux"
+)
+
+type parentRequest struct {
+	ParentID *int `json:"parent_id"`
+}
+
+// SetParent will make a task a subtask of another task, a null parent_id makes it a top level task
+func (h *Handler) SetParent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &parentRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "json decode error",
+			}
+			StdResponse(w, http.StatusBadRequest, msg)
+			return
+		}
+
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.SetParent(r.Context(), id, req.ParentID, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to change parent",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// taskTree nests the subtasks in their parent task and counts the progress of every task with subtasks.
+// Tasks whose parent is not in tasks, ie because of a filter, are top level tasks.
+func taskTree(tasks []model.Task) []model.Task {
+	present := map[int]bool{}
+	for _, task := range tasks {
+		present[task.ID] = true
+	}
+
+	roots := []model.Task{}
+	children := map[int][]model.Task{}
+	for _, task := range tasks {
+		if task.ParentID != nil && present[*task.ParentID] {
+			children[*task.ParentID] = append(children[*task.ParentID], task)
+		} else {
+			roots = append(roots, task)
+		}
+	}
+
+	var build func(task model.Task) (model.Task, model.Progress)
+	build = func(task model.Task) (model.Task, model.Progress) {
+		progress := model.Progress{}
+		for _, child := range children[task.ID] {
+			subtask, sub := build(child)
+			task.Subtasks = append(task.Subtasks, subtask)
+			progress.Done += sub.Done
+			progress.Total += sub.Total
+			if child.Status != model.StatusCancelled {
+				progress.Total++
+			}
+			if child.Status == model.StatusDone {
+				progress.Done++
+			}
+		}
+		if len(task.Subtasks) > 0 {
+			task.Progress = &progress
+		}
+		return task, progress
+	}
+
+	for i := range roots {
+		roots[i], _ = build(roots[i])
+	}
+	return roots
+}
+
+// cascadeOption reads ?cascade=true or false, fallback is used when it is not set
+func cascadeOption(r *http.Request, fallback bool) (bool, *errorMessage) {
+	switch r.URL.Query().Get("cascade") {
+	case "":
+		return fallback, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, &errorMessage{
+			Message: "Cascade must be true or false",
+		}
+	}
+}
+
+// taskStatus maps the task errors of the repo to a http status
+func taskStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrParentNotFound), errors.Is(err, repo.ErrSiblingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrTaskCycle), errors.Is(err, repo.ErrNotRecurring), errors.Is(err, repo.ErrDependencyCycle),
+		errors.Is(err, repo.ErrNotSibling), errors.Is(err, repo.ErrRecurringDone):
+		return http.StatusBadRequest
+	case errors.Is(err, repo.ErrTaskBlocked):
+		return http.StatusConflict
+	default:
+		return listStatus(err)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/gorilla/mux"
+)
+
+func TestTaskTree(t *testing.T) {
+	one, two, five := 1, 2, 5
+	tasks := []model.Task{
+		{ID: 1, Name: "release", Status: model.StatusInProgress},
+		{ID: 2, Name: "write docs", ParentID: &one, Status: model.StatusTodo},
+		{ID: 3, Name: "changelog", ParentID: &two, Status: model.StatusDone},
+		{ID: 4, Name: "screenshots", ParentID: &two, Status: model.StatusCancelled},
+		{ID: 6, Name: "tag", ParentID: &one, Status: model.StatusDone},
+		{ID: 7, Name: "filtered out parent", ParentID: &five, Status: model.StatusTodo},
+	}
+
+	tree := taskTree(tasks)
+	if len(tree) != 2 || tree[0].ID != 1 || tree[1].ID != 7 {
+		t.Fatalf("taskTree() roots = %+v, want tasks 1 and 7", tree)
+	}
+
+	release := tree[0]
+	if len(release.Subtasks) != 2 || release.Subtasks[0].ID != 2 || len(release.Subtasks[0].Subtasks) != 2 {
+		t.Fatalf("taskTree() did not nest the subtasks %+v", release)
+	}
+	// write docs, changelog and tag count, the cancelled screenshots do not
+	if release.Progress == nil || *release.Progress != (model.Progress{Done: 2, Total: 3}) {
+		t.Errorf("progress of release = %+v, want 2/3", release.Progress)
+	}
+	if docs := release.Subtasks[0]; docs.Progress == nil || *docs.Progress != (model.Progress{Done: 1, Total: 1}) {
+		t.Errorf("progress of write docs = %+v, want 1/1", docs.Progress)
+	}
+	if tree[1].Progress != nil {
+		t.Errorf("a task without subtasks should not have a progress, got %+v", tree[1].Progress)
+	}
+}
+
+func TestHandler_DeleteCascade(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{name: "default", query: "", status: http.StatusNoContent},
+		{name: "move subtasks up", query: "?cascade=false", status: http.StatusNoContent},
+		{name: "invalid cascade", query: "?cascade=maybe", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "http://www.google.com/todolist/1"+tt.query, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.Delete().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.Delete() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/controller"
+	"github.com/gorilla/mux"
+)
+
+type purgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// Trash will return the tasks of the user in the trash
+func (h *Handler) Trash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.Trash(r.Context(), userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// Restore will take a task out of the trash and return it
+func (h *Handler) Restore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		resp, err := h.TodoListDAO.Restore(r.Context(), id, userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to restore task",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, resp)
+	}
+}
+
+// Purge will permanently delete a task in the trash
+func (h *Handler) Purge() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, _ := strconv.Atoi(vars["id"])
+		userID := controller.UserIDFromContext(r.Context())
+		if err := h.TodoListDAO.Purge(r.Context(), id, userID); err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "failed to purge task",
+			}
+			StdResponse(w, taskStatus(err), msg)
+			return
+		}
+		StdResponse(w, http.StatusNoContent, nil)
+	}
+}
+
+// EmptyTrash will permanently delete every task of the user in the trash
+func (h *Handler) EmptyTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := controller.UserIDFromContext(r.Context())
+		purged, err := h.TodoListDAO.EmptyTrash(r.Context(), userID)
+		if err != nil {
+			msg := &errorMessage{
+				Error:   err.Error(),
+				Message: "database error",
+			}
+			StdResponse(w, http.StatusInternalServerError, msg)
+			return
+		}
+		StdResponse(w, http.StatusOK, &purgeResponse{Purged: purged})
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/gorilla/mux"
+)
+
+func TestHandler_Restore(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "restored", status: http.StatusOK},
+		{name: "not in the trash", err: errors.New("No record found"), status: http.StatusNotFound},
+		{name: "database error", err: errors.New("connection refused"), status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{TodoListDAO: &mockTodoListDAO{err: tt.err}}
+			writer := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "http://www.google.com/trash/1/restore", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			h.Restore().ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("Handler.Restore() = %v, want %v", writer.Result().StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandler_EmptyTrash(t *testing.T) {
+	h := &Handler{TodoListDAO: &mockTodoListDAO{tasks: []model.Task{{ID: 1}, {ID: 2}}}}
+	writer := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "http://www.google.com/trash", nil)
+	h.EmptyTrash().ServeHTTP(writer, req)
+
+	if writer.Result().StatusCode != http.StatusOK {
+		t.Fatalf("Handler.EmptyTrash() = %v, want %v", writer.Result().StatusCode, http.StatusOK)
+	}
+	if body := writer.Body.String(); body != `{"purged":2}` {
+		t.Errorf("Handler.EmptyTrash() body = %s", body)
+	}
+}
@@ -1,21 +1,38 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	// the runtime image has no time zone database, recurring tasks need it for the timezone of the users
+	_ "time/tzdata"
 
 	"github.com/cfthoo/todo-app/api"
+	accountapi "github.com/cfthoo/todo-app/api/account"
+	adminapi "github.com/cfthoo/todo-app/api/admin"
 	oauth2api "github.com/cfthoo/todo-app/api/oauth"
+	"github.com/cfthoo/todo-app/pkg/config"
 	"github.com/cfthoo/todo-app/pkg/controller"
 	conn "github.com/cfthoo/todo-app/pkg/db"
+	"github.com/cfthoo/todo-app/pkg/db/model"
 	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/cfthoo/todo-app/pkg/jobs"
+	"github.com/cfthoo/todo-app/pkg/mailer"
+	"github.com/cfthoo/todo-app/pkg/oidc"
+	"github.com/cfthoo/todo-app/pkg/reminder"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	revokeUser := flag.String("revoke-user", "", "revoke every token of the given user id and exit")
+	grantAdmin := flag.String("grant-admin", "", "give the admin role to the given user id and exit")
+	flag.Parse()
+
 	fmt.Print("sas")
 	godotenv.Load(".env")
 	db, err := conn.ConnectDatabase()
@@ -27,27 +44,209 @@ func main() {
 	// db migration
 	conn.RunDBMigration(os.Getenv("MIGRATION_URL"), os.Getenv("DB_SOURCE"))
 
+	// server side key used to sign the todo-list tokens
+	if err := controller.SetupJWT(config.SetupJWTConfig()); err != nil {
+		log.Fatal(err)
+	}
+
+	// key used to sign the oauth state cookies
+	if err := controller.SetupCookies(config.SetupCookieConfig()); err != nil {
+		log.Fatal(err)
+	}
+
+	// optional cookie based browser sessions
+	if err := controller.SetupSessions(config.SetupSessionConfig()); err != nil {
+		log.Fatal(err)
+	}
+
+	// local email/password accounts, the verification and reset links are sent by email
+	if err := controller.SetupAccounts(config.SetupAccountConfig()); err != nil {
+		log.Fatal(err)
+	}
+	controller.Mailer = mailer.New(config.SetupMailerConfig())
+
+	// TOTP two-factor authentication for the login and sensitive actions
+	if err := controller.SetupTwoFactor(config.SetupTwoFactorConfig()); err != nil {
+		log.Fatal(err)
+	}
+
+	// device authorization flow for terminal clients
+	if err := controller.SetupDevice(config.SetupDeviceConfig()); err != nil {
+		log.Fatal(err)
+	}
+
+	controller.RefreshTokens = &repo.RefreshTokens{
+		DB: db,
+	}
+	controller.Revocations = &repo.Revocations{
+		DB: db,
+	}
+	controller.Users = &repo.Users{
+		DB: db,
+	}
+	controller.AccessTokens = &repo.AccessTokens{
+		DB: db,
+	}
+	controller.Accounts = &repo.Accounts{
+		DB: db,
+	}
+	controller.DeviceCodes = &repo.DeviceCodes{
+		DB: db,
+	}
+	controller.TwoFactors = &repo.TwoFactors{
+		DB: db,
+	}
+
+	// admin operation: revoke every token of a user and exit
+	if *revokeUser != "" {
+		if err := controller.RevokeUser(context.Background(), *revokeUser); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Revoked all tokens of user %s", *revokeUser)
+		return
+	}
+	// admin operation: make the first admin, later admins can be made with the admin api
+	if *grantAdmin != "" {
+		if err := controller.SetRole(context.Background(), "", *grantAdmin, controller.RoleAdmin); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("User %s is now an admin", *grantAdmin)
+		return
+	}
+	// reminders are sent in the background, their state is kept in the database
+	reminderConf := config.SetupReminderConfig()
+	notifiers := map[string]reminder.Notifier{
+		model.ChannelEmail: &reminder.Email{Mailer: controller.Mailer},
+		model.ChannelLog:   &reminder.Log{},
+	}
+	if reminderConf.WebhookURL != "" {
+		notifiers[model.ChannelWebhook] = reminder.NewWebhook(reminderConf.WebhookURL, reminderConf.WebhookSecret)
+	}
+	scheduler := reminder.NewScheduler(&repo.Reminders{DB: db}, notifiers, reminderConf)
+
+	// the background jobs stop with the server
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobs.Start(ctx, jobs.SendReminders(scheduler, reminderConf))
+
+	// expired deny-list entries are pruned every hour
+	jobs.Start(ctx, jobs.PruneRevocations(controller.Revocations, time.Hour))
+
+	// deleted tasks are purged from the trash after the retention
+	jobs.Start(ctx, jobs.PurgeTrash(&repo.TodoList{DB: db}, config.SetupTrashConfig()))
+
+	// done tasks are archived some days after their completion when it is configured
+	if archiveConf := config.SetupArchiveConfig(); archiveConf.AfterDays > 0 {
+		jobs.Start(ctx, jobs.AutoArchive(&repo.TodoList{DB: db}, archiveConf))
+	}
+
 	u := &api.Handler{
 		TodoListDAO: &repo.TodoList{
 			DB: db,
 		},
+		ListDAO: &repo.Lists{
+			DB: db,
+		},
+		LabelDAO: &repo.Labels{
+			DB: db,
+		},
+		ReminderDAO: &repo.Reminders{
+			DB: db,
+		},
+		ReminderChannels: scheduler.Channels(),
+		Subtasks:         *config.SetupSubtaskConfig(),
 	}
 
 	r := mux.NewRouter()
 
 	r.HandleFunc("/", homeHandler)
+	r.HandleFunc("/.well-known/jwks.json", controller.JWKSHandler)
 	r.HandleFunc("/google/login", oauth2api.LoginHandler)
 	r.HandleFunc("/google/callback", oauth2api.CallbackHandler)
 	r.HandleFunc("/fb/login", oauth2api.LoginHandler)
 	r.HandleFunc("/fb/callback", oauth2api.CallbackHandler)
 	r.HandleFunc("/github/login", oauth2api.LoginHandler)
 	r.HandleFunc("/github/callback", oauth2api.CallbackHandler)
-	r.Methods(http.MethodPost).Path("/todolist").Handler(controller.ValidateJWT(u.Create()))
-	r.Methods(http.MethodGet).Path("/todolist").Handler(controller.ValidateJWT(u.List()))
-	r.Methods(http.MethodGet).Path(fmt.Sprintf("/todolist/{%s}", "id")).Handler(controller.ValidateJWT(u.FetchByID()))
-	r.Methods(http.MethodPut).Path("/todolist").Handler(controller.ValidateJWT(u.Update()))
-	r.Methods(http.MethodPatch).Path(fmt.Sprintf("/todolist/{%s}", "id")).Handler(controller.ValidateJWT(u.MarkComplete()))
-	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/todolist/{%s}", "id")).Handler(controller.ValidateJWT(u.Delete()))
+	// generic OpenID Connect providers ie the company Keycloak/Dex
+	for _, conf := range config.SetupOIDCConfigs() {
+		provider, err := oidc.Discover(context.Background(), conf)
+		if err != nil {
+			log.Println("Skipping oidc provider:", err)
+			continue
+		}
+		oidc.Register(provider)
+		r.HandleFunc(fmt.Sprintf("/%s/login", provider.Name), oauth2api.LoginHandler)
+		r.HandleFunc(fmt.Sprintf("/%s/callback", provider.Name), oauth2api.CallbackHandler)
+		log.Printf("Registered oidc provider %s", provider.Name)
+	}
+	r.Methods(http.MethodPost).Path("/auth/register").HandlerFunc(accountapi.RegisterHandler)
+	r.Methods(http.MethodPost).Path("/auth/login").HandlerFunc(accountapi.LoginHandler)
+	r.Methods(http.MethodPost).Path("/auth/login/2fa").HandlerFunc(accountapi.LoginSecondFactorHandler)
+	r.Methods(http.MethodPost).Path("/auth/2fa/totp").Handler(controller.ValidateJWT(controller.RequireLogin(accountapi.EnrollTOTPHandler)))
+	r.Methods(http.MethodPost).Path("/auth/2fa/totp/confirm").Handler(controller.ValidateJWT(controller.RequireLogin(accountapi.ConfirmTOTPHandler)))
+	r.Methods(http.MethodDelete).Path("/auth/2fa/totp").Handler(controller.ValidateJWT(controller.RequireLogin(controller.RequireSecondFactor(accountapi.DisableTOTPHandler))))
+	r.Methods(http.MethodPost).Path("/auth/2fa/recovery-codes").Handler(controller.ValidateJWT(controller.RequireLogin(controller.RequireSecondFactor(accountapi.RecoveryCodesHandler))))
+	r.Methods(http.MethodPost).Path("/auth/2fa/verify").Handler(controller.ValidateJWT(controller.RequireLogin(accountapi.VerifySecondFactorHandler)))
+	r.Methods(http.MethodDelete).Path("/auth/account").Handler(controller.ValidateJWT(controller.RequireLogin(controller.RequireSecondFactor(accountapi.DeleteAccountHandler))))
+	r.Methods(http.MethodGet).Path("/auth/verify-email").HandlerFunc(accountapi.VerifyEmailHandler)
+	r.Methods(http.MethodPost).Path("/auth/verify-email").HandlerFunc(accountapi.ResendVerificationHandler)
+	r.Methods(http.MethodPost).Path("/auth/password").Handler(controller.ValidateJWT(controller.RequireLogin(accountapi.ChangePasswordHandler)))
+	r.Methods(http.MethodPut).Path("/auth/timezone").Handler(controller.ValidateJWT(controller.RequireLogin(accountapi.TimezoneHandler)))
+	r.Methods(http.MethodPost).Path("/auth/password/forgot").HandlerFunc(accountapi.ForgotPasswordHandler)
+	r.Methods(http.MethodPost).Path("/auth/password/reset").HandlerFunc(accountapi.ResetPasswordHandler)
+	r.Methods(http.MethodPost).Path("/auth/device/code").HandlerFunc(oauth2api.DeviceCodeHandler)
+	r.Methods(http.MethodGet).Path("/auth/device").HandlerFunc(oauth2api.DevicePageHandler)
+	r.Methods(http.MethodPost).Path("/auth/device").HandlerFunc(oauth2api.DeviceDenyHandler)
+	r.Methods(http.MethodPost).Path("/auth/token").HandlerFunc(oauth2api.TokenHandler)
+	r.Methods(http.MethodPost).Path("/auth/refresh").HandlerFunc(oauth2api.RefreshHandler)
+	r.Methods(http.MethodPost).Path("/auth/logout").Handler(controller.ValidateJWT(controller.RequireLogin(oauth2api.LogoutHandler)))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/auth/link/{%s}", "provider")).Handler(controller.ValidateJWT(controller.RequireLogin(oauth2api.LinkHandler)))
+	r.Methods(http.MethodGet).Path("/auth/identities").Handler(controller.ValidateJWT(controller.RequireLogin(oauth2api.IdentitiesHandler)))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/auth/identities/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireLogin(oauth2api.UnlinkHandler)))
+	r.Methods(http.MethodPost).Path("/auth/tokens").Handler(controller.ValidateJWT(controller.RequireLogin(controller.RequireSecondFactor(oauth2api.CreateTokenHandler))))
+	r.Methods(http.MethodGet).Path("/auth/tokens").Handler(controller.ValidateJWT(controller.RequireLogin(oauth2api.ListTokensHandler)))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/auth/tokens/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireLogin(oauth2api.RevokeTokenHandler)))
+	r.Methods(http.MethodPost).Path("/todolist").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.Create())))
+	r.Methods(http.MethodGet).Path("/todolist").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.List())))
+	r.Methods(http.MethodGet).Path(fmt.Sprintf("/todolist/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.FetchByID())))
+	r.Methods(http.MethodPut).Path("/todolist").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.Update())))
+	r.Methods(http.MethodPatch).Path(fmt.Sprintf("/todolist/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.MarkComplete())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/todolist/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksDelete, u.Delete())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/todolist/{%s}/archive", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.Archive())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/todolist/{%s}/archive", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.Unarchive())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/lists/{%s}/archive", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.ArchiveCompleted())))
+	r.Methods(http.MethodGet).Path("/trash").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.Trash())))
+	r.Methods(http.MethodDelete).Path("/trash").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksDelete, u.EmptyTrash())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/trash/{%s}/restore", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.Restore())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/trash/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksDelete, u.Purge())))
+	r.Methods(http.MethodPut).Path(fmt.Sprintf("/todolist/{%s}/list", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.MoveTask())))
+	r.Methods(http.MethodPost).Path("/lists").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.CreateList())))
+	r.Methods(http.MethodGet).Path("/lists").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.Lists())))
+	r.Methods(http.MethodGet).Path(fmt.Sprintf("/lists/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.FetchList())))
+	r.Methods(http.MethodPut).Path(fmt.Sprintf("/lists/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.UpdateList())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/lists/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksDelete, u.DeleteList())))
+	r.Methods(http.MethodGet).Path(fmt.Sprintf("/lists/{%s}/plan", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.Plan())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/todolist/{%s}/move", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.Reorder())))
+	r.Methods(http.MethodPut).Path(fmt.Sprintf("/todolist/{%s}/parent", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.SetParent())))
+	r.Methods(http.MethodPut).Path(fmt.Sprintf("/todolist/{%s}/labels", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.SetTaskLabels())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/todolist/{%s}/blockers", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.AddBlocker())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/todolist/{%s}/blockers/{%s}", "id", "blocker_id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.RemoveBlocker())))
+	r.Methods(http.MethodGet).Path(fmt.Sprintf("/todolist/{%s}/completions", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.Completions())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/todolist/{%s}/reminders", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.CreateReminder())))
+	r.Methods(http.MethodGet).Path(fmt.Sprintf("/todolist/{%s}/reminders", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.Reminders())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/reminders/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksDelete, u.DeleteReminder())))
+	r.Methods(http.MethodPost).Path("/labels").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.CreateLabel())))
+	r.Methods(http.MethodGet).Path("/labels").Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksRead, u.Labels())))
+	r.Methods(http.MethodPut).Path(fmt.Sprintf("/labels/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.UpdateLabel())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/labels/{%s}/merge", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksWrite, u.MergeLabel())))
+	r.Methods(http.MethodDelete).Path(fmt.Sprintf("/labels/{%s}", "id")).Handler(controller.ValidateJWT(controller.RequireScope(controller.ScopeTasksDelete, u.DeleteLabel())))
+	// admin api, the role is checked on every request and changes need a fresh second factor
+	r.Methods(http.MethodGet).Path("/admin/users").Handler(controller.ValidateJWT(controller.RequireRole(controller.RoleAdmin, adminapi.ListUsersHandler)))
+	r.Methods(http.MethodGet).Path(fmt.Sprintf("/admin/users/{%s}/tasks", "id")).Handler(controller.ValidateJWT(controller.RequireRole(controller.RoleAdmin, u.UserTasks())))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/admin/users/{%s}/disable", "id")).Handler(controller.ValidateJWT(controller.RequireRole(controller.RoleAdmin, controller.RequireSecondFactor(adminapi.DisableUserHandler))))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/admin/users/{%s}/enable", "id")).Handler(controller.ValidateJWT(controller.RequireRole(controller.RoleAdmin, controller.RequireSecondFactor(adminapi.EnableUserHandler))))
+	r.Methods(http.MethodPut).Path(fmt.Sprintf("/admin/users/{%s}/role", "id")).Handler(controller.ValidateJWT(controller.RequireRole(controller.RoleAdmin, controller.RequireSecondFactor(adminapi.SetRoleHandler))))
+	r.Methods(http.MethodPost).Path(fmt.Sprintf("/admin/users/{%s}/revoke", "id")).Handler(controller.ValidateJWT(controller.RequireRole(controller.RoleAdmin, controller.RequireSecondFactor(adminapi.RevokeSessionsHandler))))
 	// Start the HTTP server
 	addr := ":8080"
 	log.Printf("Server listening on %s", addr)
@@ -59,12 +258,17 @@ func main() {
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
+	oidcLinks := ""
+	for _, provider := range oidc.Providers() {
+		oidcLinks += fmt.Sprintf(`
+                <a href="/%s/login">%s Log In</a><br>`, provider.Name, provider.Name)
+	}
 	var html = `
         <html>
             <body>
                 <a href="/google/login">Google Log In</a><br>
                 <a href="/fb/login">Facebook Log In</a><br>
-                <a href="/github/login">Github Log In</a><br>
+                <a href="/github/login">Github Log In</a><br>` + oidcLinks + `
             </body>
         </html>`
 	fmt.Fprint(w, html)
@@ -9,6 +9,8 @@ require (
 	github.com/gorilla/mux v1.8.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.8.0
+	golang.org/x/crypto v0.8.0
 	golang.org/x/oauth2 v0.7.0
 )
 
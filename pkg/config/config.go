@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/facebook"
@@ -54,3 +57,279 @@ func SetupGithubConfig() *oauth2.Config {
 	}
 	return conf
 }
+
+// JWTConfig holds the settings used to sign and validate todo-list tokens
+type JWTConfig struct {
+	// Algorithm is one of HS256, RS256 or ES256
+	Algorithm string
+	// Secret is the HMAC key, only used with HS256
+	Secret   []byte
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// RefreshTTL is how long a refresh token can be used to get a new access token
+	RefreshTTL time.Duration
+	// KeysDir is where RS256/ES256 private keys are loaded from and persisted to
+	KeysDir string
+	// KeyRotation is how often a new RS256/ES256 signing key is generated, 0 disables rotation
+	KeyRotation time.Duration
+}
+
+func SetupJWTConfig() *JWTConfig {
+	conf := &JWTConfig{
+		Algorithm:   getEnv("JWT_ALGORITHM", "HS256"),
+		Secret:      []byte(os.Getenv("JWT_SECRET")),
+		Issuer:      getEnv("JWT_ISSUER", "todo-list"),
+		Audience:    getEnv("JWT_AUDIENCE", "todo-list"),
+		TTL:         getDurationEnv("JWT_TTL", time.Hour),
+		RefreshTTL:  getDurationEnv("JWT_REFRESH_TTL", 30*24*time.Hour),
+		KeysDir:     os.Getenv("JWT_KEYS_DIR"),
+		KeyRotation: getDurationEnv("JWT_KEY_ROTATION", 0),
+	}
+	return conf
+}
+
+// getEnv returns the value of the environment variable key or fallback when it is unset
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDurationEnv parses the environment variable key as a time.Duration ie 1h or 15m
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// getIntEnv parses the environment variable key as a positive number
+func getIntEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// CookieConfig holds the settings of the cookies set by the server
+type CookieConfig struct {
+	// Secret is the HMAC key used to sign cookie values
+	Secret []byte
+	// Secure limits the cookies to https, only disable it for local development
+	Secure bool
+}
+
+func SetupCookieConfig() *CookieConfig {
+	conf := &CookieConfig{
+		Secret: []byte(os.Getenv("COOKIE_SECRET")),
+		Secure: getEnv("COOKIE_SECURE", "true") != "false",
+	}
+	return conf
+}
+
+// SessionConfig holds the settings of cookie based browser sessions
+type SessionConfig struct {
+	// Enabled makes the login set a session cookie and redirect instead of returning the tokens
+	Enabled bool
+	// RedirectURL is where the browser is sent after the login set the session cookie
+	RedirectURL string
+	// SameSite of the session cookies, lax or strict
+	SameSite string
+}
+
+func SetupSessionConfig() *SessionConfig {
+	conf := &SessionConfig{
+		Enabled:     getEnv("SESSION_COOKIES", "false") == "true",
+		RedirectURL: getEnv("SESSION_REDIRECT_URL", "/"),
+		SameSite:    strings.ToLower(getEnv("SESSION_SAME_SITE", "lax")),
+	}
+	return conf
+}
+
+// OIDCConfig holds the settings of a generic OpenID Connect provider
+type OIDCConfig struct {
+	// Name is used in the login and callback paths ie /keycloak/login
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// SetupOIDCConfigs reads the providers listed in OIDC_PROVIDERS. For OIDC_PROVIDERS=keycloak the
+// settings are read from OIDC_KEYCLOAK_ISSUER, OIDC_KEYCLOAK_CLIENT_ID, OIDC_KEYCLOAK_CLIENT_SECRET
+// and the optional space separated OIDC_KEYCLOAK_SCOPES
+func SetupOIDCConfigs() []*OIDCConfig {
+	confs := []*OIDCConfig{}
+	baseUrl := strings.TrimSuffix(getEnv("BASE_URL", "http://localhost:8080"), "/")
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		conf := &OIDCConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  baseUrl + "/" + name + "/callback",
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		confs = append(confs, conf)
+	}
+	return confs
+}
+
+// AccountConfig holds the settings of local email/password accounts
+type AccountConfig struct {
+	// BaseURL is used for the links sent by email
+	BaseURL string
+	// VerificationTTL is how long an email verification link can be used
+	VerificationTTL time.Duration
+	// ResetTTL is how long a password reset link can be used
+	ResetTTL time.Duration
+}
+
+func SetupAccountConfig() *AccountConfig {
+	conf := &AccountConfig{
+		BaseURL:         strings.TrimSuffix(getEnv("BASE_URL", "http://localhost:8080"), "/"),
+		VerificationTTL: getDurationEnv("ACCOUNT_VERIFICATION_TTL", 24*time.Hour),
+		ResetTTL:        getDurationEnv("ACCOUNT_RESET_TTL", time.Hour),
+	}
+	return conf
+}
+
+// MailerConfig holds the SMTP settings used to send email, mails are only logged when Host is unset
+type MailerConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func SetupMailerConfig() *MailerConfig {
+	conf := &MailerConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     getEnv("SMTP_PORT", "587"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     getEnv("MAIL_FROM", "todo-list@localhost"),
+	}
+	return conf
+}
+
+// DeviceConfig holds the settings of the device authorization flow used by terminal clients
+type DeviceConfig struct {
+	// VerificationURL is the page where the user enters the code shown on the device
+	VerificationURL string
+	// CodeTTL is how long the user has to approve the device
+	CodeTTL time.Duration
+	// Interval is the minimum time between two polls of the device
+	Interval time.Duration
+}
+
+func SetupDeviceConfig() *DeviceConfig {
+	conf := &DeviceConfig{
+		VerificationURL: strings.TrimSuffix(getEnv("BASE_URL", "http://localhost:8080"), "/") + "/auth/device",
+		CodeTTL:         getDurationEnv("DEVICE_CODE_TTL", 10*time.Minute),
+		Interval:        getDurationEnv("DEVICE_POLL_INTERVAL", 5*time.Second),
+	}
+	return conf
+}
+
+// TwoFactorConfig holds the settings of TOTP two-factor authentication
+type TwoFactorConfig struct {
+	// Issuer is the name shown next to the code in the authenticator app
+	Issuer string
+	// Freshness is how long after the last code sensitive actions are allowed without a new one
+	Freshness time.Duration
+}
+
+func SetupTwoFactorConfig() *TwoFactorConfig {
+	conf := &TwoFactorConfig{
+		Issuer:    getEnv("TOTP_ISSUER", "todo-list"),
+		Freshness: getDurationEnv("MFA_FRESHNESS", 10*time.Minute),
+	}
+	return conf
+}
+
+// SubtaskConfig holds what happens to the subtasks of a task, a request can override it with ?cascade=
+type SubtaskConfig struct {
+	// CascadeComplete also completes the open subtasks of a completed task
+	CascadeComplete bool
+	// CascadeDelete also deletes the subtasks of a deleted task, otherwise they are moved up to its parent
+	CascadeDelete bool
+}
+
+func SetupSubtaskConfig() *SubtaskConfig {
+	conf := &SubtaskConfig{
+		CascadeComplete: getEnv("SUBTASKS_COMPLETE_CASCADE", "false") == "true",
+		CascadeDelete:   getEnv("SUBTASKS_DELETE_CASCADE", "true") != "false",
+	}
+	return conf
+}
+
+// ReminderConfig holds the settings of the reminder scheduler and of its webhook notifier
+type ReminderConfig struct {
+	// Interval is the time between two runs of the scheduler
+	Interval time.Duration
+	// BatchSize is the maximum number of reminders sent by a run
+	BatchSize int
+	// MaxAttempts is how many times a failed reminder is sent before it is given up
+	MaxAttempts int
+	// WebhookURL receives the reminders of the webhook channel, the channel is disabled when it is unset
+	WebhookURL string
+	// WebhookSecret signs the webhook body in the X-Todo-Signature header when it is set
+	WebhookSecret string
+}
+
+func SetupReminderConfig() *ReminderConfig {
+	conf := &ReminderConfig{
+		Interval:      getDurationEnv("REMINDER_INTERVAL", 30*time.Second),
+		BatchSize:     getIntEnv("REMINDER_BATCH_SIZE", 100),
+		MaxAttempts:   getIntEnv("REMINDER_MAX_ATTEMPTS", 5),
+		WebhookURL:    os.Getenv("REMINDER_WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("REMINDER_WEBHOOK_SECRET"),
+	}
+	return conf
+}
+
+// TrashConfig holds how long deleted tasks are kept in the trash
+type TrashConfig struct {
+	// Retention is how long a task stays in the trash before it is purged
+	Retention time.Duration
+	// Interval is the time between two purges of the trash
+	Interval time.Duration
+}
+
+func SetupTrashConfig() *TrashConfig {
+	conf := &TrashConfig{
+		Retention: getDurationEnv("TRASH_RETENTION", 30*24*time.Hour),
+		Interval:  getDurationEnv("TRASH_PURGE_INTERVAL", time.Hour),
+	}
+	return conf
+}
+
+// ArchiveConfig holds when the done tasks are archived automatically
+type ArchiveConfig struct {
+	// AfterDays is how many days after their completion done tasks are archived, 0 disables it
+	AfterDays int
+	// Interval is the time between two runs of the automatic archiving
+	Interval time.Duration
+}
+
+func SetupArchiveConfig() *ArchiveConfig {
+	conf := &ArchiveConfig{
+		AfterDays: getIntEnv("ARCHIVE_AFTER_DAYS", 0),
+		Interval:  getDurationEnv("ARCHIVE_INTERVAL", time.Hour),
+	}
+	return conf
+}
@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/mailer"
+	"github.com/cfthoo/todo-app/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LocalProvider is the provider claim of tokens issued by a password login
+const LocalProvider = "local"
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes
+	maxPasswordLength = 72
+)
+
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email or a wrong password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrEmailNotVerified is returned when logging in before the email is verified
+	ErrEmailNotVerified = errors.New("email is not verified, please check your email")
+	// ErrInvalidAccountToken is returned for unknown, used or expired verification and reset tokens
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
+	// ErrInvalidEmail is returned for malformed email addresses
+	ErrInvalidEmail = errors.New("invalid email")
+	// ErrInvalidPassword is returned for passwords that are too short or too long
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+// AccountStore is the local account data access object
+type AccountStore interface {
+	CreateAccount(ctx context.Context, email string, passwordHash string) (*model.Account, error)
+	FetchAccount(ctx context.Context, email string) (*model.Account, error)
+	FetchAccountByUser(ctx context.Context, userId int) (*model.Account, error)
+	FetchAccountByIdentity(ctx context.Context, identityId int) (*model.Account, error)
+	UpdatePassword(ctx context.Context, identityId int, passwordHash string) error
+	VerifyEmail(ctx context.Context, identityId int) error
+	CreateAccountToken(ctx context.Context, token *model.AccountToken) error
+	UseAccountToken(ctx context.Context, purpose string, tokenHash string) (*model.AccountToken, error)
+}
+
+// Accounts stores the local accounts, it is set in main
+var Accounts AccountStore
+
+// Mailer sends the verification and password reset email, it is set in main
+var Mailer mailer.Mailer
+
+// accountConfig holds the link base url and token lifetimes set by SetupAccounts
+var accountConfig *config.AccountConfig
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// SetupAccounts sets the configuration of local accounts
+func SetupAccounts(conf *config.AccountConfig) error {
+	if conf == nil {
+		return errors.New("account config can not be nil")
+	}
+	accountConfig = conf
+	return nil
+}
+
+// Register creates a local account and sends the email verification link. When the email already
+// has an account its owner is emailed instead, the caller is not told whether the email was free.
+func Register(ctx context.Context, email string, password string) error {
+	if err := accountsConfigured(); err != nil {
+		return err
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	account, err := Accounts.CreateAccount(ctx, email, hash)
+	if err != nil {
+		existing, fetchErr := Accounts.FetchAccount(ctx, email)
+		if fetchErr != nil {
+			return err
+		}
+		if err := sendAccountExistsEmail(ctx, existing); err != nil {
+			log.Println("Failed to send account exists email:", err)
+		}
+		return nil
+	}
+	if err := sendVerificationEmail(ctx, account); err != nil {
+		// the account is created, the link can be sent again
+		log.Println("Failed to send verification email:", err)
+	}
+	return nil
+}
+
+// Login checks the password of a verified account and issues the same tokens as a provider login.
+// Accounts with two-factor authentication get a *SecondFactorChallenge error to answer with CompleteLogin.
+func Login(ctx context.Context, email string, password string) (*TokenPair, error) {
+	if err := accountsConfigured(); err != nil {
+		return nil, err
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	account, err := Accounts.FetchAccount(ctx, email)
+	if err != nil {
+		// compare anyway so unknown emails take as long as wrong passwords
+		_ = bcrypt.CompareHashAndPassword(getDummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if account.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	return IssueLoginTokens(ctx, strconv.Itoa(account.UserID), LocalProvider)
+}
+
+// ChangePassword replaces the password of userId after checking the current one. Every
+// session of the user is revoked, personal access tokens keep working.
+func ChangePassword(ctx context.Context, userId string, currentPassword string, newPassword string) error {
+	if err := accountsConfigured(); err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+
+	account, err := Accounts.FetchAccountByUser(ctx, id)
+	if err != nil {
+		return errors.New("user has no password login")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(currentPassword)); err != nil {
+		return ErrInvalidCredentials
+	}
+	hash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := Accounts.UpdatePassword(ctx, account.IdentityID, hash); err != nil {
+		return err
+	}
+	return RevokeUser(ctx, userId)
+}
+
+// ResendVerification sends the email verification link again. Nothing is sent for
+// unknown or already verified emails, the caller is not told which.
+func ResendVerification(ctx context.Context, email string) error {
+	if err := accountsConfigured(); err != nil {
+		return err
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	account, err := Accounts.FetchAccount(ctx, email)
+	if err != nil || account.EmailVerifiedAt != nil {
+		return nil
+	}
+	return sendVerificationEmail(ctx, account)
+}
+
+// VerifyEmail marks the email of the account of a verification token as verified
+func VerifyEmail(ctx context.Context, token string) error {
+	if err := accountsConfigured(); err != nil {
+		return err
+	}
+	used, err := Accounts.UseAccountToken(ctx, purposeVerifyEmail, hashToken(token))
+	if err != nil {
+		return ErrInvalidAccountToken
+	}
+	return Accounts.VerifyEmail(ctx, used.IdentityID)
+}
+
+// RequestPasswordReset emails a password reset link. Nothing is sent for unknown
+// emails, the caller is not told whether the email has an account.
+func RequestPasswordReset(ctx context.Context, email string) error {
+	if err := accountsConfigured(); err != nil {
+		return err
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	account, err := Accounts.FetchAccount(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := createAccountToken(ctx, account, purposeResetPassword, accountConfig.ResetTTL)
+	if err != nil {
+		return err
+	}
+	return Mailer.Send(ctx, &mailer.Message{
+		To:      account.Email,
+		Subject: "Reset your todo-list password",
+		Body: fmt.Sprintf("Somebody asked to reset the password of your todo-list account.\n\n"+
+			"To choose a new password, send the token below to %s/auth/password/reset within %s:\n\n%s\n\n"+
+			"If it was not you, you can ignore this email.\n",
+			accountConfig.BaseURL, accountConfig.ResetTTL, token),
+	})
+}
+
+// ResetPassword sets a new password with a reset token and revokes every token of the user.
+// Receiving the reset email proves the email, so it is marked as verified as well.
+func ResetPassword(ctx context.Context, token string, password string) error {
+	if err := accountsConfigured(); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	used, err := Accounts.UseAccountToken(ctx, purposeResetPassword, hashToken(token))
+	if err != nil {
+		return ErrInvalidAccountToken
+	}
+	account, err := Accounts.FetchAccountByIdentity(ctx, used.IdentityID)
+	if err != nil {
+		return ErrInvalidAccountToken
+	}
+	if err := Accounts.UpdatePassword(ctx, account.IdentityID, hash); err != nil {
+		return err
+	}
+	if err := Accounts.VerifyEmail(ctx, account.IdentityID); err != nil {
+		return err
+	}
+	return RevokeUser(ctx, strconv.Itoa(account.UserID))
+}
+
+// sendAccountExistsEmail tells the owner of account that somebody registered with their email again
+func sendAccountExistsEmail(ctx context.Context, account *model.Account) error {
+	return Mailer.Send(ctx, &mailer.Message{
+		To:      account.Email,
+		Subject: "Your todo-list account",
+		Body: fmt.Sprintf("Somebody tried to register a todo-list account with this email, but it already has one.\n\n"+
+			"You can login at %s/auth/login, or ask for a password reset at %s/auth/password/forgot if you forgot the password.\n\n"+
+			"If it was not you, you can ignore this email.\n",
+			accountConfig.BaseURL, accountConfig.BaseURL),
+	})
+}
+
+// sendVerificationEmail emails a new verification link for account
+func sendVerificationEmail(ctx context.Context, account *model.Account) error {
+	token, err := createAccountToken(ctx, account, purposeVerifyEmail, accountConfig.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	return Mailer.Send(ctx, &mailer.Message{
+		To:      account.Email,
+		Subject: "Verify your todo-list email",
+		Body: fmt.Sprintf("Welcome to todo-list!\n\nPlease open the link below within %s to verify your email:\n\n%s/auth/verify-email?token=%s\n",
+			accountConfig.VerificationTTL, accountConfig.BaseURL, url.QueryEscape(token)),
+	})
+}
+
+// createAccountToken stores the hash of a new single use token and returns the token
+func createAccountToken(ctx context.Context, account *model.Account, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	err = Accounts.CreateAccountToken(ctx, &model.AccountToken{
+		IdentityID: account.IdentityID,
+		Purpose:    purpose,
+		TokenHash:  hashToken(token),
+		ExpiresAt:  time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// accountsConfigured checks that main set up everything local accounts need
+func accountsConfigured() error {
+	if Accounts == nil || Mailer == nil || accountConfig == nil {
+		return errors.New("local accounts are not configured")
+	}
+	return nil
+}
+
+// normalizeEmail validates email and returns it lower cased
+func normalizeEmail(email string) (string, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+// hashPassword checks the password length and returns its bcrypt hash
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("%w, it must be at least %d characters", ErrInvalidPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w, it can not be longer than %d bytes", ErrInvalidPassword, maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// getDummyHash returns a bcrypt hash compared against when the email is unknown
+func getDummyHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("todo-list-dummy-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
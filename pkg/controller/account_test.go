@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/mailer"
+)
+
+type mockAccountStore struct {
+	mu       sync.Mutex
+	accounts []*model.Account
+	tokens   []*model.AccountToken
+}
+
+func (m *mockAccountStore) CreateAccount(ctx context.Context, email string, passwordHash string) (*model.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, account := range m.accounts {
+		if account.Email == email {
+			return nil, errors.New("an account with this email already exists")
+		}
+	}
+	account := &model.Account{
+		IdentityID:   len(m.accounts) + 1,
+		UserID:       len(m.accounts) + 100,
+		Email:        email,
+		PasswordHash: passwordHash,
+	}
+	m.accounts = append(m.accounts, account)
+	copied := *account
+	return &copied, nil
+}
+
+func (m *mockAccountStore) find(match func(a *model.Account) bool) (*model.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, account := range m.accounts {
+		if match(account) {
+			copied := *account
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("No record found")
+}
+
+func (m *mockAccountStore) FetchAccount(ctx context.Context, email string) (*model.Account, error) {
+	return m.find(func(a *model.Account) bool { return a.Email == email })
+}
+
+func (m *mockAccountStore) FetchAccountByUser(ctx context.Context, userId int) (*model.Account, error) {
+	return m.find(func(a *model.Account) bool { return a.UserID == userId })
+}
+
+func (m *mockAccountStore) FetchAccountByIdentity(ctx context.Context, identityId int) (*model.Account, error) {
+	return m.find(func(a *model.Account) bool { return a.IdentityID == identityId })
+}
+
+func (m *mockAccountStore) UpdatePassword(ctx context.Context, identityId int, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounts[identityId-1].PasswordHash = passwordHash
+	return nil
+}
+
+func (m *mockAccountStore) VerifyEmail(ctx context.Context, identityId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.accounts[identityId-1].EmailVerifiedAt = &now
+	return nil
+}
+
+func (m *mockAccountStore) CreateAccountToken(ctx context.Context, token *model.AccountToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token.ID = len(m.tokens) + 1
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *mockAccountStore) UseAccountToken(ctx context.Context, purpose string, tokenHash string) (*model.AccountToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash && token.Purpose == purpose && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			token.UsedAt = &now
+			return token, nil
+		}
+	}
+	return nil, errors.New("No record found")
+}
+
+// setupTestAccounts sets up local accounts with in memory stores and returns the mailer
+func setupTestAccounts(t *testing.T) *mailer.Memory {
+	setupTestJWT(t)
+	if err := SetupAccounts(&config.AccountConfig{
+		BaseURL:         "http://localhost:8080",
+		VerificationTTL: time.Hour,
+		ResetTTL:        time.Hour,
+	}); err != nil {
+		t.Fatalf("failed to setup accounts: %v", err)
+	}
+	memory := &mailer.Memory{}
+	Mailer = memory
+	Accounts = &mockAccountStore{}
+	RefreshTokens = &mockRefreshTokenStore{}
+	Revocations = newMockRevocationStore()
+	t.Cleanup(func() { Revocations = nil })
+	return memory
+}
+
+var mailedToken = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// lastMailedToken returns the token of the latest email sent to the address to
+func lastMailedToken(t *testing.T, memory *mailer.Memory, to string) string {
+	msg, ok := memory.Last(to)
+	if !ok {
+		t.Fatalf("no email sent to %s", to)
+	}
+	token := mailedToken.FindString(msg.Body)
+	if token == "" {
+		t.Fatalf("no token in email %q", msg.Body)
+	}
+	return token
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	memory := setupTestAccounts(t)
+	ctx := context.Background()
+
+	if err := Register(ctx, "not-an-email", "password123"); !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("Register() with invalid email error = %v, want %v", err, ErrInvalidEmail)
+	}
+	if err := Register(ctx, "me@example.com", "short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Register() with short password error = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	if err := Register(ctx, " Me@Example.com ", "password123"); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	if _, err := Login(ctx, "me@example.com", "password123"); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("Login() before verification error = %v, want %v", err, ErrEmailNotVerified)
+	}
+
+	token := lastMailedToken(t, memory, "me@example.com")
+
+	// registering the email again looks the same to the caller, the owner is emailed
+	if err := Register(ctx, "me@example.com", "another-password"); err != nil {
+		t.Errorf("Register() with a taken email should not fail: %v", err)
+	}
+	msg, _ := memory.Last("me@example.com")
+	if !strings.Contains(msg.Body, "already has one") {
+		t.Errorf("Register() with a taken email sent %q", msg.Body)
+	}
+
+	if err := VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("failed to verify email: %v", err)
+	}
+	if err := VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Errorf("VerifyEmail() with used token error = %v, want %v", err, ErrInvalidAccountToken)
+	}
+
+	if _, err := Login(ctx, "me@example.com", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with wrong password error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, err := Login(ctx, "nobody@example.com", "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with unknown email error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	tokens, err := Login(ctx, "ME@example.com", "password123")
+	if err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+	claims, err := ParseJWT(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse login token: %v", err)
+	}
+	if claims.Subject != "100" || claims.Provider != LocalProvider {
+		t.Errorf("Login() token sub = %v provider = %v", claims.Subject, claims.Provider)
+	}
+}
+
+func TestPasswordChangeAndReset(t *testing.T) {
+	memory := setupTestAccounts(t)
+	ctx := context.Background()
+
+	_ = Register(ctx, "me@example.com", "password123")
+
+	if err := ChangePassword(ctx, "100", "wrong-password", "new-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("ChangePassword() with wrong password error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if err := ChangePassword(ctx, "100", "password123", "new-password"); err != nil {
+		t.Fatalf("failed to change password: %v", err)
+	}
+
+	if err := RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("RequestPasswordReset() of unknown email should not fail: %v", err)
+	}
+	if _, ok := memory.Last("nobody@example.com"); ok {
+		t.Errorf("RequestPasswordReset() should not email unknown addresses")
+	}
+
+	if err := RequestPasswordReset(ctx, "me@example.com"); err != nil {
+		t.Fatalf("failed to request password reset: %v", err)
+	}
+	token := lastMailedToken(t, memory, "me@example.com")
+	if err := ResetPassword(ctx, token, "short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("ResetPassword() with short password error = %v, want %v", err, ErrInvalidPassword)
+	}
+	if err := ResetPassword(ctx, token, "reset-password"); err != nil {
+		t.Fatalf("failed to reset password: %v", err)
+	}
+	if err := ResetPassword(ctx, token, "another-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Errorf("ResetPassword() with used token error = %v, want %v", err, ErrInvalidAccountToken)
+	}
+
+	// the reset link proves the email, so the account can login right away
+	if _, err := Login(ctx, "me@example.com", "new-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with old password error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, err := Login(ctx, "me@example.com", "reset-password"); err != nil {
+		t.Errorf("Login() with reset password failed: %v", err)
+	}
+}
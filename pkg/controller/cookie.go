@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+// cookieConfig holds the cookie signing key set by SetupCookies
+var cookieConfig *config.CookieConfig
+
+// ErrInvalidCookie is returned for missing, tampered or expired signed cookies and values
+var ErrInvalidCookie = errors.New("invalid cookie")
+
+// SetupCookies sets the key used to sign cookie values. When no key is
+// configured a random one is used, so signed cookies do not survive a restart.
+func SetupCookies(conf *config.CookieConfig) error {
+	if conf == nil {
+		return errors.New("cookie config can not be nil")
+	}
+	if len(conf.Secret) < minSecretLength {
+		log.Println("COOKIE_SECRET is not set or too short, using a random cookie key")
+		conf.Secret = make([]byte, minSecretLength)
+		if _, err := rand.Read(conf.Secret); err != nil {
+			return err
+		}
+	}
+	cookieConfig = conf
+	return nil
+}
+
+// SetSignedCookie sets an HttpOnly cookie whose value is signed and expires after maxAge
+func SetSignedCookie(w http.ResponseWriter, name string, value string, maxAge time.Duration) error {
+	signed, err := SignValue(name, value, maxAge)
+	if err != nil {
+		return err
+	}
+
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    signed,
+		Path:     "/",
+		Expires:  time.Now().Add(maxAge),
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   cookieConfig.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// ReadSignedCookie returns the value of a cookie set by SetSignedCookie
+func ReadSignedCookie(r *http.Request, name string) (string, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	return VerifyValue(name, cookie.Value)
+}
+
+// SignValue signs value for purpose, it can be read back with VerifyValue until maxAge passed
+func SignValue(purpose string, value string, maxAge time.Duration) (string, error) {
+	if cookieConfig == nil {
+		return "", errors.New("cookies are not configured")
+	}
+
+	expires := time.Now().Add(maxAge)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signCookie(purpose, payload), nil
+}
+
+// VerifyValue returns the value of a string signed by SignValue for the same purpose
+func VerifyValue(purpose string, signed string) (string, error) {
+	if cookieConfig == nil {
+		return "", errors.New("cookies are not configured")
+	}
+
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidCookie
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signCookie(purpose, payload))) {
+		return "", ErrInvalidCookie
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return "", ErrInvalidCookie
+	}
+	value, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	return string(value), nil
+}
+
+// ClearCookie removes a cookie from the browser
+func ClearCookie(w http.ResponseWriter, name string) {
+	secure := cookieConfig != nil && cookieConfig.Secure
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// signCookie returns the HMAC of the purpose ie the cookie name and the payload
+func signCookie(purpose string, payload string) string {
+	mac := hmac.New(sha256.New, cookieConfig.Secret)
+	mac.Write([]byte(purpose + "|" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
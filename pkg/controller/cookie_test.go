@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+func TestSignedCookie(t *testing.T) {
+	if err := SetupCookies(&config.CookieConfig{Secret: []byte("a-test-cookie-secret-long-enough!!")}); err != nil {
+		t.Fatalf("failed to setup cookies: %v", err)
+	}
+
+	writer := httptest.NewRecorder()
+	if err := SetSignedCookie(writer, "oauth_state", "some value", time.Minute); err != nil {
+		t.Fatalf("failed to set cookie: %v", err)
+	}
+	cookie := writer.Result().Cookies()[0]
+	if !cookie.HttpOnly {
+		t.Errorf("expected the cookie to be HttpOnly")
+	}
+
+	tests := []struct {
+		name    string
+		cookie  *http.Cookie
+		value   string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			cookie: cookie,
+			value:  "some value",
+		},
+		{
+			name:    "tampered",
+			cookie:  &http.Cookie{Name: cookie.Name, Value: "c29tZSBvdGhlcg" + cookie.Value[strings.Index(cookie.Value, "."):]},
+			wantErr: true,
+		},
+		{
+			name:    "renamed",
+			cookie:  &http.Cookie{Name: "session", Value: cookie.Value},
+			wantErr: true,
+		},
+		{
+			name:    "missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			value, err := ReadSignedCookie(req, "oauth_state")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadSignedCookie() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if value != tt.value {
+				t.Errorf("ReadSignedCookie() = %v, want %v", value, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/utils"
+)
+
+// DeviceCodeGrantType is the grant_type of the device token request (RFC 8628)
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// userCodeAlphabet leaves out vowels and look-alike characters so user codes are easy to type
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// slowDownStep is added to the poll interval of a device that polls too fast
+const slowDownStep = 5 * time.Second
+
+// denyTokenPurpose signs the user code embedded in the device page so only that page can deny the device
+const denyTokenPurpose = "device_deny"
+
+// maxUserCodeLookups unknown user codes entered from one address lock its lookups for userCodeLockout
+const (
+	maxUserCodeLookups = 10
+	userCodeLockout    = 15 * time.Minute
+)
+
+var (
+	// ErrTooManyLookups is returned while an address is locked after entering too many unknown user codes
+	ErrTooManyLookups = errors.New("too many unknown codes, please try again later")
+	// errUnknownUserCode is returned for a user code which does not exist
+	errUnknownUserCode = errors.New("unknown code, please check the code shown on your device")
+)
+
+// The token endpoint errors of the device flow, their text is the RFC 8628 error code
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredDeviceCode    = errors.New("expired_token")
+	ErrInvalidDeviceCode    = errors.New("invalid_grant")
+)
+
+// DeviceCodeStore is the device authorization data access object
+type DeviceCodeStore interface {
+	CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error
+	FetchDeviceCode(ctx context.Context, deviceCodeHash string) (*model.DeviceCode, error)
+	FetchDeviceCodeByUserCode(ctx context.Context, userCode string) (*model.DeviceCode, error)
+	ApproveDeviceCode(ctx context.Context, id int, userId int, provider string) (bool, error)
+	DenyDeviceCode(ctx context.Context, id int) error
+	TouchDeviceCode(ctx context.Context, id int, polledAt time.Time, interval int) error
+	UseDeviceCode(ctx context.Context, id int) (bool, error)
+	PruneDeviceCodes(ctx context.Context) (int64, error)
+}
+
+// DeviceCodes stores the device authorizations, it is set in main
+var DeviceCodes DeviceCodeStore
+
+// deviceConfig holds the verification url and timings set by SetupDevice
+var deviceConfig *config.DeviceConfig
+
+// userCodeLookups counts the unknown user codes entered per address, user codes are short
+// enough to be guessed otherwise
+var userCodeLookups = &lookupLimiter{failures: map[string]*lookupFailures{}}
+
+type lookupFailures struct {
+	count int
+	since time.Time
+}
+
+// lookupLimiter allows maxUserCodeLookups failed lookups per client in a userCodeLockout window
+type lookupLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*lookupFailures
+}
+
+// allowed tells whether client may look up another user code
+func (l *lookupLimiter) allowed(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failures, ok := l.failures[client]
+	if !ok {
+		return true
+	}
+	if now.Sub(failures.since) > userCodeLockout {
+		delete(l.failures, client)
+		return true
+	}
+	return failures.count < maxUserCodeLookups
+}
+
+// fail records an unknown user code entered by client and forgets the expired windows
+func (l *lookupLimiter) fail(client string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, failures := range l.failures {
+		if now.Sub(failures.since) > userCodeLockout {
+			delete(l.failures, key)
+		}
+	}
+	if failures, ok := l.failures[client]; ok {
+		failures.count++
+		return
+	}
+	l.failures[client] = &lookupFailures{count: 1, since: now}
+}
+
+// DeviceAuthorization is the device authorization response (RFC 8628 section 3.2)
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// SetupDevice sets the configuration of the device authorization flow
+func SetupDevice(conf *config.DeviceConfig) error {
+	if conf == nil {
+		return errors.New("device config can not be nil")
+	}
+	deviceConfig = conf
+	return nil
+}
+
+// RequestDeviceCode starts a device authorization. The device shows the user code and
+// polls PollDeviceToken with the device code until the user approved it.
+func RequestDeviceCode(ctx context.Context, clientId string) (*DeviceAuthorization, error) {
+	if DeviceCodes == nil || deviceConfig == nil {
+		return nil, errors.New("device authorization is not configured")
+	}
+	if pruned, err := DeviceCodes.PruneDeviceCodes(ctx); err != nil {
+		log.Println("Failed to prune device codes:", err)
+	} else if pruned > 0 {
+		log.Printf("Pruned %d expired device codes", pruned)
+	}
+
+	deviceCode, err := utils.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	err = DeviceCodes.CreateDeviceCode(ctx, &model.DeviceCode{
+		DeviceCodeHash: hashToken(deviceCode),
+		UserCode:       userCode,
+		ClientID:       clientId,
+		Interval:       int(deviceConfig.Interval.Seconds()),
+		ExpiresAt:      time.Now().Add(deviceConfig.CodeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	formatted := FormatUserCode(userCode)
+	return &DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                formatted,
+		VerificationURI:         deviceConfig.VerificationURL,
+		VerificationURIComplete: deviceConfig.VerificationURL + "?user_code=" + formatted,
+		ExpiresIn:               int64(deviceConfig.CodeTTL.Seconds()),
+		Interval:                int64(deviceConfig.Interval.Seconds()),
+	}, nil
+}
+
+// LookupDeviceCode returns the pending device authorization of the code entered by the user.
+// client identifies the user ie its address, it is locked out after too many unknown codes.
+func LookupDeviceCode(ctx context.Context, client string, userCode string) (*model.DeviceCode, error) {
+	now := time.Now()
+	if !userCodeLookups.allowed(client, now) {
+		return nil, ErrTooManyLookups
+	}
+	code, err := pendingDeviceCode(ctx, userCode)
+	if errors.Is(err, errUnknownUserCode) {
+		userCodeLookups.fail(client, now)
+	}
+	return code, err
+}
+
+// pendingDeviceCode returns the device authorization of userCode if it is still waiting for the user
+func pendingDeviceCode(ctx context.Context, userCode string) (*model.DeviceCode, error) {
+	if DeviceCodes == nil {
+		return nil, errors.New("device authorization is not configured")
+	}
+	code, err := DeviceCodes.FetchDeviceCodeByUserCode(ctx, NormalizeUserCode(userCode))
+	if err != nil {
+		return nil, errUnknownUserCode
+	}
+	if code.ApprovedAt != nil || code.DeniedAt != nil || time.Now().After(code.ExpiresAt) {
+		return nil, errors.New("the code expired, please start the login on your device again")
+	}
+	return code, nil
+}
+
+// ApproveDevice lets the device of userCode login as userId after the user logged in with provider
+func ApproveDevice(ctx context.Context, userCode string, userId string, provider string) error {
+	code, err := pendingDeviceCode(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+	ok, err := DeviceCodes.ApproveDeviceCode(ctx, code.ID, id, provider)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("the code expired, please start the login on your device again")
+	}
+	return nil
+}
+
+// CreateDenyToken signs the user code of a device page, the page posts it back to DenyDevice
+func CreateDenyToken(code *model.DeviceCode) (string, error) {
+	return SignValue(denyTokenPurpose, code.UserCode, time.Until(code.ExpiresAt))
+}
+
+// DenyDevice refuses the device authorization of the user code signed by CreateDenyToken
+func DenyDevice(ctx context.Context, denyToken string) error {
+	userCode, err := VerifyValue(denyTokenPurpose, denyToken)
+	if err != nil {
+		return errors.New("the page expired, please enter the code shown on your device again")
+	}
+	code, err := pendingDeviceCode(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	return DeviceCodes.DenyDeviceCode(ctx, code.ID)
+}
+
+// PollDeviceToken exchanges an approved device code for a token pair. Until the user
+// approved the device ErrAuthorizationPending is returned, or ErrSlowDown when the
+// device polls faster than its interval. Users with two-factor authentication get a
+// *SecondFactorChallenge error to answer with CompleteLogin.
+func PollDeviceToken(ctx context.Context, deviceCode string) (*TokenPair, error) {
+	if DeviceCodes == nil {
+		return nil, errors.New("device authorization is not configured")
+	}
+
+	code, err := DeviceCodes.FetchDeviceCode(ctx, hashToken(deviceCode))
+	if err != nil || code.UsedAt != nil {
+		return nil, ErrInvalidDeviceCode
+	}
+	now := time.Now()
+	if now.After(code.ExpiresAt) {
+		return nil, ErrExpiredDeviceCode
+	}
+	if code.DeniedAt != nil {
+		return nil, ErrAccessDenied
+	}
+
+	if code.ApprovedAt == nil || code.UserID == nil {
+		interval := code.Interval
+		pollErr := ErrAuthorizationPending
+		if code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(code.Interval)*time.Second {
+			interval += int(slowDownStep.Seconds())
+			pollErr = ErrSlowDown
+		}
+		if err := DeviceCodes.TouchDeviceCode(ctx, code.ID, now, interval); err != nil {
+			return nil, err
+		}
+		return nil, pollErr
+	}
+
+	ok, err := DeviceCodes.UseDeviceCode(ctx, code.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidDeviceCode
+	}
+	return IssueLoginTokens(ctx, strconv.Itoa(*code.UserID), code.Provider)
+}
+
+// FormatUserCode splits a user code in two halves ie BCDF-GHJK
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// NormalizeUserCode removes the dashes and spaces a user may type and upper cases the code
+func NormalizeUserCode(userCode string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(userCode))
+}
+
+// randomUserCode returns userCodeLength random characters of userCodeAlphabet
+func randomUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+type mockDeviceCodeStore struct {
+	mu    sync.Mutex
+	codes []*model.DeviceCode
+}
+
+func (m *mockDeviceCodeStore) CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code.ID = len(m.codes) + 1
+	m.codes = append(m.codes, code)
+	return nil
+}
+
+func (m *mockDeviceCodeStore) find(match func(c *model.DeviceCode) bool) (*model.DeviceCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range m.codes {
+		if match(code) {
+			copied := *code
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("No record found")
+}
+
+func (m *mockDeviceCodeStore) FetchDeviceCode(ctx context.Context, deviceCodeHash string) (*model.DeviceCode, error) {
+	return m.find(func(c *model.DeviceCode) bool { return c.DeviceCodeHash == deviceCodeHash })
+}
+
+func (m *mockDeviceCodeStore) FetchDeviceCodeByUserCode(ctx context.Context, userCode string) (*model.DeviceCode, error) {
+	return m.find(func(c *model.DeviceCode) bool { return c.UserCode == userCode })
+}
+
+func (m *mockDeviceCodeStore) ApproveDeviceCode(ctx context.Context, id int, userId int, provider string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := m.codes[id-1]
+	if code.ApprovedAt != nil || code.DeniedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	code.UserID, code.Provider, code.ApprovedAt = &userId, provider, &now
+	return true, nil
+}
+
+func (m *mockDeviceCodeStore) DenyDeviceCode(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.codes[id-1].DeniedAt = &now
+	return nil
+}
+
+func (m *mockDeviceCodeStore) TouchDeviceCode(ctx context.Context, id int, polledAt time.Time, interval int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[id-1].LastPolledAt, m.codes[id-1].Interval = &polledAt, interval
+	return nil
+}
+
+func (m *mockDeviceCodeStore) UseDeviceCode(ctx context.Context, id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := m.codes[id-1]
+	if code.UsedAt != nil || code.ApprovedAt == nil {
+		return false, nil
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockDeviceCodeStore) PruneDeviceCodes(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func setupTestDevice(t *testing.T) *mockDeviceCodeStore {
+	setupTestJWT(t)
+	if err := SetupDevice(&config.DeviceConfig{
+		VerificationURL: "http://localhost:8080/auth/device",
+		CodeTTL:         10 * time.Minute,
+		Interval:        5 * time.Second,
+	}); err != nil {
+		t.Fatalf("failed to setup device flow: %v", err)
+	}
+	if err := SetupCookies(&config.CookieConfig{Secret: []byte("a-test-cookie-secret-long-enough!!")}); err != nil {
+		t.Fatalf("failed to setup cookies: %v", err)
+	}
+	userCodeLookups = &lookupLimiter{failures: map[string]*lookupFailures{}}
+	store := &mockDeviceCodeStore{}
+	DeviceCodes = store
+	RefreshTokens = &mockRefreshTokenStore{}
+	return store
+}
+
+func TestDeviceFlow(t *testing.T) {
+	store := setupTestDevice(t)
+	ctx := context.Background()
+
+	authorization, err := RequestDeviceCode(ctx, "todo-cli")
+	if err != nil {
+		t.Fatalf("failed to request device code: %v", err)
+	}
+	if !strings.HasSuffix(authorization.VerificationURIComplete, "?user_code="+authorization.UserCode) {
+		t.Errorf("unexpected verification_uri_complete %s", authorization.VerificationURIComplete)
+	}
+	if len(authorization.UserCode) != userCodeLength+1 || authorization.UserCode[4] != '-' {
+		t.Errorf("unexpected user code %s", authorization.UserCode)
+	}
+
+	if _, err := PollDeviceToken(ctx, authorization.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Errorf("PollDeviceToken() before approval error = %v, want %v", err, ErrAuthorizationPending)
+	}
+	if _, err := PollDeviceToken(ctx, authorization.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Errorf("PollDeviceToken() polled too fast error = %v, want %v", err, ErrSlowDown)
+	}
+	if interval := store.codes[0].Interval; interval != 10 {
+		t.Errorf("interval after slow_down = %v, want %v", interval, 10)
+	}
+
+	// users may type the code in lower case and without the dash
+	typed := strings.ToLower(strings.ReplaceAll(authorization.UserCode, "-", ""))
+	if err := ApproveDevice(ctx, typed, "7", "github"); err != nil {
+		t.Fatalf("failed to approve device: %v", err)
+	}
+	if err := ApproveDevice(ctx, authorization.UserCode, "8", "github"); err == nil {
+		t.Errorf("ApproveDevice() of an approved code should fail")
+	}
+
+	tokens, err := PollDeviceToken(ctx, authorization.DeviceCode)
+	if err != nil {
+		t.Fatalf("failed to poll approved device: %v", err)
+	}
+	claims, err := ParseJWT(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse device token: %v", err)
+	}
+	if claims.Subject != "7" || claims.Provider != "github" {
+		t.Errorf("device token sub = %v provider = %v", claims.Subject, claims.Provider)
+	}
+
+	if _, err := PollDeviceToken(ctx, authorization.DeviceCode); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Errorf("PollDeviceToken() of a used code error = %v, want %v", err, ErrInvalidDeviceCode)
+	}
+}
+
+func TestDeviceFlow_DeniedAndExpired(t *testing.T) {
+	store := setupTestDevice(t)
+	ctx := context.Background()
+
+	denied, _ := RequestDeviceCode(ctx, "")
+	code, err := LookupDeviceCode(ctx, "127.0.0.1", denied.UserCode)
+	if err != nil {
+		t.Fatalf("failed to lookup device code: %v", err)
+	}
+	if err := DenyDevice(ctx, code.UserCode); err == nil {
+		t.Errorf("DenyDevice() without a deny token should fail")
+	}
+	denyToken, err := CreateDenyToken(code)
+	if err != nil {
+		t.Fatalf("failed to create deny token: %v", err)
+	}
+	if err := DenyDevice(ctx, denyToken); err != nil {
+		t.Fatalf("failed to deny device: %v", err)
+	}
+	if _, err := PollDeviceToken(ctx, denied.DeviceCode); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("PollDeviceToken() of a denied code error = %v, want %v", err, ErrAccessDenied)
+	}
+
+	expired, _ := RequestDeviceCode(ctx, "")
+	store.codes[1].ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := LookupDeviceCode(ctx, "127.0.0.1", expired.UserCode); err == nil {
+		t.Errorf("LookupDeviceCode() of an expired code should fail")
+	}
+	if _, err := PollDeviceToken(ctx, expired.DeviceCode); !errors.Is(err, ErrExpiredDeviceCode) {
+		t.Errorf("PollDeviceToken() of an expired code error = %v, want %v", err, ErrExpiredDeviceCode)
+	}
+
+	if _, err := PollDeviceToken(ctx, "unknown"); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Errorf("PollDeviceToken() of an unknown code error = %v, want %v", err, ErrInvalidDeviceCode)
+	}
+}
+
+func TestLookupDeviceCode_TooManyUnknownCodes(t *testing.T) {
+	setupTestDevice(t)
+	ctx := context.Background()
+
+	authorization, _ := RequestDeviceCode(ctx, "")
+	for i := 0; i < maxUserCodeLookups; i++ {
+		if _, err := LookupDeviceCode(ctx, "10.0.0.1", "BCDF-BCDF"); !errors.Is(err, errUnknownUserCode) {
+			t.Fatalf("LookupDeviceCode() of an unknown code error = %v, want %v", err, errUnknownUserCode)
+		}
+	}
+	if _, err := LookupDeviceCode(ctx, "10.0.0.1", authorization.UserCode); !errors.Is(err, ErrTooManyLookups) {
+		t.Errorf("LookupDeviceCode() after too many unknown codes error = %v, want %v", err, ErrTooManyLookups)
+	}
+	if _, err := LookupDeviceCode(ctx, "10.0.0.2", authorization.UserCode); err != nil {
+		t.Errorf("LookupDeviceCode() from another address error = %v", err)
+	}
+
+	// the lockout ends after userCodeLockout
+	userCodeLookups.failures["10.0.0.1"].since = time.Now().Add(-userCodeLockout - time.Second)
+	if _, err := LookupDeviceCode(ctx, "10.0.0.1", authorization.UserCode); err != nil {
+		t.Errorf("LookupDeviceCode() after the lockout error = %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/utils"
+	"github.com/golang-jwt/jwt"
+)
+
+// rsaKeyBits is the size of generated RS256 keys
+const rsaKeyBits = 2048
+
+// SigningKey is a key used to sign and verify tokens, identified by its kid
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   interface{}
+	Public    interface{}
+	CreatedAt time.Time
+}
+
+// KeyRing holds the key currently used for signing together with the older
+// keys that are still accepted for verification
+type KeyRing struct {
+	mu sync.RWMutex
+	// keys are sorted newest first, keys[0] is the active signing key
+	keys      []*SigningKey
+	algorithm string
+	ttl       time.Duration
+	dir       string
+}
+
+// NewHMACKeyRing creates a KeyRing holding a single HS256 key
+func NewHMACKeyRing(secret []byte) *KeyRing {
+	return &KeyRing{
+		algorithm: jwt.SigningMethodHS256.Alg(),
+		keys: []*SigningKey{
+			{
+				ID:      "default",
+				Method:  jwt.SigningMethodHS256,
+				Private: secret,
+				Public:  secret,
+			},
+		},
+	}
+}
+
+// NewKeyRing creates a RS256 or ES256 KeyRing. Keys are loaded from dir when it is set,
+// and a new key is generated (and saved to dir) when there is no usable signing key.
+// ttl is how long a key is kept for verification after it stops signing.
+func NewKeyRing(algorithm string, dir string, ttl time.Duration) (*KeyRing, error) {
+	if algorithm != jwt.SigningMethodRS256.Alg() && algorithm != jwt.SigningMethodES256.Alg() {
+		return nil, fmt.Errorf("unsupported jwt algorithm %s", algorithm)
+	}
+
+	k := &KeyRing{
+		algorithm: algorithm,
+		ttl:       ttl,
+		dir:       dir,
+	}
+	if dir != "" {
+		if err := k.load(); err != nil {
+			return nil, err
+		}
+	}
+	if k.active() == nil || k.active().Method.Alg() != algorithm {
+		if err := k.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// active returns the signing key, the caller must hold the lock
+func (k *KeyRing) active() *SigningKey {
+	if len(k.keys) == 0 {
+		return nil
+	}
+	return k.keys[0]
+}
+
+// Signer returns the key that should be used to sign new tokens
+func (k *KeyRing) Signer() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active()
+}
+
+// Lookup returns the verification key for kid, an empty kid returns the signing key
+func (k *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if kid == "" {
+		return k.active(), k.active() != nil
+	}
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Keys returns all of the keys accepted for verification, newest first
+func (k *KeyRing) Keys() []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]*SigningKey, len(k.keys))
+	copy(keys, k.keys)
+	return keys
+}
+
+// Rotate generates a new signing key and drops the keys whose tokens can no longer be valid
+func (k *KeyRing) Rotate() error {
+	if k.algorithm == jwt.SigningMethodHS256.Alg() {
+		return errors.New("HS256 keys can not be rotated, please change JWT_SECRET instead")
+	}
+
+	key, err := generateKey(k.algorithm)
+	if err != nil {
+		return err
+	}
+	if k.dir != "" {
+		if err := saveKey(k.dir, key); err != nil {
+			return err
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append([]*SigningKey{key}, k.keys...)
+	k.prune(time.Now())
+	return nil
+}
+
+// prune removes the keys retired for longer than the token ttl, the caller must hold the lock
+func (k *KeyRing) prune(now time.Time) {
+	keys := []*SigningKey{k.keys[0]}
+	for i := 1; i < len(k.keys); i++ {
+		// a key stops signing when the next key is created
+		retiredAt := k.keys[i-1].CreatedAt
+		if retiredAt.Add(k.ttl).After(now) {
+			keys = append(keys, k.keys[i])
+			continue
+		}
+		if k.dir != "" {
+			if err := os.Remove(filepath.Join(k.dir, k.keys[i].ID+".pem")); err != nil && !os.IsNotExist(err) {
+				log.Println("Failed to remove retired signing key:", err)
+			}
+		}
+	}
+	k.keys = keys
+}
+
+// StartRotation rotates the signing key every interval in a background goroutine
+func (k *KeyRing) StartRotation(interval time.Duration) {
+	if signer := k.Signer(); signer != nil && time.Since(signer.CreatedAt) >= interval {
+		if err := k.Rotate(); err != nil {
+			log.Println("Failed to rotate signing key:", err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := k.Rotate(); err != nil {
+				log.Println("Failed to rotate signing key:", err)
+				continue
+			}
+			log.Printf("Rotated signing key, new kid %s", k.Signer().ID)
+		}
+	}()
+}
+
+// load reads every *.pem private key in the key directory, the kid is the file name
+func (k *KeyRing) load() error {
+	files, err := filepath.Glob(filepath.Join(k.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		key, err := parseKey(contents)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", file, err)
+		}
+		key.ID = strings.TrimSuffix(filepath.Base(file), ".pem")
+		key.CreatedAt = info.ModTime()
+		k.keys = append(k.keys, key)
+	}
+
+	sort.Slice(k.keys, func(i, j int) bool {
+		return k.keys[i].CreatedAt.After(k.keys[j].CreatedAt)
+	})
+	if len(k.keys) > 0 {
+		k.prune(time.Now())
+	}
+	return nil
+}
+
+// generateKey creates a new RS256 or ES256 key with a random kid
+func generateKey(algorithm string) (*SigningKey, error) {
+	suffix, err := utils.RandomString(4)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	key := &SigningKey{
+		ID:        now.UTC().Format("20060102T150405") + "-" + suffix,
+		CreatedAt: now,
+	}
+
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, private, &private.PublicKey
+	case jwt.SigningMethodES256.Alg():
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.Method, key.Private, key.Public = jwt.SigningMethodES256, private, &private.PublicKey
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %s", algorithm)
+	}
+	return key, nil
+}
+
+// saveKey writes the private key as a PKCS8 pem file named after its kid
+func saveKey(dir string, key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	contents := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return ioutil.WriteFile(filepath.Join(dir, key.ID+".pem"), contents, 0600)
+}
+
+// parseKey parses a PKCS8 pem encoded RSA or P-256 private key
+func parseKey(contents []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, errors.New("no pem data found")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		if private.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 ecdsa keys are supported")
+		}
+		return &SigningKey{Method: jwt.SigningMethodES256, Private: private, Public: &private.PublicKey}, nil
+	default:
+		return nil, errors.New("unsupported private key type")
+	}
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys of the key ring, symmetric keys are never published
+func (k *KeyRing) JWKS() []JWK {
+	jwks := []JWK{}
+	for _, key := range k.Keys() {
+		jwk := JWK{
+			Kid: key.ID,
+			Alg: key.Method.Alg(),
+			Use: "sig",
+		}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+// JWKSHandler serves the public verification keys at /.well-known/jwks.json
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	jwks := []JWK{}
+	if keys != nil {
+		jwks = keys.JWKS()
+	}
+
+	body, err := json.Marshal(map[string][]JWK{"keys": jwks})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+func TestSetupJWT_Asymmetric(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256"} {
+		t.Run(alg, func(t *testing.T) {
+			err := SetupJWT(&config.JWTConfig{
+				Algorithm: alg,
+				Issuer:    "todo-list",
+				Audience:  "todo-list",
+				TTL:       time.Hour,
+			})
+			if err != nil {
+				t.Fatalf("failed to setup jwt: %v", err)
+			}
+
+			tokenStr, err := CreateJWT("user-a", "google")
+			if err != nil {
+				t.Fatalf("failed to create token: %v", err)
+			}
+			claims, err := ParseJWT(tokenStr)
+			if err != nil {
+				t.Fatalf("failed to parse token: %v", err)
+			}
+			if claims.Subject != "user-a" {
+				t.Errorf("ParseJWT() sub = %v, want user-a", claims.Subject)
+			}
+
+			// tokens signed before a rotation must stay valid
+			if err := keys.Rotate(); err != nil {
+				t.Fatalf("failed to rotate keys: %v", err)
+			}
+			if _, err := ParseJWT(tokenStr); err != nil {
+				t.Errorf("token signed by the previous key should be valid: %v", err)
+			}
+			if len(keys.Keys()) != 2 {
+				t.Errorf("expected 2 keys after rotation, got %d", len(keys.Keys()))
+			}
+		})
+	}
+}
+
+func TestKeyRing_Prune(t *testing.T) {
+	ring, err := NewKeyRing("ES256", "", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create key ring: %v", err)
+	}
+	if err := ring.Rotate(); err != nil {
+		t.Fatalf("failed to rotate keys: %v", err)
+	}
+
+	// the first key retired when the second one was created, after the ttl it has to go
+	ring.prune(time.Now().Add(2 * time.Hour))
+	if len(ring.Keys()) != 1 {
+		t.Errorf("expected 1 key after prune, got %d", len(ring.Keys()))
+	}
+}
+
+func TestKeyRing_LoadFromDir(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := NewKeyRing("RS256", dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create key ring: %v", err)
+	}
+	second, err := NewKeyRing("RS256", dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to load key ring: %v", err)
+	}
+	if first.Signer().ID != second.Signer().ID {
+		t.Errorf("expected the persisted key %v to be loaded, got %v", first.Signer().ID, second.Signer().ID)
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	if err := SetupJWT(&config.JWTConfig{Algorithm: "ES256", TTL: time.Hour}); err != nil {
+		t.Fatalf("failed to setup jwt: %v", err)
+	}
+
+	writer := httptest.NewRecorder()
+	JWKSHandler(writer, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	var body struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.NewDecoder(writer.Body).Decode(&body); err != nil {
+		t.Fatalf("JWKSHandler() = json body decode error %v", err)
+	}
+	if len(body.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(body.Keys))
+	}
+	key := body.Keys[0]
+	if key.Kid != keys.Signer().ID || key.Kty != "EC" || key.Crv != "P-256" || key.X == "" || key.Y == "" {
+		t.Errorf("unexpected jwk %+v", key)
+	}
+
+	// symmetric keys are never published
+	setupTestJWT(t)
+	writer = httptest.NewRecorder()
+	JWKSHandler(writer, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if err := json.NewDecoder(writer.Body).Decode(&body); err != nil {
+		t.Fatalf("JWKSHandler() = json body decode error %v", err)
+	}
+	if len(body.Keys) != 0 {
+		t.Errorf("expected no keys for HS256, got %d", len(body.Keys))
+	}
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/utils"
+)
+
+// PersonalAccessTokenPrefix tells personal access tokens apart from session JWTs
+const PersonalAccessTokenPrefix = "tdl_pat_"
+
+// personalAccessTokenScopes are the scopes a personal access token can be created with,
+// the admin scope is only granted to admins
+var personalAccessTokenScopes = map[string]bool{
+	ScopeTasksRead:   true,
+	ScopeTasksWrite:  true,
+	ScopeTasksDelete: true,
+	ScopeAdmin:       true,
+}
+
+// ErrInvalidAccessToken is returned for unknown, expired or revoked personal access tokens
+var ErrInvalidAccessToken = errors.New("invalid personal access token")
+
+// AccessTokenStore is the personal access token data access object
+type AccessTokenStore interface {
+	CreateAccessToken(ctx context.Context, token *model.PersonalAccessToken) (*model.PersonalAccessToken, error)
+	FetchAccessToken(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error)
+	ListAccessTokens(ctx context.Context, userId int) ([]model.PersonalAccessToken, error)
+	RevokeAccessToken(ctx context.Context, id int, userId int) error
+	RevokeAccessTokensByUser(ctx context.Context, userId int) error
+	TouchAccessToken(ctx context.Context, id int) error
+}
+
+// AccessTokens stores the personal access tokens, it is set in main
+var AccessTokens AccessTokenStore
+
+// CreatePersonalAccessToken mints a named token for userId. Without scopes the token is read only,
+// a nil expiresAt creates a token that does not expire.
+func CreatePersonalAccessToken(ctx context.Context, userId string, name string, scopes []string, expiresAt *time.Time) (*model.PersonalAccessToken, error) {
+	if AccessTokens == nil {
+		return nil, errors.New("personal access tokens are not configured")
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("token must have a name")
+	}
+	if len(scopes) == 0 {
+		scopes = []string{ScopeTasksRead}
+	}
+	for _, scope := range scopes {
+		if !personalAccessTokenScopes[scope] {
+			return nil, fmt.Errorf("unknown scope %s", scope)
+		}
+		if scope == ScopeAdmin {
+			admin, err := IsAdmin(ctx, userId)
+			if err != nil {
+				return nil, err
+			}
+			if !admin {
+				return nil, fmt.Errorf("only admins can create tokens with the %s scope", ScopeAdmin)
+			}
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	secret, err := utils.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	tokenStr := PersonalAccessTokenPrefix + secret
+
+	token, err := AccessTokens.CreateAccessToken(ctx, &model.PersonalAccessToken{
+		UserID:    id,
+		Name:      name,
+		TokenHash: hashToken(tokenStr),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	token.Token = tokenStr
+	return token, nil
+}
+
+// ParsePersonalAccessToken returns the stored token of tokenStr when it is still valid
+func ParsePersonalAccessToken(ctx context.Context, tokenStr string) (*model.PersonalAccessToken, error) {
+	if AccessTokens == nil {
+		return nil, errors.New("personal access tokens are not configured")
+	}
+
+	token, err := AccessTokens.FetchAccessToken(ctx, hashToken(tokenStr))
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	if token.RevokedAt != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if err := AccessTokens.TouchAccessToken(ctx, token.ID); err != nil {
+		log.Println("Failed to record personal access token use:", err)
+	}
+	return token, nil
+}
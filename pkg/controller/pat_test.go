@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+type mockAccessTokenStore struct {
+	mu     sync.Mutex
+	tokens []*model.PersonalAccessToken
+}
+
+func (m *mockAccessTokenStore) CreateAccessToken(ctx context.Context, token *model.PersonalAccessToken) (*model.PersonalAccessToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token.ID = len(m.tokens) + 1
+	stored := *token
+	m.tokens = append(m.tokens, &stored)
+	return token, nil
+}
+
+func (m *mockAccessTokenStore) FetchAccessToken(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("No record found")
+}
+
+func (m *mockAccessTokenStore) ListAccessTokens(ctx context.Context, userId int) ([]model.PersonalAccessToken, error) {
+	return nil, nil
+}
+
+func (m *mockAccessTokenStore) RevokeAccessToken(ctx context.Context, id int, userId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.tokens[id-1].RevokedAt = &now
+	return nil
+}
+
+func (m *mockAccessTokenStore) RevokeAccessTokensByUser(ctx context.Context, userId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockAccessTokenStore) TouchAccessToken(ctx context.Context, id int) error {
+	return nil
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	setupTestJWT(t)
+	store := &mockAccessTokenStore{}
+	AccessTokens = store
+	ctx := context.Background()
+
+	if _, err := CreatePersonalAccessToken(ctx, "7", "ci", []string{"admin"}, nil); err == nil {
+		t.Errorf("expected unknown scope to be rejected")
+	}
+
+	token, err := CreatePersonalAccessToken(ctx, "7", "ci", nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if !strings.HasPrefix(token.Token, PersonalAccessTokenPrefix) {
+		t.Errorf("expected token to start with %s, got %s", PersonalAccessTokenPrefix, token.Token)
+	}
+	if strings.Contains(store.tokens[0].TokenHash, token.Token) {
+		t.Errorf("expected only the hash of the token to be stored")
+	}
+
+	var gotUserId string
+	var gotScopes []string
+	handler := ValidateJWT(func(w http.ResponseWriter, r *http.Request) {
+		gotUserId = UserIDFromContext(r.Context())
+		gotScopes, _ = ScopesFromContext(r.Context())
+	})
+	status := func(tokenStr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/todolist", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, req)
+		return writer.Result().StatusCode
+	}
+
+	if got := status(token.Token); got != http.StatusOK {
+		t.Fatalf("ValidateJWT() = %v, want %v", got, http.StatusOK)
+	}
+	if gotUserId != "7" || !reflect.DeepEqual(gotScopes, []string{ScopeTasksRead}) {
+		t.Errorf("ValidateJWT() userId = %v scopes = %v", gotUserId, gotScopes)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	expiring, _ := CreatePersonalAccessToken(ctx, "7", "nightly", []string{ScopeTasksRead, ScopeTasksWrite}, &expiresAt)
+	store.tokens[1].ExpiresAt = &time.Time{}
+	if got := status(expiring.Token); got != http.StatusUnauthorized {
+		t.Errorf("ValidateJWT() with expired token = %v, want %v", got, http.StatusUnauthorized)
+	}
+
+	_ = store.RevokeAccessToken(ctx, token.ID, 7)
+	if got := status(token.Token); got != http.StatusUnauthorized {
+		t.Errorf("ValidateJWT() with revoked token = %v, want %v", got, http.StatusUnauthorized)
+	}
+	if got := status(PersonalAccessTokenPrefix + "unknown"); got != http.StatusUnauthorized {
+		t.Errorf("ValidateJWT() with unknown token = %v, want %v", got, http.StatusUnauthorized)
+	}
+}
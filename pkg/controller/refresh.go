@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+	"github.com/cfthoo/todo-app/pkg/utils"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reused, please login again")
+)
+
+// RefreshTokenStore is the refresh token data access object
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) (*model.RefreshToken, error)
+	FetchRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, id int) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyId string) error
+	RevokeRefreshTokensByUser(ctx context.Context, userId string) error
+}
+
+// RefreshTokens stores the issued refresh tokens, it is set in main
+var RefreshTokens RefreshTokenStore
+
+// TokenPair is returned after a successful login or refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// IssueTokens creates an access token and a new refresh token family for a login
+func IssueTokens(ctx context.Context, userId string, provider string) (*TokenPair, error) {
+	familyId, err := utils.RandomString(16)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, userId, provider, familyId, time.Time{})
+}
+
+// RefreshJWT exchanges a refresh token for a new token pair. The presented refresh
+// token is rotated, presenting it a second time revokes the whole family.
+func RefreshJWT(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if RefreshTokens == nil {
+		return nil, errors.New("refresh tokens are not configured")
+	}
+
+	stored, err := fetchRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	ok, err := RefreshTokens.UseRefreshToken(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// somebody already used this token, the family may be stolen so end it
+		if err := RefreshTokens.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// the second factor is not carried over, sensitive actions ask for a new code
+	return issueTokens(ctx, stored.UserID, stored.Provider, stored.FamilyID, time.Time{})
+}
+
+// issueTokens creates an access token and a refresh token in the given family, mfaAt is set
+// when the user just proved a second factor
+func issueTokens(ctx context.Context, userId string, provider string, familyId string, mfaAt time.Time) (*TokenPair, error) {
+	if RefreshTokens == nil {
+		return nil, errors.New("refresh tokens are not configured")
+	}
+	if err := checkUserEnabled(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := createJWT(userId, provider, mfaAt)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.RandomString(32)
+	if err != nil {
+		return nil, err
+	}
+	_, err = RefreshTokens.CreateRefreshToken(ctx, &model.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyId,
+		UserID:    userId,
+		Provider:  provider,
+		ExpiresAt: time.Now().Add(jwtConfig.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(jwtConfig.TTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// fetchRefreshToken looks up a presented refresh token, an unknown token is ErrInvalidRefreshToken
+func fetchRefreshToken(ctx context.Context, refreshToken string) (*model.RefreshToken, error) {
+	stored, err := RefreshTokens.FetchRefreshToken(ctx, hashToken(refreshToken))
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	return stored, err
+}
+
+// hashToken returns the sha256 hex digest used to store opaque tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+)
+
+type mockRefreshTokenStore struct {
+	mu       sync.Mutex
+	tokens   []*model.RefreshToken
+	fetchErr error
+}
+
+func (m *mockRefreshTokenStore) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) (*model.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token.ID = len(m.tokens) + 1
+	m.tokens = append(m.tokens, token)
+	return token, nil
+}
+
+func (m *mockRefreshTokenStore) FetchRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fetchErr != nil {
+		return nil, m.fetchErr
+	}
+	for _, token := range m.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, repo.ErrNotFound
+}
+
+func (m *mockRefreshTokenStore) UseRefreshToken(ctx context.Context, id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token := m.tokens[id-1]
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (m *mockRefreshTokenStore) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.FamilyID == familyId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockRefreshTokenStore) RevokeRefreshTokensByUser(ctx context.Context, userId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userId && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func TestRefreshJWT(t *testing.T) {
+	setupTestJWT(t)
+	RefreshTokens = &mockRefreshTokenStore{}
+	ctx := context.Background()
+
+	first, err := IssueTokens(ctx, "user-a", "google")
+	if err != nil {
+		t.Fatalf("failed to issue tokens: %v", err)
+	}
+
+	second, err := RefreshJWT(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("failed to refresh token: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Errorf("expected the refresh token to be rotated")
+	}
+	claims, err := ParseJWT(second.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse refreshed token: %v", err)
+	}
+	if claims.Subject != "user-a" || claims.Provider != "google" {
+		t.Errorf("unexpected claims sub=%v provider=%v", claims.Subject, claims.Provider)
+	}
+
+	// presenting the rotated token again ends the whole family
+	if _, err := RefreshJWT(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("RefreshJWT() with reused token = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := RefreshJWT(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshJWT() after reuse = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	if _, err := RefreshJWT(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RefreshJWT() with unknown token = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	// a failing database is not an invalid token
+	dbErr := errors.New("connection refused")
+	RefreshTokens = &mockRefreshTokenStore{fetchErr: dbErr}
+	if _, err := RefreshJWT(ctx, second.RefreshToken); err != dbErr {
+		t.Errorf("RefreshJWT() with a failing store = %v, want %v", err, dbErr)
+	}
+}
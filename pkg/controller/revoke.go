@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTokenRevoked is returned for tokens on the deny-list
+var ErrTokenRevoked = errors.New("token revoked")
+
+// RevocationStore is the token deny-list data access object
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, userId string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userId string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string, userId string, issuedAt time.Time) (bool, error)
+	PruneRevocations(ctx context.Context) (int64, error)
+}
+
+// Revocations is the persisted deny-list checked by ValidateJWT, it is set in main
+var Revocations RevocationStore
+
+// checkRevoked returns ErrTokenRevoked when the token is on the deny-list
+func checkRevoked(ctx context.Context, claims *Claims) error {
+	if Revocations == nil {
+		return nil
+	}
+	revoked, err := Revocations.IsRevoked(ctx, claims.Id, claims.Subject, claims.issuedAt())
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Logout revokes the access token and, when given, the refresh token family of the same login
+func Logout(ctx context.Context, claims *Claims, refreshToken string) error {
+	if Revocations == nil {
+		return errors.New("revocations are not configured")
+	}
+
+	err := Revocations.RevokeToken(ctx, claims.Id, claims.Subject, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return err
+	}
+
+	if refreshToken == "" || RefreshTokens == nil {
+		return nil
+	}
+	stored, err := fetchRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	if stored.UserID != claims.Subject {
+		return ErrInvalidRefreshToken
+	}
+	return RefreshTokens.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+}
+
+// RevokeUser revokes every access and refresh token issued to a user so far
+func RevokeUser(ctx context.Context, userId string) error {
+	if Revocations == nil || jwtConfig == nil {
+		return errors.New("revocations are not configured")
+	}
+
+	// every access token issued until now expires within one ttl
+	err := Revocations.RevokeUser(ctx, userId, time.Now().Add(jwtConfig.TTL))
+	if err != nil {
+		return err
+	}
+
+	if RefreshTokens == nil {
+		return nil
+	}
+	return RefreshTokens.RevokeRefreshTokensByUser(ctx, userId)
+}
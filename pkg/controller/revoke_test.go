@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockRevocationStore struct {
+	mu    sync.Mutex
+	jtis  map[string]time.Time
+	users map[string]time.Time
+}
+
+func newMockRevocationStore() *mockRevocationStore {
+	return &mockRevocationStore{
+		jtis:  map[string]time.Time{},
+		users: map[string]time.Time{},
+	}
+}
+
+func (m *mockRevocationStore) RevokeToken(ctx context.Context, jti string, userId string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jtis[jti] = expiresAt
+	return nil
+}
+
+func (m *mockRevocationStore) RevokeUser(ctx context.Context, userId string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[userId] = time.Now()
+	return nil
+}
+
+func (m *mockRevocationStore) IsRevoked(ctx context.Context, jti string, userId string, issuedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jtis[jti]; ok {
+		return true, nil
+	}
+	revokedAt, ok := m.users[userId]
+	return ok && !revokedAt.Before(issuedAt), nil
+}
+
+func (m *mockRevocationStore) PruneRevocations(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// validateStatus runs tokenStr through ValidateJWT and returns the response status
+func validateStatus(tokenStr string) int {
+	handler := ValidateJWT(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodGet, "/todolist", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenStr)
+	writer := httptest.NewRecorder()
+	handler.ServeHTTP(writer, req)
+	return writer.Result().StatusCode
+}
+
+func TestLogout(t *testing.T) {
+	setupTestJWT(t)
+	RefreshTokens = &mockRefreshTokenStore{}
+	Revocations = newMockRevocationStore()
+	defer func() { Revocations = nil }()
+	ctx := context.Background()
+
+	first, _ := IssueTokens(ctx, "user-a", "google")
+	second, _ := IssueTokens(ctx, "user-a", "github")
+
+	claims, err := ParseJWT(first.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if err := Logout(ctx, claims, first.RefreshToken); err != nil {
+		t.Fatalf("failed to logout: %v", err)
+	}
+
+	if status := validateStatus(first.AccessToken); status != http.StatusUnauthorized {
+		t.Errorf("ValidateJWT() after logout = %v, want %v", status, http.StatusUnauthorized)
+	}
+	if _, err := RefreshJWT(ctx, first.RefreshToken); err == nil {
+		t.Errorf("RefreshJWT() after logout should fail")
+	}
+	// other logins of the same user are not affected
+	if status := validateStatus(second.AccessToken); status != http.StatusOK {
+		t.Errorf("ValidateJWT() of other login = %v, want %v", status, http.StatusOK)
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	setupTestJWT(t)
+	RefreshTokens = &mockRefreshTokenStore{}
+	Revocations = newMockRevocationStore()
+	defer func() { Revocations = nil }()
+	ctx := context.Background()
+
+	userA, _ := IssueTokens(ctx, "user-a", "google")
+	userB, _ := IssueTokens(ctx, "user-b", "google")
+
+	if err := RevokeUser(ctx, "user-a"); err != nil {
+		t.Fatalf("failed to revoke user: %v", err)
+	}
+
+	if status := validateStatus(userA.AccessToken); status != http.StatusUnauthorized {
+		t.Errorf("ValidateJWT() of revoked user = %v, want %v", status, http.StatusUnauthorized)
+	}
+	if _, err := RefreshJWT(ctx, userA.RefreshToken); err == nil {
+		t.Errorf("RefreshJWT() of revoked user should fail")
+	}
+	if status := validateStatus(userB.AccessToken); status != http.StatusOK {
+		t.Errorf("ValidateJWT() of other user = %v, want %v", status, http.StatusOK)
+	}
+
+	// a login right after the revocation, within the same second, is not revoked
+	fresh, _ := IssueTokens(ctx, "user-a", "google")
+	if status := validateStatus(fresh.AccessToken); status != http.StatusOK {
+		t.Errorf("ValidateJWT() of a token issued after the revocation = %v, want %v", status, http.StatusOK)
+	}
+}
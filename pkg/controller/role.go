@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/cfthoo/todo-app/pkg/db/repo"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+var (
+	// ErrUserDisabled is returned when a disabled user tries to login or refresh its tokens
+	ErrUserDisabled = errors.New("account is disabled")
+	// ErrInvalidRole is returned for roles other than user and admin
+	ErrInvalidRole = errors.New("invalid role, it must be user or admin")
+	// ErrSelfAdministration is returned when an admin tries to disable or demote itself
+	ErrSelfAdministration = errors.New("admins can not disable or change the role of their own account")
+)
+
+// RequireRole only calls next when the current user has role and is not disabled, otherwise it
+// responds 403. The role is read from the database so a change applies right away. Personal access
+// tokens also need the admin scope. It must be wrapped by ValidateJWT.
+func RequireRole(role string, next func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if Users == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("users are not configured"))
+			return
+		}
+
+		// scripts only reach the admin api with a token created for it
+		if _, ok := ClaimsFromContext(r.Context()); !ok && !HasScope(r.Context(), ScopeAdmin) {
+			RequireScope(ScopeAdmin, next)(w, r)
+			return
+		}
+
+		id, _ := strconv.Atoi(UserIDFromContext(r.Context()))
+		user, err := Users.FetchUser(r.Context(), id)
+		if err == nil && user.Role == role && user.DisabledAt == nil {
+			next(w, r)
+			return
+		}
+
+		writeForbidden(w, &forbiddenError{
+			Error:   "forbidden",
+			Message: fmt.Sprintf("the %s role is required", role),
+		})
+	}
+}
+
+// IsAdmin reports whether userId has the admin role
+func IsAdmin(ctx context.Context, userId string) (bool, error) {
+	if Users == nil {
+		return false, errors.New("users are not configured")
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return false, errors.New("invalid user id")
+	}
+	user, err := Users.FetchUser(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return user.Role == RoleAdmin && user.DisabledAt == nil, nil
+}
+
+// SetRole gives userId the role, adminId is the admin making the change
+func SetRole(ctx context.Context, adminId string, userId string, role string) error {
+	if role != RoleUser && role != RoleAdmin {
+		return ErrInvalidRole
+	}
+	id, err := administeredUser(adminId, userId)
+	if err != nil {
+		return err
+	}
+	return Users.SetUserRole(ctx, id, role)
+}
+
+// DisableUser stops userId from logging in and revokes all of its sessions and personal access tokens,
+// adminId is the admin making the change
+func DisableUser(ctx context.Context, adminId string, userId string) error {
+	id, err := administeredUser(adminId, userId)
+	if err != nil {
+		return err
+	}
+	if err := Users.SetUserDisabled(ctx, id, true); err != nil {
+		return err
+	}
+	if AccessTokens != nil {
+		if err := AccessTokens.RevokeAccessTokensByUser(ctx, id); err != nil {
+			return err
+		}
+	}
+	return RevokeUser(ctx, userId)
+}
+
+// EnableUser lets a disabled user login again
+func EnableUser(ctx context.Context, userId string) error {
+	if Users == nil {
+		return errors.New("users are not configured")
+	}
+	// a user id which is not a number does not exist
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return repo.ErrNotFound
+	}
+	return Users.SetUserDisabled(ctx, id, false)
+}
+
+// checkUserEnabled refuses to issue tokens to a disabled user
+func checkUserEnabled(ctx context.Context, userId string) error {
+	if Users == nil {
+		return nil
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+	user, err := Users.FetchUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.DisabledAt != nil {
+		return ErrUserDisabled
+	}
+	return nil
+}
+
+// administeredUser parses userId, an admin can not lock itself out
+func administeredUser(adminId string, userId string) (int, error) {
+	if Users == nil {
+		return 0, errors.New("users are not configured")
+	}
+	// a user id which is not a number does not exist
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return 0, repo.ErrNotFound
+	}
+	if userId == adminId {
+		return 0, ErrSelfAdministration
+	}
+	return id, nil
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+type mockUserStore struct {
+	mu    sync.Mutex
+	users map[int]*model.User
+}
+
+func newMockUserStore(users ...model.User) *mockUserStore {
+	m := &mockUserStore{users: map[int]*model.User{}}
+	for i := range users {
+		m.users[users[i].ID] = &users[i]
+	}
+	return m
+}
+
+func (m *mockUserStore) FindOrCreateUser(ctx context.Context, identity *model.Identity) (*model.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockUserStore) LinkIdentity(ctx context.Context, userId int, identity *model.Identity) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockUserStore) FetchUser(ctx context.Context, id int) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return nil, errors.New("No record found")
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (m *mockUserStore) ListUsers(ctx context.Context) ([]model.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockUserStore) SetUserRole(ctx context.Context, id int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[id].Role = role
+	return nil
+}
+
+func (m *mockUserStore) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+	m.users[id].DisabledAt = disabledAt
+	return nil
+}
+
+func (m *mockUserStore) SetUserTimezone(ctx context.Context, id int, timezone string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[id].Timezone = timezone
+	return nil
+}
+
+func (m *mockUserStore) ListIdentities(ctx context.Context, userId int) ([]model.Identity, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockUserStore) DeleteIdentity(ctx context.Context, id int, userId int) error {
+	return errors.New("not implemented")
+}
+
+func (m *mockUserStore) DeleteUser(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.users, id)
+	return nil
+}
+
+func TestRequireRole(t *testing.T) {
+	setupTestJWT(t)
+	now := time.Now()
+	Users = newMockUserStore(
+		model.User{ID: 1, Role: RoleAdmin},
+		model.User{ID: 2, Role: RoleUser},
+		model.User{ID: 3, Role: RoleAdmin, DisabledAt: &now},
+	)
+	store := &mockAccessTokenStore{}
+	AccessTokens = store
+	defer func() { Users, AccessTokens = nil, nil }()
+
+	admin, _ := CreateJWT("1", "github")
+	user, _ := CreateJWT("2", "github")
+	disabled, _ := CreateJWT("3", "github")
+	script, _ := CreatePersonalAccessToken(context.Background(), "1", "backup", []string{ScopeTasksRead}, nil)
+	adminScript, err := CreatePersonalAccessToken(context.Background(), "1", "audit", []string{ScopeAdmin}, nil)
+	if err != nil {
+		t.Fatalf("failed to create admin token: %v", err)
+	}
+	if _, err := CreatePersonalAccessToken(context.Background(), "2", "audit", []string{ScopeAdmin}, nil); err == nil {
+		t.Errorf("CreatePersonalAccessToken() with the admin scope should fail for users")
+	}
+
+	handler := ValidateJWT(RequireRole(RoleAdmin, func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "admin", token: admin, status: http.StatusOK},
+		{name: "user", token: user, status: http.StatusForbidden},
+		{name: "disabled admin", token: disabled, status: http.StatusForbidden},
+		{name: "admin token without admin scope", token: script.Token, status: http.StatusForbidden},
+		{name: "admin token with admin scope", token: adminScript.Token, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, req)
+			if got := writer.Result().StatusCode; got != tt.status {
+				t.Errorf("RequireRole() = %v, want %v", got, tt.status)
+			}
+		})
+	}
+}
+
+func TestDisableUser(t *testing.T) {
+	setupTestJWT(t)
+	Users = newMockUserStore(model.User{ID: 1, Role: RoleAdmin}, model.User{ID: 2, Role: RoleUser})
+	AccessTokens = &mockAccessTokenStore{}
+	RefreshTokens = &mockRefreshTokenStore{}
+	Revocations = newMockRevocationStore()
+	defer func() { Users, AccessTokens, Revocations = nil, nil, nil }()
+	ctx := context.Background()
+
+	script, _ := CreatePersonalAccessToken(ctx, "2", "backup", nil, nil)
+	if err := DisableUser(ctx, "1", "1"); !errors.Is(err, ErrSelfAdministration) {
+		t.Errorf("DisableUser() of itself error = %v, want %v", err, ErrSelfAdministration)
+	}
+	if err := SetRole(ctx, "1", "2", "owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("SetRole() with unknown role error = %v, want %v", err, ErrInvalidRole)
+	}
+
+	if err := DisableUser(ctx, "1", "2"); err != nil {
+		t.Fatalf("failed to disable user: %v", err)
+	}
+	if _, err := IssueTokens(ctx, "2", "github"); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("IssueTokens() of disabled user error = %v, want %v", err, ErrUserDisabled)
+	}
+	if _, err := ParsePersonalAccessToken(ctx, script.Token); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("ParsePersonalAccessToken() of disabled user error = %v, want %v", err, ErrInvalidAccessToken)
+	}
+
+	if err := EnableUser(ctx, "2"); err != nil {
+		t.Fatalf("failed to enable user: %v", err)
+	}
+	if _, err := IssueTokens(ctx, "2", "github"); err != nil {
+		t.Errorf("IssueTokens() of enabled user failed: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopeTasksRead   = "tasks:read"
+	ScopeTasksWrite  = "tasks:write"
+	ScopeTasksDelete = "tasks:delete"
+	ScopeAdmin       = "admin"
+)
+
+// sessionScopes are granted to the tokens issued by a login
+var sessionScopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeTasksDelete}
+
+// Scopes returns the scopes granted to the token. Tokens issued before scopes
+// existed carry no scope claim and keep the scopes of a login.
+func (c *Claims) Scopes() []string {
+	if c.Scope == "" {
+		return sessionScopes
+	}
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether the token validated by ValidateJWT was granted scope
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, _ := ScopesFromContext(ctx)
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenError is the body of the 403 answers of the middlewares, scope is only set when a scope is missing
+type forbiddenError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Scope   string `json:"scope,omitempty"`
+}
+
+// writeForbidden responds 403 with body
+func writeForbidden(w http.ResponseWriter, body *forbiddenError) {
+	data, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write(data)
+}
+
+// RequireScope only calls next when the token validated by ValidateJWT was granted scope,
+// otherwise it responds 403 with the missing scope. It must be wrapped by ValidateJWT.
+func RequireScope(scope string, next func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if HasScope(r.Context(), scope) {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+		writeForbidden(w, &forbiddenError{
+			Error:   "insufficient_scope",
+			Message: fmt.Sprintf("token is missing the %s scope", scope),
+			Scope:   scope,
+		})
+	}
+}
+
+// RequireLogin only calls next for the tokens issued by a login, personal access tokens carry no
+// claims and are answered 403 as they can not manage the account. It must be wrapped by ValidateJWT.
+func RequireLogin(next func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := ClaimsFromContext(r.Context()); ok {
+			next(w, r)
+			return
+		}
+
+		writeForbidden(w, &forbiddenError{
+			Error:   "login_required",
+			Message: "personal access tokens can not be used here, please login",
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireScope(t *testing.T) {
+	setupTestJWT(t)
+	AccessTokens = &mockAccessTokenStore{}
+	ctx := context.Background()
+
+	session, _ := CreateJWT("7", "github")
+	readOnly, _ := CreatePersonalAccessToken(ctx, "7", "dashboard", []string{ScopeTasksRead}, nil)
+	readWrite, _ := CreatePersonalAccessToken(ctx, "7", "ci", []string{ScopeTasksRead, ScopeTasksWrite}, nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		scope  string
+		status int
+	}{
+		{name: "session can read", token: session, scope: ScopeTasksRead, status: http.StatusOK},
+		{name: "session can delete", token: session, scope: ScopeTasksDelete, status: http.StatusOK},
+		{name: "session is not admin", token: session, scope: ScopeAdmin, status: http.StatusForbidden},
+		{name: "read only token can read", token: readOnly.Token, scope: ScopeTasksRead, status: http.StatusOK},
+		{name: "read only token can not write", token: readOnly.Token, scope: ScopeTasksWrite, status: http.StatusForbidden},
+		{name: "write token can write", token: readWrite.Token, scope: ScopeTasksWrite, status: http.StatusOK},
+		{name: "write token can not delete", token: readWrite.Token, scope: ScopeTasksDelete, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ValidateJWT(RequireScope(tt.scope, func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/todolist", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, req)
+
+			if got := writer.Result().StatusCode; got != tt.status {
+				t.Fatalf("RequireScope() = %v, want %v", got, tt.status)
+			}
+			if tt.status != http.StatusForbidden {
+				return
+			}
+			body := &forbiddenError{}
+			if err := json.NewDecoder(writer.Body).Decode(body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Scope != tt.scope {
+				t.Errorf("RequireScope() missing scope = %v, want %v", body.Scope, tt.scope)
+			}
+		})
+	}
+}
+
+func TestRequireLogin(t *testing.T) {
+	setupTestJWT(t)
+	AccessTokens = &mockAccessTokenStore{}
+	ctx := context.Background()
+
+	session, _ := CreateJWT("7", "github")
+	token, _ := CreatePersonalAccessToken(ctx, "7", "ci", []string{ScopeTasksRead, ScopeTasksWrite, ScopeTasksDelete}, nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "session", token: session, status: http.StatusOK},
+		{name: "personal access token", token: token.Token, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ValidateJWT(RequireLogin(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/auth/tokens", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, req)
+
+			if got := writer.Result().StatusCode; got != tt.status {
+				t.Fatalf("RequireLogin() = %v, want %v", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+const (
+	// SessionCookieName holds the access token of a browser session
+	SessionCookieName = "todo_session"
+	// RefreshCookieName holds the refresh token of a browser session, it is only sent to /auth
+	RefreshCookieName = "todo_refresh"
+	// CSRFCookieName holds the CSRF token, it is readable by javascript so it can be sent back in CSRFHeaderName
+	CSRFCookieName = "todo_csrf"
+	// CSRFHeaderName must carry the CSRF token on unsafe requests authenticated by the session cookie
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// ErrInvalidCSRFToken is returned when an unsafe request of a browser session has no valid CSRF token
+var ErrInvalidCSRFToken = errors.New("invalid csrf token")
+
+// sessionConfig holds the browser session settings set by SetupSessions
+var sessionConfig *config.SessionConfig
+
+// SetupSessions sets the browser session mode, it needs SetupCookies and SetupJWT
+func SetupSessions(conf *config.SessionConfig) error {
+	if conf == nil {
+		return errors.New("session config can not be nil")
+	}
+	if conf.SameSite != "lax" && conf.SameSite != "strict" {
+		return fmt.Errorf("session same site must be lax or strict, got %s", conf.SameSite)
+	}
+	sessionConfig = conf
+	return nil
+}
+
+// SessionsEnabled reports whether logins start cookie based browser sessions
+func SessionsEnabled() bool {
+	return sessionConfig != nil && sessionConfig.Enabled
+}
+
+// SessionRedirectURL is where the browser is sent after a login started a session
+func SessionRedirectURL() string {
+	if sessionConfig == nil {
+		return "/"
+	}
+	return sessionConfig.RedirectURL
+}
+
+// SetSessionCookies stores a token pair in HttpOnly cookies together with the CSRF token of the access token
+func SetSessionCookies(w http.ResponseWriter, tokens *TokenPair) error {
+	if !SessionsEnabled() || cookieConfig == nil {
+		return errors.New("sessions are not configured")
+	}
+	claims, err := ParseJWT(tokens.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	setSessionCookie(w, SessionCookieName, tokens.AccessToken, "/", jwtConfig.TTL, true)
+	setSessionCookie(w, RefreshCookieName, tokens.RefreshToken, "/auth", jwtConfig.RefreshTTL, true)
+	setSessionCookie(w, CSRFCookieName, CSRFToken(claims), "/", jwtConfig.RefreshTTL, false)
+	return nil
+}
+
+// ClearSessionCookies ends the browser session
+func ClearSessionCookies(w http.ResponseWriter) {
+	setSessionCookie(w, SessionCookieName, "", "/", -1, true)
+	setSessionCookie(w, RefreshCookieName, "", "/auth", -1, true)
+	setSessionCookie(w, CSRFCookieName, "", "/", -1, false)
+}
+
+// RefreshTokenFromCookie returns the refresh token of the browser session, or an empty string.
+// The CSRF cookie has to be sent back in CSRFHeaderName, since the access token may have expired
+// the double submitted value is compared.
+func RefreshTokenFromCookie(r *http.Request) (string, error) {
+	if !SessionsEnabled() {
+		return "", nil
+	}
+	refresh, err := r.Cookie(RefreshCookieName)
+	if err != nil || refresh.Value == "" {
+		return "", nil
+	}
+	csrf, err := r.Cookie(CSRFCookieName)
+	if err != nil || csrf.Value == "" || !hmac.Equal([]byte(csrf.Value), []byte(r.Header.Get(CSRFHeaderName))) {
+		return "", ErrInvalidCSRFToken
+	}
+	return refresh.Value, nil
+}
+
+// CSRFToken returns the CSRF token bound to the access token of claims
+func CSRFToken(claims *Claims) string {
+	return signCookie("csrf", claims.Id)
+}
+
+// sessionToken returns the access token of the session cookie, or an empty string
+func sessionToken(r *http.Request) string {
+	if !SessionsEnabled() {
+		return ""
+	}
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// checkCSRF verifies the CSRF token of unsafe requests authenticated by the session cookie
+func checkCSRF(r *http.Request, claims *Claims) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	if cookieConfig == nil || !hmac.Equal([]byte(r.Header.Get(CSRFHeaderName)), []byte(CSRFToken(claims))) {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+func setSessionCookie(w http.ResponseWriter, name string, value string, path string, maxAge time.Duration, httpOnly bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		HttpOnly: httpOnly,
+		Secure:   cookieConfig != nil && cookieConfig.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if sessionConfig != nil && sessionConfig.SameSite == "strict" {
+		cookie.SameSite = http.SameSiteStrictMode
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = time.Now().Add(maxAge)
+		cookie.MaxAge = int(maxAge.Seconds())
+	}
+	http.SetCookie(w, cookie)
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+func TestSessionCookies(t *testing.T) {
+	setupTestJWT(t)
+	if err := SetupCookies(&config.CookieConfig{Secret: []byte("a-cookie-secret-that-is-long-enough!"), Secure: true}); err != nil {
+		t.Fatalf("failed to setup cookies: %v", err)
+	}
+	if err := SetupSessions(&config.SessionConfig{Enabled: true, RedirectURL: "/app", SameSite: "strict"}); err != nil {
+		t.Fatalf("failed to setup sessions: %v", err)
+	}
+	defer func() { sessionConfig = nil }()
+	RefreshTokens = &mockRefreshTokenStore{}
+
+	tokens, _ := IssueTokens(context.Background(), "7", "github")
+	recorder := httptest.NewRecorder()
+	if err := SetSessionCookies(recorder, tokens); err != nil {
+		t.Fatalf("failed to set session cookies: %v", err)
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range recorder.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	session, csrf := cookies[SessionCookieName], cookies[CSRFCookieName]
+	if session == nil || !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("unexpected session cookie %+v", session)
+	}
+	if refresh := cookies[RefreshCookieName]; refresh == nil || refresh.Path != "/auth" {
+		t.Errorf("unexpected refresh cookie %+v", refresh)
+	}
+	if csrf == nil || csrf.HttpOnly {
+		t.Fatalf("the csrf cookie has to be readable by the page, got %+v", csrf)
+	}
+
+	handler := ValidateJWT(func(w http.ResponseWriter, r *http.Request) {})
+	status := func(method string, csrfHeader string) int {
+		req := httptest.NewRequest(method, "/todolist", nil)
+		req.AddCookie(session)
+		if csrfHeader != "" {
+			req.Header.Set(CSRFHeaderName, csrfHeader)
+		}
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, req)
+		return writer.Result().StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		csrf   string
+		want   int
+	}{
+		{name: "safe method needs no csrf token", method: http.MethodGet, want: http.StatusOK},
+		{name: "unsafe method without csrf token", method: http.MethodPost, want: http.StatusForbidden},
+		{name: "unsafe method with wrong csrf token", method: http.MethodDelete, csrf: "wrong", want: http.StatusForbidden},
+		{name: "unsafe method with csrf token", method: http.MethodPost, csrf: csrf.Value, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status(tt.method, tt.csrf); got != tt.want {
+				t.Errorf("ValidateJWT() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the cookie is ignored once sessions are turned off
+	sessionConfig.Enabled = false
+	if got := status(http.MethodGet, ""); got != http.StatusUnauthorized {
+		t.Errorf("ValidateJWT() with sessions disabled = %v, want %v", got, http.StatusUnauthorized)
+	}
+}
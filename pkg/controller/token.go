@@ -1,26 +1,125 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/utils"
 	"github.com/golang-jwt/jwt"
 )
 
-var SECRET []byte = make([]byte, 0)
+// minSecretLength is the minimum length of the HMAC signing key
+const minSecretLength = 32
 
-// CreateJWT creates JWT token
-func CreateJWT(secret []byte) (string, error) {
+// jwtConfig is the server side signing configuration set by SetupJWT
+var jwtConfig *config.JWTConfig
 
-	token := jwt.New(jwt.SigningMethodHS256)
+// keys holds the signing and verification keys set by SetupJWT
+var keys *KeyRing
 
-	claims := token.Claims.(jwt.MapClaims)
+type contextKey string
 
-	claims["exp"] = time.Now().Add(time.Hour).Unix()
+const (
+	userIDKey contextKey = "userId"
+	claimsKey contextKey = "claims"
+	scopesKey contextKey = "scopes"
+)
+
+// Claims are the claims carried by todo-list access tokens
+type Claims struct {
+	Provider string `json:"provider"`
+	// Scope is the space separated list of scopes granted to the token
+	Scope string `json:"scope,omitempty"`
+	// MFAAt is when the user last proved a second factor, unset when it did not
+	MFAAt int64 `json:"mfa_at,omitempty"`
+	// IssuedAtNano is IssuedAt in nanoseconds, so a token issued right after its user was revoked
+	// in the same second is not denied. Tokens issued before it existed only have IssuedAt.
+	IssuedAtNano int64 `json:"iat_ns,omitempty"`
+	jwt.StandardClaims
+}
+
+// issuedAt returns when the token was issued, as precisely as the token tells
+func (c *Claims) issuedAt() time.Time {
+	if c.IssuedAtNano != 0 {
+		return time.Unix(0, c.IssuedAtNano)
+	}
+	return time.Unix(c.IssuedAt, 0)
+}
+
+// SetupJWT sets the signing keys, issuer and audience used by CreateJWT and ValidateJWT.
+// RS256 and ES256 keys are rotated every conf.KeyRotation when it is set.
+func SetupJWT(conf *config.JWTConfig) error {
+	if conf == nil {
+		return errors.New("jwt config can not be nil")
+	}
+
+	var ring *KeyRing
+	switch conf.Algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		if len(conf.Secret) < minSecretLength {
+			return fmt.Errorf("jwt secret must be at least %d bytes", minSecretLength)
+		}
+		ring = NewHMACKeyRing(conf.Secret)
+	default:
+		var err error
+		ring, err = NewKeyRing(conf.Algorithm, conf.KeysDir, conf.TTL)
+		if err != nil {
+			return err
+		}
+		if conf.KeyRotation > 0 {
+			ring.StartRotation(conf.KeyRotation)
+		}
+	}
+
+	jwtConfig = conf
+	keys = ring
+	return nil
+}
+
+// CreateJWT creates JWT token for the given userId and login provider ie google/fb/github
+func CreateJWT(userId string, provider string) (string, error) {
+	return createJWT(userId, provider, time.Time{})
+}
 
-	tokenStr, err := token.SignedString(secret)
-	SECRET = secret
+// createJWT creates the access token, mfaAt is set when the user just proved a second factor
+func createJWT(userId string, provider string, mfaAt time.Time) (string, error) {
+	if jwtConfig == nil {
+		return "", errors.New("jwt is not configured")
+	}
+
+	jti, err := utils.RandomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		Provider:     provider,
+		Scope:        strings.Join(sessionScopes, " "),
+		IssuedAtNano: now.UnixNano(),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   userId,
+			Issuer:    jwtConfig.Issuer,
+			Audience:  jwtConfig.Audience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(jwtConfig.TTL).Unix(),
+			Id:        jti,
+		},
+	}
+	if !mfaAt.IsZero() {
+		claims.MFAAt = mfaAt.Unix()
+	}
+
+	signer := keys.Signer()
+	token := jwt.NewWithClaims(signer.Method, claims)
+	token.Header["kid"] = signer.ID
+	tokenStr, err := token.SignedString(signer.Private)
 	if err != nil {
 		return "", err
 	}
@@ -28,31 +127,136 @@ func CreateJWT(secret []byte) (string, error) {
 	return tokenStr, nil
 }
 
-// ValidateJWT validates the token from Token header
+// ParseJWT parses tokenStr and verifies its signature, expiry, issuer and audience
+func ParseJWT(tokenStr string) (*Claims, error) {
+	if jwtConfig == nil {
+		return nil, errors.New("jwt is not configured")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %s", kid)
+		}
+		// the algorithm is bound to the key, never to the token header
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return key.Public, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if !claims.VerifyIssuer(jwtConfig.Issuer, true) {
+		return nil, errors.New("invalid issuer")
+	}
+	if !claims.VerifyAudience(jwtConfig.Audience, true) {
+		return nil, errors.New("invalid audience")
+	}
+	if claims.IssuedAt == 0 {
+		return nil, errors.New("token has no issued at")
+	}
+	if claims.Id == "" {
+		return nil, errors.New("token has no id")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	return claims, nil
+}
+
+// ValidateJWT validates the token from the Authorization header, or the session cookie of a
+// browser session, and stores the authenticated userId in the request context
 func ValidateJWT(next func(w http.ResponseWriter, r *http.Request)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		if r.Header["Authorization"] != nil {
-			authHeader := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-			token, err := jwt.Parse(authHeader, func(t *jwt.Token) (interface{}, error) {
-				_, ok := t.Method.(*jwt.SigningMethodHMAC)
-				if !ok {
-					w.WriteHeader(http.StatusUnauthorized)
-					w.Write([]byte("not authorized"))
-				}
-				return SECRET, nil
-			})
+		authHeader := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		fromCookie := false
+		if r.Header["Authorization"] == nil {
+			authHeader = sessionToken(r)
+			fromCookie = true
+		}
+		if authHeader == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("not authorized"))
+			return
+		}
+
+		// personal access tokens for scripts and CI
+		if !fromCookie && strings.HasPrefix(authHeader, PersonalAccessTokenPrefix) {
+			token, err := ParsePersonalAccessToken(r.Context(), authHeader)
 			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				w.Write([]byte("not authorized: " + err.Error()))
+				return
 			}
+			ctx := WithUserID(r.Context(), strconv.Itoa(token.UserID))
+			ctx = context.WithValue(ctx, scopesKey, token.Scopes)
+			next(w, r.WithContext(ctx))
+			return
+		}
 
-			if token.Valid {
-				next(w, r)
-			}
-		} else {
+		claims, err := ParseJWT(authHeader)
+		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("not authorized"))
+			w.Write([]byte("not authorized: " + err.Error()))
+			return
 		}
+
+		if err := checkRevoked(r.Context(), claims); err != nil {
+			if errors.Is(err, ErrTokenRevoked) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte("not authorized: " + err.Error()))
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("failed to check token: " + err.Error()))
+			return
+		}
+
+		// browsers send the session cookie on their own, unsafe requests have to prove they come from our page
+		if fromCookie {
+			if err := checkCSRF(r, claims); err != nil {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte("forbidden: " + err.Error()))
+				return
+			}
+		}
+
+		ctx := WithUserID(r.Context(), claims.Subject)
+		ctx = context.WithValue(ctx, claimsKey, claims)
+		ctx = context.WithValue(ctx, scopesKey, claims.Scopes())
+		next(w, r.WithContext(ctx))
 	})
 }
+
+// WithUserID returns a copy of ctx carrying the authenticated userId
+func WithUserID(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, userIDKey, userId)
+}
+
+// UserIDFromContext returns the authenticated userId stored by ValidateJWT
+func UserIDFromContext(ctx context.Context) string {
+	userId, _ := ctx.Value(userIDKey).(string)
+	return userId
+}
+
+// ClaimsFromContext returns the claims of the token validated by ValidateJWT
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// ScopesFromContext returns the scopes of the token validated by ValidateJWT,
+// ok is false when the request is not authenticated
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/golang-jwt/jwt"
+)
+
+func setupTestJWT(t *testing.T) *config.JWTConfig {
+	conf := &config.JWTConfig{
+		Secret:   []byte("a-test-secret-that-is-long-enough!!"),
+		Issuer:   "todo-list",
+		Audience: "todo-list",
+		TTL:      time.Hour,
+		// refresh tokens outlive the access tokens
+		RefreshTTL: 24 * time.Hour,
+	}
+	if err := SetupJWT(conf); err != nil {
+		t.Fatalf("failed to setup jwt: %v", err)
+	}
+	return conf
+}
+
+// signTestClaims signs claims with secret without any of the CreateJWT defaults
+func signTestClaims(t *testing.T, claims *Claims, secret []byte) string {
+	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return tokenStr
+}
+
+func TestSetupJWT(t *testing.T) {
+	if err := SetupJWT(&config.JWTConfig{Secret: []byte("short")}); err == nil {
+		t.Errorf("SetupJWT() with short secret should return an error")
+	}
+}
+
+func TestCreateJWT(t *testing.T) {
+	setupTestJWT(t)
+
+	tokenStr, err := CreateJWT("user-a", "github")
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	claims, err := ParseJWT(tokenStr)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if claims.Subject != "user-a" || claims.Provider != "github" {
+		t.Errorf("unexpected claims sub=%v provider=%v", claims.Subject, claims.Provider)
+	}
+	if claims.Issuer != "todo-list" || claims.Audience != "todo-list" {
+		t.Errorf("unexpected claims iss=%v aud=%v", claims.Issuer, claims.Audience)
+	}
+	if claims.Id == "" || claims.IssuedAt == 0 {
+		t.Errorf("expected jti and iat to be set, got jti=%v iat=%v", claims.Id, claims.IssuedAt)
+	}
+
+	other, _ := CreateJWT("user-b", "google")
+	if _, err := ParseJWT(tokenStr); err != nil {
+		t.Errorf("token should stay valid after another login: %v", err)
+	}
+	if other == tokenStr {
+		t.Errorf("expected different tokens for different users")
+	}
+}
+
+func TestValidateJWT(t *testing.T) {
+	conf := setupTestJWT(t)
+
+	tokenA, err := CreateJWT("user-a", "google")
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	now := time.Now()
+	valid := jwt.StandardClaims{
+		Subject:   "user-a",
+		Issuer:    conf.Issuer,
+		Audience:  conf.Audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+		Id:        "jti",
+	}
+
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "someone-else"
+	wrongAudience := valid
+	wrongAudience.Audience = "other-service"
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Minute).Unix()
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		userId string
+	}{
+		{
+			name:   "valid token",
+			header: "Bearer " + tokenA,
+			status: http.StatusOK,
+			userId: "user-a",
+		},
+		{
+			name:   "missing header",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "garbage token",
+			header: "Bearer abc",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong key",
+			header: "Bearer " + signTestClaims(t, &Claims{StandardClaims: valid}, []byte("another-secret-that-is-long-enough")),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong issuer",
+			header: "Bearer " + signTestClaims(t, &Claims{StandardClaims: wrongIssuer}, conf.Secret),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong audience",
+			header: "Bearer " + signTestClaims(t, &Claims{StandardClaims: wrongAudience}, conf.Secret),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "expired",
+			header: "Bearer " + signTestClaims(t, &Claims{StandardClaims: expired}, conf.Secret),
+			status: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserId string
+			handler := ValidateJWT(func(w http.ResponseWriter, r *http.Request) {
+				gotUserId = UserIDFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/todolist", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, req)
+
+			if writer.Result().StatusCode != tt.status {
+				t.Errorf("ValidateJWT() = %v, want %v", writer.Result().StatusCode, tt.status)
+				return
+			}
+			if gotUserId != tt.userId {
+				t.Errorf("ValidateJWT() userId = %v, want %v", gotUserId, tt.userId)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/totp"
+	"github.com/cfthoo/todo-app/pkg/utils"
+)
+
+const (
+	// recoveryCodeCount is the number of recovery codes handed out at once
+	recoveryCodeCount = 10
+	// maxTOTPAttempts invalid codes in a row lock the second factor for totpLockout
+	maxTOTPAttempts = 5
+	totpLockout     = 5 * time.Minute
+	// totpSkew accepts the code of one step before and after the current one for clock drift
+	totpSkew = 1
+	// mfaLoginTTL is how long a login waits for the second factor
+	mfaLoginTTL = 5 * time.Minute
+	// mfaLoginPurpose signs the mfa token of a login waiting for the second factor
+	mfaLoginPurpose = "mfa-login"
+)
+
+var (
+	// ErrSecondFactorRequired is returned when an action needs a fresh second factor
+	ErrSecondFactorRequired = errors.New("second factor required")
+	// ErrInvalidSecondFactor is returned for wrong, reused or expired codes
+	ErrInvalidSecondFactor = errors.New("invalid authentication code")
+	// ErrInvalidMFAToken is returned when completing a login with an unknown or expired mfa token
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token, please login again")
+	// ErrTooManyAttempts is returned while the second factor is locked after too many invalid codes
+	ErrTooManyAttempts = errors.New("too many invalid codes, please try again later")
+	// ErrTOTPNotEnabled is returned when the user has no confirmed authenticator
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrTOTPEnabled is returned when enrolling while an authenticator is already confirmed
+	ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+// TwoFactorStore is the TOTP and recovery code data access object
+type TwoFactorStore interface {
+	// FetchTOTP returns nil without an error when the user never enrolled
+	FetchTOTP(ctx context.Context, userId int) (*model.TOTPFactor, error)
+	SaveTOTP(ctx context.Context, userId int, secret string) error
+	ConfirmTOTP(ctx context.Context, userId int, step int64) error
+	UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error)
+	SetTOTPAttempts(ctx context.Context, userId int, attempts int, lockedUntil *time.Time) error
+	DeleteTOTP(ctx context.Context, userId int) error
+	ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error)
+}
+
+// TwoFactors stores the TOTP authenticators and recovery codes, it is set in main
+var TwoFactors TwoFactorStore
+
+// twoFactorConfig holds the issuer and freshness set by SetupTwoFactor
+var twoFactorConfig *config.TwoFactorConfig
+
+// SecondFactorChallenge is returned by the logins of users with two-factor authentication,
+// the login is completed by CompleteLogin with the MFAToken and a code
+type SecondFactorChallenge struct {
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+func (c *SecondFactorChallenge) Error() string {
+	return ErrSecondFactorRequired.Error()
+}
+
+func (c *SecondFactorChallenge) Unwrap() error {
+	return ErrSecondFactorRequired
+}
+
+// TOTPEnrollment is the secret of a new authenticator, URI is shown as a QR code
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// SetupTwoFactor sets the issuer shown in authenticator apps and how long a second factor stays fresh
+func SetupTwoFactor(conf *config.TwoFactorConfig) error {
+	if conf == nil {
+		return errors.New("two-factor config can not be nil")
+	}
+	if conf.Freshness <= 0 {
+		return errors.New("two-factor freshness must be positive")
+	}
+	twoFactorConfig = conf
+	return nil
+}
+
+// TwoFactorEnabled reports whether userId has a confirmed authenticator
+func TwoFactorEnabled(ctx context.Context, userId string) (bool, error) {
+	if TwoFactors == nil {
+		return false, nil
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return false, errors.New("invalid user id")
+	}
+	factor, err := TwoFactors.FetchTOTP(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return factor != nil && factor.ConfirmedAt != nil, nil
+}
+
+// EnrollTOTP creates a new authenticator secret for userId. It protects nothing until
+// ConfirmTOTP is called with a first code of the authenticator app.
+func EnrollTOTP(ctx context.Context, userId string) (*TOTPEnrollment, error) {
+	id, factor, err := fetchTOTP(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if factor != nil && factor.ConfirmedAt != nil {
+		return nil, ErrTOTPEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := TwoFactors.SaveTOTP(ctx, id, secret); err != nil {
+		return nil, err
+	}
+
+	account := "user " + userId
+	if Users != nil {
+		if user, err := Users.FetchUser(ctx, id); err == nil && user.Email != "" {
+			account = user.Email
+		}
+	}
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(twoFactorConfig.Issuer, account, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables the authenticator enrolled by EnrollTOTP with a first valid code and
+// returns the recovery codes, they are only shown this once
+func ConfirmTOTP(ctx context.Context, userId string, code string) ([]string, error) {
+	id, factor, err := fetchTOTP(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil {
+		return nil, ErrTOTPNotEnabled
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrTOTPEnabled
+	}
+
+	step, ok := totp.Validate(factor.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidSecondFactor
+	}
+	codes, err := replaceRecoveryCodes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := TwoFactors.ConfirmTOTP(ctx, id, step); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP removes the authenticator and the recovery codes of userId
+func DisableTOTP(ctx context.Context, userId string) error {
+	id, factor, err := fetchTOTP(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if factor == nil {
+		return ErrTOTPNotEnabled
+	}
+	return TwoFactors.DeleteTOTP(ctx, id)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of userId, the old ones stop working
+func RegenerateRecoveryCodes(ctx context.Context, userId string) ([]string, error) {
+	id, factor, err := fetchTOTP(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return nil, ErrTOTPNotEnabled
+	}
+	return replaceRecoveryCodes(ctx, id)
+}
+
+// IssueLoginTokens issues the tokens of a login of userId, whatever the provider it logged in with.
+// Users with two-factor authentication get a *SecondFactorChallenge error to answer with CompleteLogin.
+func IssueLoginTokens(ctx context.Context, userId string, provider string) (*TokenPair, error) {
+	enabled, err := TwoFactorEnabled(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, secondFactorChallenge(userId, provider)
+	}
+	return IssueTokens(ctx, userId, provider)
+}
+
+// CompleteLogin finishes a login that returned a SecondFactorChallenge
+func CompleteLogin(ctx context.Context, mfaToken string, code string) (*TokenPair, error) {
+	value, err := VerifyValue(mfaLoginPurpose, mfaToken)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	userId, provider, found := strings.Cut(value, ":")
+	if !found {
+		provider = LocalProvider
+	}
+	if err := verifySecondFactor(ctx, userId, code); err != nil {
+		return nil, err
+	}
+	return issueSecondFactorTokens(ctx, userId, provider)
+}
+
+// VerifySecondFactor checks a code of the logged in user and issues new tokens that
+// allow sensitive actions for the configured freshness
+func VerifySecondFactor(ctx context.Context, claims *Claims, code string) (*TokenPair, error) {
+	if err := verifySecondFactor(ctx, claims.Subject, code); err != nil {
+		return nil, err
+	}
+	return issueSecondFactorTokens(ctx, claims.Subject, claims.Provider)
+}
+
+// HasFreshSecondFactor reports whether the token validated by ValidateJWT proved a second
+// factor within the configured freshness
+func HasFreshSecondFactor(ctx context.Context) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.MFAAt == 0 || twoFactorConfig == nil {
+		return false
+	}
+	return time.Since(time.Unix(claims.MFAAt, 0)) <= twoFactorConfig.Freshness
+}
+
+// RequireSecondFactor only calls next when the user has no authenticator or proved it recently,
+// otherwise it responds 403 and the client has to verify a code first. It must be wrapped by ValidateJWT.
+func RequireSecondFactor(next func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enabled, err := TwoFactorEnabled(r.Context(), UserIDFromContext(r.Context()))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("failed to check second factor: " + err.Error()))
+			return
+		}
+		if !enabled || HasFreshSecondFactor(r.Context()) {
+			next(w, r)
+			return
+		}
+
+		writeForbidden(w, &forbiddenError{
+			Error:   "mfa_required",
+			Message: "please verify a code of your authenticator at /auth/2fa/verify first",
+		})
+	}
+}
+
+// secondFactorChallenge returns the challenge a login of userId with provider has to answer
+func secondFactorChallenge(userId string, provider string) error {
+	token, err := SignValue(mfaLoginPurpose, userId+":"+provider, mfaLoginTTL)
+	if err != nil {
+		return err
+	}
+	return &SecondFactorChallenge{
+		MFAToken:  token,
+		ExpiresIn: int64(mfaLoginTTL.Seconds()),
+	}
+}
+
+// verifySecondFactor accepts a code of the authenticator app or an unused recovery code of userId.
+// Too many invalid codes in a row lock the second factor for a while.
+func verifySecondFactor(ctx context.Context, userId string, code string) error {
+	id, factor, err := fetchTOTP(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return ErrTOTPNotEnabled
+	}
+	now := time.Now()
+	if factor.LockedUntil != nil && now.Before(*factor.LockedUntil) {
+		return ErrTooManyAttempts
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if step, ok := totp.Validate(factor.Secret, code, now, totpSkew); ok {
+		// a code can only be used once, a replay counts as an invalid code
+		used, err := TwoFactors.UseTOTPStep(ctx, id, step)
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	} else if len(code) != totp.Digits {
+		used, err := TwoFactors.UseRecoveryCode(ctx, id, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+		if used {
+			return TwoFactors.SetTOTPAttempts(ctx, id, 0, nil)
+		}
+	}
+
+	attempts := factor.FailedAttempts + 1
+	if attempts >= maxTOTPAttempts {
+		lockedUntil := now.Add(totpLockout)
+		if err := TwoFactors.SetTOTPAttempts(ctx, id, 0, &lockedUntil); err != nil {
+			return err
+		}
+		return ErrTooManyAttempts
+	}
+	if err := TwoFactors.SetTOTPAttempts(ctx, id, attempts, nil); err != nil {
+		return err
+	}
+	return ErrInvalidSecondFactor
+}
+
+// issueSecondFactorTokens starts a new login of userId marked with a fresh second factor
+func issueSecondFactorTokens(ctx context.Context, userId string, provider string) (*TokenPair, error) {
+	familyId, err := utils.RandomString(16)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, userId, provider, familyId, time.Now())
+}
+
+// fetchTOTP parses userId and returns the TOTP factor of the user, nil when it never enrolled
+func fetchTOTP(ctx context.Context, userId string) (int, *model.TOTPFactor, error) {
+	if TwoFactors == nil || twoFactorConfig == nil {
+		return 0, nil, errors.New("two-factor authentication is not configured")
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return 0, nil, errors.New("invalid user id")
+	}
+	factor, err := TwoFactors.FetchTOTP(ctx, id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, factor, nil
+}
+
+// replaceRecoveryCodes stores the hashes of new recovery codes for userId and returns the codes
+func replaceRecoveryCodes(ctx context.Context, userId int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	if err := TwoFactors.ReplaceRecoveryCodes(ctx, userId, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed without the dash or in upper case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/totp"
+)
+
+type mockTwoFactorStore struct {
+	mu       sync.Mutex
+	factors  map[int]*model.TOTPFactor
+	recovery map[int]map[string]bool
+}
+
+func newMockTwoFactorStore() *mockTwoFactorStore {
+	return &mockTwoFactorStore{factors: map[int]*model.TOTPFactor{}, recovery: map[int]map[string]bool{}}
+}
+
+func (m *mockTwoFactorStore) FetchTOTP(ctx context.Context, userId int) (*model.TOTPFactor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	factor, ok := m.factors[userId]
+	if !ok {
+		return nil, nil
+	}
+	copied := *factor
+	return &copied, nil
+}
+
+func (m *mockTwoFactorStore) SaveTOTP(ctx context.Context, userId int, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if factor, ok := m.factors[userId]; ok && factor.ConfirmedAt != nil {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	m.factors[userId] = &model.TOTPFactor{UserID: userId, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *mockTwoFactorStore) ConfirmTOTP(ctx context.Context, userId int, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.factors[userId].ConfirmedAt = &now
+	m.factors[userId].LastUsedStep = step
+	return nil
+}
+
+func (m *mockTwoFactorStore) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	factor := m.factors[userId]
+	if factor.LastUsedStep >= step {
+		return false, nil
+	}
+	factor.LastUsedStep = step
+	factor.FailedAttempts = 0
+	factor.LockedUntil = nil
+	return true, nil
+}
+
+func (m *mockTwoFactorStore) SetTOTPAttempts(ctx context.Context, userId int, attempts int, lockedUntil *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.factors[userId].FailedAttempts = attempts
+	m.factors[userId].LockedUntil = lockedUntil
+	return nil
+}
+
+func (m *mockTwoFactorStore) DeleteTOTP(ctx context.Context, userId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.factors, userId)
+	delete(m.recovery, userId)
+	return nil
+}
+
+func (m *mockTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recovery[userId] = map[string]bool{}
+	for _, hash := range codeHashes {
+		m.recovery[userId][hash] = true
+	}
+	return nil
+}
+
+func (m *mockTwoFactorStore) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.recovery[userId][codeHash] {
+		return false, nil
+	}
+	m.recovery[userId][codeHash] = false
+	return true, nil
+}
+
+// setupTestTwoFactor sets up local accounts and two-factor authentication with in memory stores
+func setupTestTwoFactor(t *testing.T) {
+	memory := setupTestAccounts(t)
+	if err := SetupCookies(&config.CookieConfig{Secret: []byte("a-cookie-secret-that-is-long-enough!")}); err != nil {
+		t.Fatalf("failed to setup cookies: %v", err)
+	}
+	if err := SetupTwoFactor(&config.TwoFactorConfig{Issuer: "todo-list", Freshness: 10 * time.Minute}); err != nil {
+		t.Fatalf("failed to setup two-factor: %v", err)
+	}
+	TwoFactors = newMockTwoFactorStore()
+	t.Cleanup(func() { TwoFactors = nil })
+
+	ctx := context.Background()
+	if err := Register(ctx, "me@example.com", "password123"); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if err := VerifyEmail(ctx, lastMailedToken(t, memory, "me@example.com")); err != nil {
+		t.Fatalf("failed to verify email: %v", err)
+	}
+}
+
+// codeAt returns the authenticator code of secret for the step of t
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	code, err := totp.Code(secret, totp.Step(at))
+	if err != nil {
+		t.Fatalf("failed to create code: %v", err)
+	}
+	return code
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	setupTestTwoFactor(t)
+	ctx := context.Background()
+
+	enrollment, err := EnrollTOTP(ctx, "100")
+	if err != nil {
+		t.Fatalf("failed to enroll: %v", err)
+	}
+	if enrollment.URI == "" || enrollment.Secret == "" {
+		t.Fatalf("unexpected enrollment %+v", enrollment)
+	}
+	// an unconfirmed authenticator does not protect the login yet
+	if _, err := Login(ctx, "me@example.com", "password123"); err != nil {
+		t.Fatalf("Login() before confirmation failed: %v", err)
+	}
+
+	if _, err := ConfirmTOTP(ctx, "100", "000000"); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Errorf("ConfirmTOTP() with wrong code error = %v, want %v", err, ErrInvalidSecondFactor)
+	}
+	// the confirmation uses the code of the previous step, so the current one is still unused
+	codes, err := ConfirmTOTP(ctx, "100", codeAt(t, enrollment.Secret, time.Now().Add(-totp.Period*time.Second)))
+	if err != nil {
+		t.Fatalf("failed to confirm: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("ConfirmTOTP() returned %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	if _, err := EnrollTOTP(ctx, "100"); !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("EnrollTOTP() when enabled error = %v, want %v", err, ErrTOTPEnabled)
+	}
+
+	_, err = Login(ctx, "me@example.com", "password123")
+	var challenge *SecondFactorChallenge
+	if !errors.As(err, &challenge) || !errors.Is(err, ErrSecondFactorRequired) {
+		t.Fatalf("Login() with two-factor error = %v, want a challenge", err)
+	}
+
+	if _, err := CompleteLogin(ctx, "forged", codeAt(t, enrollment.Secret, time.Now())); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("CompleteLogin() with forged token error = %v, want %v", err, ErrInvalidMFAToken)
+	}
+	code := codeAt(t, enrollment.Secret, time.Now())
+	tokens, err := CompleteLogin(ctx, challenge.MFAToken, code)
+	if err != nil {
+		t.Fatalf("failed to complete login: %v", err)
+	}
+	claims, err := ParseJWT(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if claims.Subject != "100" || claims.MFAAt == 0 {
+		t.Errorf("CompleteLogin() token sub = %v mfa_at = %v", claims.Subject, claims.MFAAt)
+	}
+	if _, err := CompleteLogin(ctx, challenge.MFAToken, code); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Errorf("CompleteLogin() with reused code error = %v, want %v", err, ErrInvalidSecondFactor)
+	}
+
+	// a recovery code works once
+	if _, err := CompleteLogin(ctx, challenge.MFAToken, codes[0]); err != nil {
+		t.Errorf("CompleteLogin() with recovery code failed: %v", err)
+	}
+	if _, err := CompleteLogin(ctx, challenge.MFAToken, codes[0]); !errors.Is(err, ErrInvalidSecondFactor) {
+		t.Errorf("CompleteLogin() with used recovery code error = %v, want %v", err, ErrInvalidSecondFactor)
+	}
+}
+
+func TestTwoFactorProviderLogin(t *testing.T) {
+	setupTestTwoFactor(t)
+	ctx := context.Background()
+
+	if _, err := IssueLoginTokens(ctx, "100", "github"); err != nil {
+		t.Fatalf("IssueLoginTokens() without two-factor failed: %v", err)
+	}
+	enrollment, err := EnrollTOTP(ctx, "100")
+	if err != nil {
+		t.Fatalf("failed to enroll: %v", err)
+	}
+	if _, err := ConfirmTOTP(ctx, "100", codeAt(t, enrollment.Secret, time.Now().Add(-totp.Period*time.Second))); err != nil {
+		t.Fatalf("failed to confirm: %v", err)
+	}
+
+	// a linked provider asks for the same code as the password login
+	_, err = IssueLoginTokens(ctx, "100", "github")
+	var challenge *SecondFactorChallenge
+	if !errors.As(err, &challenge) {
+		t.Fatalf("IssueLoginTokens() with two-factor error = %v, want a challenge", err)
+	}
+	tokens, err := CompleteLogin(ctx, challenge.MFAToken, codeAt(t, enrollment.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("failed to complete login: %v", err)
+	}
+	claims, err := ParseJWT(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	if claims.Subject != "100" || claims.Provider != "github" || claims.MFAAt == 0 {
+		t.Errorf("CompleteLogin() token sub = %v provider = %v mfa_at = %v", claims.Subject, claims.Provider, claims.MFAAt)
+	}
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	setupTestTwoFactor(t)
+	ctx := context.Background()
+
+	enrollment, _ := EnrollTOTP(ctx, "100")
+	if _, err := ConfirmTOTP(ctx, "100", codeAt(t, enrollment.Secret, time.Now().Add(-totp.Period*time.Second))); err != nil {
+		t.Fatalf("failed to confirm: %v", err)
+	}
+	claims := &Claims{Provider: LocalProvider}
+	claims.Subject = "100"
+
+	for i := 1; i < maxTOTPAttempts; i++ {
+		if _, err := VerifySecondFactor(ctx, claims, "wrong-code"); !errors.Is(err, ErrInvalidSecondFactor) {
+			t.Fatalf("VerifySecondFactor() attempt %d error = %v, want %v", i, err, ErrInvalidSecondFactor)
+		}
+	}
+	if _, err := VerifySecondFactor(ctx, claims, "wrong-code"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("VerifySecondFactor() last attempt error = %v, want %v", err, ErrTooManyAttempts)
+	}
+	// even the right code is refused while locked
+	if _, err := VerifySecondFactor(ctx, claims, codeAt(t, enrollment.Secret, time.Now())); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("VerifySecondFactor() while locked error = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func TestRequireSecondFactor(t *testing.T) {
+	setupTestTwoFactor(t)
+	ctx := context.Background()
+
+	handler := ValidateJWT(RequireSecondFactor(func(w http.ResponseWriter, r *http.Request) {}))
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/auth/tokens", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, req)
+		return writer.Result().StatusCode
+	}
+
+	// without an authenticator the login is enough
+	session, _ := CreateJWT("100", LocalProvider)
+	if got := status(session); got != http.StatusOK {
+		t.Errorf("RequireSecondFactor() without authenticator = %v, want %v", got, http.StatusOK)
+	}
+
+	enrollment, _ := EnrollTOTP(ctx, "100")
+	if _, err := ConfirmTOTP(ctx, "100", codeAt(t, enrollment.Secret, time.Now().Add(-totp.Period*time.Second))); err != nil {
+		t.Fatalf("failed to confirm: %v", err)
+	}
+	stale, _ := createJWT("100", LocalProvider, time.Now().Add(-time.Hour))
+	fresh, _ := createJWT("100", LocalProvider, time.Now())
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "login without second factor", token: session, want: http.StatusForbidden},
+		{name: "second factor too old", token: stale, want: http.StatusForbidden},
+		{name: "fresh second factor", token: fresh, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status(tt.token); got != tt.want {
+				t.Errorf("RequireSecondFactor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// linkTokenMaxAge is how long a user has to login with the provider being linked
+const linkTokenMaxAge = 10 * time.Minute
+
+// linkCookieName is the cookie binding a link token to the browser which asked for it
+const linkCookieName = "oauth_link"
+
+// UserStore is the user and identity data access object
+type UserStore interface {
+	FindOrCreateUser(ctx context.Context, identity *model.Identity) (*model.User, error)
+	LinkIdentity(ctx context.Context, userId int, identity *model.Identity) error
+	FetchUser(ctx context.Context, id int) (*model.User, error)
+	ListUsers(ctx context.Context) ([]model.User, error)
+	SetUserRole(ctx context.Context, id int, role string) error
+	SetUserDisabled(ctx context.Context, id int, disabled bool) error
+	SetUserTimezone(ctx context.Context, id int, timezone string) error
+	ListIdentities(ctx context.Context, userId int) ([]model.Identity, error)
+	DeleteIdentity(ctx context.Context, id int, userId int) error
+	DeleteUser(ctx context.Context, id int) error
+}
+
+// ErrInvalidTimezone is returned for a timezone which is not in the time zone database
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// Users stores the users and their provider identities, it is set in main
+var Users UserStore
+
+// LinkIdentity links a provider identity to userId, an identity of another user is not taken over
+func LinkIdentity(ctx context.Context, userId string, identity *model.Identity) error {
+	if Users == nil {
+		return errors.New("users are not configured")
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+	return Users.LinkIdentity(ctx, id, identity)
+}
+
+// DeleteAccount removes userId with its tasks and logins, the tokens issued to it stop working
+func DeleteAccount(ctx context.Context, userId string) error {
+	if Users == nil {
+		return errors.New("users are not configured")
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+
+	if err := Users.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	return RevokeUser(ctx, userId)
+}
+
+// SetTimezone changes the timezone of userId, it is an IANA time zone ie Europe/Berlin
+func SetTimezone(ctx context.Context, userId string, timezone string) error {
+	if Users == nil {
+		return errors.New("users are not configured")
+	}
+	id, err := strconv.Atoi(userId)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+	// an empty name is UTC for LoadLocation, it has to be given explicitly
+	if timezone == "" || timezone == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+
+	return Users.SetUserTimezone(ctx, id, timezone)
+}
+
+// CreateLinkToken returns a short lived token allowing userId to link the provider to its account.
+// The token is bound to the browser with a signed cookie, a link url sent to somebody else does not work.
+func CreateLinkToken(w http.ResponseWriter, userId string, provider string) (string, error) {
+	linkToken, err := SignValue("link:"+provider, userId, linkTokenMaxAge)
+	if err != nil {
+		return "", err
+	}
+	if err := SetSignedCookie(w, linkCookieName, linkToken, linkTokenMaxAge); err != nil {
+		return "", err
+	}
+	return linkToken, nil
+}
+
+// ParseLinkToken returns the userId of a token created by CreateLinkToken for the provider,
+// the request has to come from the browser the token was created for
+func ParseLinkToken(r *http.Request, linkToken string, provider string) (string, error) {
+	bound, err := ReadSignedCookie(r, linkCookieName)
+	if err != nil {
+		return "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(bound), []byte(linkToken)) != 1 {
+		return "", ErrInvalidCookie
+	}
+	return VerifyValue("link:"+provider, linkToken)
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+func TestSetTimezone(t *testing.T) {
+	store := newMockUserStore(model.User{ID: 1, Timezone: "UTC"})
+	Users = store
+	defer func() { Users = nil }()
+
+	for _, timezone := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		if err := SetTimezone(context.Background(), "1", timezone); !errors.Is(err, ErrInvalidTimezone) {
+			t.Errorf("SetTimezone(%q) error = %v, want %v", timezone, err, ErrInvalidTimezone)
+		}
+	}
+
+	if err := SetTimezone(context.Background(), "1", "Europe/Berlin"); err != nil {
+		t.Fatalf("SetTimezone() returned an error: %v", err)
+	}
+	if got := store.users[1].Timezone; got != "Europe/Berlin" {
+		t.Errorf("timezone = %v, want Europe/Berlin", got)
+	}
+}
+
+func TestLinkToken(t *testing.T) {
+	if err := SetupCookies(&config.CookieConfig{Secret: []byte("a-test-cookie-secret-long-enough!!")}); err != nil {
+		t.Fatalf("failed to setup cookies: %v", err)
+	}
+
+	writer := httptest.NewRecorder()
+	linkToken, err := CreateLinkToken(writer, "7", "google")
+	if err != nil {
+		t.Fatalf("failed to create link token: %v", err)
+	}
+	cookie := writer.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodGet, "/google/login", nil)
+	req.AddCookie(cookie)
+	if userId, err := ParseLinkToken(req, linkToken, "google"); err != nil || userId != "7" {
+		t.Errorf("ParseLinkToken() = %v, %v, want 7", userId, err)
+	}
+	if _, err := ParseLinkToken(req, linkToken, "github"); err == nil {
+		t.Errorf("expected the link token of another provider to be rejected")
+	}
+
+	// another browser opening the link url has no link cookie
+	other := httptest.NewRequest(http.MethodGet, "/google/login", nil)
+	if _, err := ParseLinkToken(other, linkToken, "google"); err == nil {
+		t.Errorf("expected the link token to be rejected without the link cookie")
+	}
+}
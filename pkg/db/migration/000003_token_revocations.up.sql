@@ -0,0 +1,14 @@
+-- Create the token_revocations table
+-- a row with a jti revokes that single token, a row without a jti revokes
+-- every token of user_id issued up to revoked_at
+CREATE TABLE token_revocations (
+  id SERIAL PRIMARY KEY,
+  jti TEXT UNIQUE,
+  user_id TEXT NOT NULL,
+  revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL
+);
+
+-- Add indexes on the user_id and expires_at columns of the token_revocations table
+CREATE INDEX token_revocations_user_id_idx ON token_revocations (user_id);
+CREATE INDEX token_revocations_expires_at_idx ON token_revocations (expires_at);
@@ -0,0 +1,3 @@
+UPDATE tasks SET created_by = regexp_replace(created_by, '^legacy:[^:]*:', '') WHERE created_by LIKE 'legacy:%';
+DROP TABLE IF EXISTS identities;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,33 @@
+-- Create the users table
+CREATE TABLE users (
+  id SERIAL PRIMARY KEY,
+  email TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  modified_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create the identities table, one row per provider account linked to a user
+CREATE TABLE identities (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  provider_user_id TEXT NOT NULL,
+  email TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (provider, provider_user_id)
+);
+
+-- Add an index on the user_id column of the identities table
+CREATE INDEX identities_user_id_idx ON identities (user_id);
+
+-- tasks used to be owned by the raw provider user id, mark them with their provider so they can not clash
+-- with the new user ids. They are handed over on the first login of that provider user. The provider is
+-- taken from the refresh tokens of the owner, tasks of an owner without exactly one provider (every owner
+-- when upgrading from the first release) are marked without it. Those go to the first login with the id,
+-- unless a user of another provider has the same id.
+UPDATE tasks SET created_by = 'legacy:' || (
+  SELECT min(provider) FROM refresh_tokens WHERE refresh_tokens.user_id = tasks.created_by
+) || ':' || created_by
+WHERE created_by IS NOT NULL
+  AND (SELECT count(DISTINCT provider) FROM refresh_tokens WHERE refresh_tokens.user_id = tasks.created_by) = 1;
+UPDATE tasks SET created_by = 'legacy::' || created_by WHERE created_by IS NOT NULL AND created_by NOT LIKE 'legacy:%';
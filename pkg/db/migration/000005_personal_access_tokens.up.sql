@@ -0,0 +1,15 @@
+-- Create the personal_access_tokens table, only the sha256 of a token is stored
+CREATE TABLE personal_access_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  scopes TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add an index on the user_id column of the personal_access_tokens table
+CREATE INDEX personal_access_tokens_user_id_idx ON personal_access_tokens (user_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS account_tokens;
+DROP TABLE IF EXISTS accounts;
+DELETE FROM identities WHERE provider = 'local';
@@ -0,0 +1,24 @@
+-- Create the accounts table, the password of a local identity. The email of the account is the
+-- provider_user_id of the identity, so removing the identity removes the password too.
+CREATE TABLE accounts (
+  identity_id INTEGER PRIMARY KEY REFERENCES identities (id) ON DELETE CASCADE,
+  password_hash TEXT NOT NULL,
+  email_verified_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  modified_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create the account_tokens table for email verification and password reset links,
+-- only the sha256 of a token is stored
+CREATE TABLE account_tokens (
+  id SERIAL PRIMARY KEY,
+  identity_id INTEGER NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+  purpose TEXT NOT NULL,
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add an index on the identity_id column of the account_tokens table
+CREATE INDEX account_tokens_identity_id_idx ON account_tokens (identity_id);
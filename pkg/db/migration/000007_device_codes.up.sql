@@ -0,0 +1,19 @@
+-- Create the device_codes table for the device authorization flow, only the sha256 of a device code is stored
+CREATE TABLE device_codes (
+  id SERIAL PRIMARY KEY,
+  device_code_hash TEXT NOT NULL UNIQUE,
+  user_code TEXT NOT NULL UNIQUE,
+  client_id TEXT NOT NULL DEFAULT '',
+  poll_interval INTEGER NOT NULL,
+  last_polled_at TIMESTAMP,
+  user_id INTEGER REFERENCES users (id) ON DELETE CASCADE,
+  provider TEXT,
+  approved_at TIMESTAMP,
+  denied_at TIMESTAMP,
+  used_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add an index on the expires_at column of the device_codes table
+CREATE INDEX device_codes_expires_at_idx ON device_codes (expires_at);
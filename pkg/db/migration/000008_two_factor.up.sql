@@ -0,0 +1,24 @@
+-- Create the totp_factors table, the TOTP authenticator of a user. The factor only protects the
+-- login once confirmed_at is set, last_used_step keeps a code from being used twice.
+CREATE TABLE totp_factors (
+  user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  secret TEXT NOT NULL,
+  confirmed_at TIMESTAMP,
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  failed_attempts INTEGER NOT NULL DEFAULT 0,
+  locked_until TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create the recovery_codes table, single use codes for a lost authenticator,
+-- only the sha256 of a code is stored
+CREATE TABLE recovery_codes (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add an index on the user_id column of the recovery_codes table
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
@@ -0,0 +1,3 @@
+-- Add the role and the disabled state to the users table, every existing user is a plain user
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
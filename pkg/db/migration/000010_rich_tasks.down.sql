@@ -0,0 +1,9 @@
+ALTER TABLE tasks ADD COLUMN complete BOOLEAN;
+UPDATE tasks SET complete = (status = 'done');
+
+DROP INDEX IF EXISTS tasks_due_at_idx;
+ALTER TABLE tasks DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS status;
+ALTER TABLE tasks DROP COLUMN IF EXISTS priority;
+ALTER TABLE tasks DROP COLUMN IF EXISTS due_at;
+ALTER TABLE tasks DROP COLUMN IF EXISTS description;
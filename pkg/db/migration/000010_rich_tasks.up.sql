@@ -0,0 +1,13 @@
+-- Add the markdown description, the due date, the priority and the status to the tasks table
+ALTER TABLE tasks ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN due_at TIMESTAMPTZ;
+ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+ALTER TABLE tasks ADD COLUMN completed_at TIMESTAMPTZ;
+
+-- the status supersedes the complete flag, completed tasks are done
+UPDATE tasks SET status = 'done', completed_at = modified_at WHERE complete = true;
+ALTER TABLE tasks DROP COLUMN complete;
+
+-- Add an index on the due_at column of the tasks table
+CREATE INDEX tasks_due_at_idx ON tasks (due_at);
@@ -0,0 +1,25 @@
+-- Create the lists table, every user has one inbox list
+CREATE TABLE lists (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  created_by TEXT NOT NULL,
+  inbox BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  modified_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add an index on the created_by column of the lists table
+CREATE INDEX lists_created_by_idx ON lists (created_by);
+
+-- Add a unique index so a user can only have one inbox
+CREATE UNIQUE INDEX lists_inbox_idx ON lists (created_by) WHERE inbox;
+
+-- tasks belong to a list, the tasks of a deleted list are deleted
+ALTER TABLE tasks ADD COLUMN list_id INTEGER REFERENCES lists (id) ON DELETE CASCADE;
+
+-- the existing tasks go to the inbox of their owner
+INSERT INTO lists (name, created_by, inbox) SELECT DISTINCT 'Inbox', created_by, true FROM tasks WHERE created_by IS NOT NULL;
+UPDATE tasks SET list_id = lists.id FROM lists WHERE lists.created_by = tasks.created_by AND lists.inbox;
+
+-- Add an index on the list_id column of the tasks table
+CREATE INDEX tasks_list_id_idx ON tasks (list_id);
@@ -0,0 +1,20 @@
+-- Create the labels table, label names are unique per user
+CREATE TABLE labels (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  color TEXT NOT NULL,
+  created_by TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  modified_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (created_by, name)
+);
+
+-- Create the task_labels table, one row per label attached to a task
+CREATE TABLE task_labels (
+  task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  label_id INTEGER NOT NULL REFERENCES labels (id) ON DELETE CASCADE,
+  PRIMARY KEY (task_id, label_id)
+);
+
+-- Add an index on the label_id column of the task_labels table
+CREATE INDEX task_labels_label_id_idx ON task_labels (label_id);
@@ -0,0 +1,5 @@
+-- tasks can have a parent task, subtasks are deleted with their parent unless they are moved up first
+ALTER TABLE tasks ADD COLUMN parent_id INTEGER REFERENCES tasks (id) ON DELETE CASCADE;
+
+-- Add an index on the parent_id column of the tasks table
+CREATE INDEX tasks_parent_id_idx ON tasks (parent_id);
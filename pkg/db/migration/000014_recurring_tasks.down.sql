@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS task_completions;
+ALTER TABLE tasks DROP COLUMN IF EXISTS occurrence;
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence;
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,19 @@
+-- the timezone of a user, the next occurrence of a recurring task is computed in it
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+
+-- recurrence is a RRULE, occurrence counts the occurrences of the series up to the current one
+ALTER TABLE tasks ADD COLUMN recurrence TEXT;
+ALTER TABLE tasks ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 1;
+
+-- Create the task_completions table, it keeps the completed and skipped occurrences of recurring tasks
+CREATE TABLE task_completions (
+  id SERIAL PRIMARY KEY,
+  task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  occurrence INTEGER NOT NULL,
+  due_at TIMESTAMPTZ,
+  completed_at TIMESTAMPTZ NOT NULL,
+  skipped BOOLEAN NOT NULL DEFAULT false
+);
+
+-- Add an index on the task_id column of the task_completions table
+CREATE INDEX task_completions_task_id_idx ON task_completions (task_id);
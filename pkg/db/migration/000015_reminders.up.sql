@@ -0,0 +1,22 @@
+-- Create the reminders table, a reminder fires at remind_at or offset_minutes from the due date of its task.
+-- The state of the scheduler is kept in the table so that reminders survive restarts.
+CREATE TABLE reminders (
+  id SERIAL PRIMARY KEY,
+  task_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  channel TEXT NOT NULL DEFAULT 'email',
+  remind_at TIMESTAMPTZ,
+  offset_minutes INTEGER,
+  -- the occurrence of the task the reminder fired for, a relative reminder fires again for the next occurrence
+  fired_occurrence INTEGER,
+  fired_at TIMESTAMPTZ,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  -- a reminder is not claimed again before locked_until, a failed delivery is retried after it
+  locked_until TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL,
+  modified_at TIMESTAMPTZ NOT NULL,
+  CHECK ((remind_at IS NULL) <> (offset_minutes IS NULL))
+);
+
+-- Add an index on the task_id column of the reminders table
+CREATE INDEX reminders_task_id_idx ON reminders (task_id);
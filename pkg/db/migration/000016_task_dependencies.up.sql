@@ -0,0 +1,11 @@
+-- Create the task_dependencies table, the blocker task blocks the blocked task until it is done or cancelled
+CREATE TABLE task_dependencies (
+  blocker_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  blocked_id INTEGER NOT NULL REFERENCES tasks (id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (blocker_id, blocked_id),
+  CHECK (blocker_id <> blocked_id)
+);
+
+-- Add an index on the blocked_id column of the task_dependencies table
+CREATE INDEX task_dependencies_blocked_id_idx ON task_dependencies (blocked_id);
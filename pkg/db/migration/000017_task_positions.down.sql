@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS tasks_list_id_position_idx;
+ALTER TABLE tasks DROP COLUMN IF EXISTS position;
@@ -0,0 +1,20 @@
+-- tasks are ordered by position in their list, positions are rank keys compared byte by byte
+ALTER TABLE tasks ADD COLUMN position TEXT COLLATE "C" NOT NULL DEFAULT '';
+
+-- the existing tasks keep the order of their ids, their keys are d followed by 4 base 62 digits
+WITH numbered AS (
+  SELECT id, row_number() OVER (PARTITION BY list_id ORDER BY id) - 1 AS n FROM tasks
+), base62 AS (
+  SELECT '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz'::TEXT AS digits
+)
+UPDATE tasks SET position = 'd'
+  || substr(digits, (n / 238328 % 62)::INTEGER + 1, 1)
+  || substr(digits, (n / 3844 % 62)::INTEGER + 1, 1)
+  || substr(digits, (n / 62 % 62)::INTEGER + 1, 1)
+  || substr(digits, (n % 62)::INTEGER + 1, 1)
+FROM numbered, base62 WHERE tasks.id = numbered.id;
+
+ALTER TABLE tasks ALTER COLUMN position DROP DEFAULT;
+
+-- Add an index on the list_id and position columns of the tasks table
+CREATE INDEX tasks_list_id_position_idx ON tasks (list_id, position);
@@ -0,0 +1,5 @@
+-- deleted tasks go to the trash, they are purged after the retention
+ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMP;
+
+-- Add an index on the deleted_at column of the tasks in the trash
+CREATE INDEX tasks_deleted_at_idx ON tasks (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- archived tasks are hidden from the task list, archiving is independent of the status
+ALTER TABLE tasks ADD COLUMN archived_at TIMESTAMP;
+
+-- Add an index on the completed_at column of the done tasks which are not archived yet
+CREATE INDEX tasks_completed_at_idx ON tasks (completed_at) WHERE status = 'done' AND archived_at IS NULL;
@@ -1,12 +1,293 @@
 package model
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
+
+// task statuses, a task is complete once it is done
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusBlocked    = "blocked"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+// task priorities from the lowest to the highest
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
 
 type Task struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Description is markdown
+	Description string `json:"description"`
+	CreatedBy   string `json:"created_by"`
+	ListID      int    `json:"list_id"`
+	// Position orders the task in its list, it is changed with its own route and ignored on input
+	Position string `json:"position"`
+	// ParentID is the task this task is a subtask of, it is read on create and later changed with its own route
+	ParentID *int `json:"parent_id"`
+	// LabelIDs are the labels attached to the task, they are set with their own route and ignored on input
+	LabelIDs []int64 `json:"label_ids"`
+	// BlockedBy are the tasks blocking this task, they are set with their own route and ignored on input.
+	// Blocked is true while one of them is not done or cancelled, it is independent of the blocked status.
+	BlockedBy []int64 `json:"blocked_by"`
+	Blocked   bool    `json:"blocked"`
+	Status    string  `json:"status"`
+	Priority  string  `json:"priority"`
+	// DueAt keeps the instant of the due date, it is sent with its offset ie 2023-05-01T18:00:00+08:00
+	DueAt *time.Time `json:"due_at"`
+	// Recurrence is a RRULE ie FREQ=WEEKLY;BYDAY=MO, a recurring task needs a due date.
+	// Completing it moves the due date to the next occurrence instead of finishing the task.
+	Recurrence string `json:"recurrence"`
+	// Occurrence is the number of the current occurrence of a recurring task, it is ignored on input
+	Occurrence int `json:"occurrence"`
+	// Complete is true when the status is done, it is kept for older clients and ignored on input
+	Complete    bool       `json:"complete"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// Archived hides the task from the task list unless archived tasks are asked for, it is independent of
+	// the status. They are set with their own route and ignored on input.
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ModifiedAt time.Time  `json:"modified_at"`
+	// DeletedAt is only set while the task is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Subtasks and Progress are only set in the tree response of the task list
+	Subtasks []Task    `json:"subtasks,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+}
+
+// Progress counts the done subtasks of a task at any depth, cancelled subtasks are not counted
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// CompleteOptions changes how MarkComplete completes a task
+type CompleteOptions struct {
+	// Cascade also completes the open subtasks
+	Cascade bool
+	// Skip moves a recurring task to its next occurrence without completing the current one
+	Skip bool
+	// EndSeries completes a recurring task without generating its next occurrence
+	EndSeries bool
+	// Force completes a task even while its blockers are open
+	Force bool
+}
+
+// reminder channels, a reminder is sent through the notifier of its channel
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelLog     = "log"
+)
+
+// Reminder notifies the owner of a task at RemindAt, or OffsetMinutes from the due date of the task
+type Reminder struct {
+	ID      int    `json:"id"`
+	TaskID  int    `json:"task_id"`
+	Channel string `json:"channel"`
+	// RemindAt and OffsetMinutes are exclusive, a negative offset reminds before the due date.
+	// A relative reminder of a recurring task fires for every occurrence.
+	RemindAt      *time.Time `json:"remind_at"`
+	OffsetMinutes *int       `json:"offset_minutes"`
+	// FireAt is when the reminder fires next, it is null once it fired or while the task has no due date
+	FireAt    *time.Time `json:"fire_at"`
+	FiredAt   *time.Time `json:"fired_at"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// DueReminder is a reminder claimed by the scheduler with the task and the user it reminds of
+type DueReminder struct {
+	Reminder
+	// Occurrence is the occurrence of the task the reminder fires for
+	Occurrence int
+	TaskName   string
+	DueAt      *time.Time
+	UserID     string
+	Email      string
+	Timezone   string
+}
+
+// TaskCompletion is a completed or skipped occurrence of a recurring task
+type TaskCompletion struct {
+	ID          int        `json:"id"`
+	TaskID      int        `json:"task_id"`
+	Occurrence  int        `json:"occurrence"`
+	DueAt       *time.Time `json:"due_at"`
+	CompletedAt time.Time  `json:"completed_at"`
+	Skipped     bool       `json:"skipped"`
+}
+
+// DeleteOptions changes how Delete deletes a task
+type DeleteOptions struct {
+	// Cascade also deletes the subtasks, otherwise they are moved up to the parent of the task
+	Cascade bool
+}
+
+// TaskFilter narrows the tasks returned by FetchAll, zero values do not filter
+type TaskFilter struct {
+	ListID int
+	// LabelIDs only returns the tasks with any of the labels, or with all of them when AllLabels is set
+	LabelIDs  []int64
+	AllLabels bool
+	// IncludeArchived also returns the archived tasks
+	IncludeArchived bool
+}
+
+// List groups the tasks of a user, every user has an inbox list which can not be deleted
+type List struct {
 	ID         int       `json:"id"`
 	Name       string    `json:"name"`
 	CreatedBy  string    `json:"created_by"`
-	Complete   bool      `json:"complete"`
+	Inbox      bool      `json:"inbox"`
 	CreatedAt  time.Time `json:"created_at"`
 	ModifiedAt time.Time `json:"modified_at"`
 }
+
+// Label is a colored tag, any number of labels can be attached to a task
+type Label struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Color is a hex color ie #e11d48
+	Color      string    `json:"color"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+var colorPattern = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
+// ValidColor reports whether color is a hex color like #e11d48
+func ValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// ValidStatus reports whether status is one of the task statuses
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusTodo, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// ValidPriority reports whether priority is one of the task priorities
+func ValidPriority(priority string) bool {
+	switch priority {
+	case PriorityNone, PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+		return true
+	}
+	return false
+}
+
+type RefreshToken struct {
+	ID        int        `json:"id"`
+	TokenHash string     `json:"-"`
+	FamilyID  string     `json:"family_id"`
+	UserID    string     `json:"user_id"`
+	Provider  string     `json:"provider"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type User struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	// Role is user or admin
+	Role string `json:"role"`
+	// DisabledAt is set while an admin disabled the user, it can not login
+	DisabledAt *time.Time `json:"disabled_at"`
+	// Timezone is an IANA time zone ie Europe/Berlin, recurring tasks follow its wall clock
+	Timezone   string    `json:"timezone"`
+	CreatedAt  time.Time `json:"created_at"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+type Identity struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	Provider       string    `json:"provider"`
+	ProviderUserID string    `json:"provider_user_id"`
+	Email          string    `json:"email"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type PersonalAccessToken struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id"`
+	Name      string `json:"name"`
+	TokenHash string `json:"-"`
+	// Token is only returned once, when the token is created
+	Token      string     `json:"token,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Account is a local email/password login, it belongs to the identity of the local provider
+type Account struct {
+	IdentityID      int        `json:"identity_id"`
+	UserID          int        `json:"user_id"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ModifiedAt      time.Time  `json:"modified_at"`
+}
+
+// AccountToken is a single use email verification or password reset token
+type AccountToken struct {
+	ID         int        `json:"id"`
+	IdentityID int        `json:"identity_id"`
+	Purpose    string     `json:"purpose"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// DeviceCode is a pending or approved device authorization
+type DeviceCode struct {
+	ID             int    `json:"id"`
+	DeviceCodeHash string `json:"-"`
+	UserCode       string `json:"user_code"`
+	ClientID       string `json:"client_id"`
+	// Interval is the minimum number of seconds between two polls
+	Interval     int        `json:"interval"`
+	LastPolledAt *time.Time `json:"last_polled_at"`
+	// UserID and Provider are set once a user approved the device
+	UserID     *int       `json:"user_id"`
+	Provider   string     `json:"provider"`
+	ApprovedAt *time.Time `json:"approved_at"`
+	DeniedAt   *time.Time `json:"denied_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TOTPFactor is the TOTP authenticator of a user, it protects the login once confirmed
+type TOTPFactor struct {
+	UserID      int        `json:"user_id"`
+	Secret      string     `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep is the time step of the last accepted code, a code can not be used twice
+	LastUsedStep   int64      `json:"-"`
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
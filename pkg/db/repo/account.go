@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// LocalProvider is the identity provider of email/password accounts
+const LocalProvider = "local"
+
+// ErrEmailTaken is returned when registering an email that already has an account
+var ErrEmailTaken = errors.New("an account with this email already exists")
+
+// selectAccount selects the columns read by scanAccount
+const selectAccount = "SELECT a.identity_id, i.user_id, i.provider_user_id, a.password_hash, a.email_verified_at, a.created_at, a.modified_at FROM accounts a JOIN identities i ON i.id=a.identity_id"
+
+// Accounts handles all of the local account database actions
+type Accounts struct {
+	DB *sql.DB
+}
+
+// CreateAccount creates a user with a local identity for email and its password hash
+func (a *Accounts) CreateAccount(ctx context.Context, email string, passwordHash string) (*model.Account, error) {
+	tx, err := a.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	statement := "SELECT EXISTS (SELECT 1 FROM identities WHERE provider=$1 and provider_user_id=$2)"
+	if err := tx.QueryRow(statement, LocalProvider, email).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEmailTaken
+	}
+
+	now := time.Now()
+	account := &model.Account{
+		Email:        email,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		ModifiedAt:   now,
+	}
+	statement = "INSERT INTO users (email, created_at, modified_at) VALUES ($1,$2,$3) RETURNING id"
+	if err := tx.QueryRow(statement, email, now, now).Scan(&account.UserID); err != nil {
+		return nil, err
+	}
+	statement = "INSERT INTO identities (user_id, provider, provider_user_id, email, created_at) VALUES ($1,$2,$3,$4,$5) RETURNING id"
+	if err := tx.QueryRow(statement, account.UserID, LocalProvider, email, email, now).Scan(&account.IdentityID); err != nil {
+		return nil, err
+	}
+	statement = "INSERT INTO accounts (identity_id, password_hash, created_at, modified_at) VALUES ($1,$2,$3,$4)"
+	if _, err := tx.Exec(statement, account.IdentityID, passwordHash, now, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// FetchAccount returns the local account of email
+func (a *Accounts) FetchAccount(ctx context.Context, email string) (*model.Account, error) {
+	statement := selectAccount + " WHERE i.provider=$1 and i.provider_user_id=$2"
+	return scanAccount(a.DB.QueryRow(statement, LocalProvider, email))
+}
+
+// FetchAccountByUser returns the local account of a user
+func (a *Accounts) FetchAccountByUser(ctx context.Context, userId int) (*model.Account, error) {
+	statement := selectAccount + " WHERE i.provider=$1 and i.user_id=$2 ORDER BY a.identity_id LIMIT 1"
+	return scanAccount(a.DB.QueryRow(statement, LocalProvider, userId))
+}
+
+// FetchAccountByIdentity returns the account of a local identity
+func (a *Accounts) FetchAccountByIdentity(ctx context.Context, identityId int) (*model.Account, error) {
+	statement := selectAccount + " WHERE a.identity_id=$1"
+	return scanAccount(a.DB.QueryRow(statement, identityId))
+}
+
+// UpdatePassword replaces the password hash of an account
+func (a *Accounts) UpdatePassword(ctx context.Context, identityId int, passwordHash string) error {
+	statement := "UPDATE accounts SET password_hash=$1, modified_at=$2 WHERE identity_id=$3"
+	res, err := a.DB.Exec(statement, passwordHash, time.Now(), identityId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of an account as verified
+func (a *Accounts) VerifyEmail(ctx context.Context, identityId int) error {
+	now := time.Now()
+	statement := "UPDATE accounts SET email_verified_at=COALESCE(email_verified_at, $1), modified_at=$1 WHERE identity_id=$2"
+	_, err := a.DB.Exec(statement, now, identityId)
+	return err
+}
+
+// CreateAccountToken will insert an email verification or password reset token into the database
+func (a *Accounts) CreateAccountToken(ctx context.Context, token *model.AccountToken) error {
+	if token == nil {
+		return errors.New("account token can not be nil")
+	}
+	token.CreatedAt = time.Now()
+	statement := "INSERT INTO account_tokens (identity_id, purpose, token_hash, expires_at, created_at) VALUES ($1,$2,$3,$4,$5) RETURNING id"
+	return a.DB.QueryRow(statement, token.IdentityID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+}
+
+// UseAccountToken marks an unused, unexpired token as used and returns it, a token can only be used once
+func (a *Accounts) UseAccountToken(ctx context.Context, purpose string, tokenHash string) (*model.AccountToken, error) {
+	now := time.Now()
+	token := &model.AccountToken{TokenHash: tokenHash, UsedAt: &now}
+	statement := "UPDATE account_tokens SET used_at=$1 WHERE token_hash=$2 and purpose=$3 and used_at IS NULL and expires_at > $1 RETURNING id, identity_id, purpose, expires_at, created_at"
+	err := a.DB.QueryRow(statement, now, tokenHash, purpose).Scan(&token.ID, &token.IdentityID, &token.Purpose, &token.ExpiresAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func scanAccount(row rowScanner) (*model.Account, error) {
+	account := &model.Account{}
+	err := row.Scan(&account.IdentityID, &account.UserID, &account.Email, &account.PasswordHash,
+		&account.EmailVerifiedAt, &account.CreatedAt, &account.ModifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAccounts_CreateAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	accounts := &Accounts{DB: db}
+
+	// a new email creates the user, its local identity and the password
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM identities WHERE provider=\\$1 and provider_user_id=\\$2\\)").
+		WithArgs("local", "me@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("^INSERT INTO users").
+		WithArgs("me@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("^INSERT INTO identities").
+		WithArgs(7, "local", "me@example.com", "me@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec("^INSERT INTO accounts").
+		WithArgs(3, "hash", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	account, err := accounts.CreateAccount(context.Background(), "me@example.com", "hash")
+	if err != nil {
+		t.Fatalf("CreateAccount returned an error: %v", err)
+	}
+	if account.UserID != 7 || account.IdentityID != 3 {
+		t.Errorf("expected user 7 and identity 3, got %d and %d", account.UserID, account.IdentityID)
+	}
+
+	// the same email can not register twice
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM identities WHERE provider=\\$1 and provider_user_id=\\$2\\)").
+		WithArgs("local", "me@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if _, err := accounts.CreateAccount(context.Background(), "me@example.com", "hash"); err != ErrEmailTaken {
+		t.Errorf("expected %v, got %v", ErrEmailTaken, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAccounts_UseAccountToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	accounts := &Accounts{DB: db}
+
+	// an used or expired token matches no row
+	mock.ExpectQuery("UPDATE account_tokens SET used_at=\\$1 WHERE token_hash=\\$2 and purpose=\\$3 and used_at IS NULL and expires_at > \\$1").
+		WithArgs(sqlmock.AnyArg(), "hash", "verify_email").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "identity_id", "purpose", "expires_at", "created_at"}))
+
+	if _, err := accounts.UseAccountToken(context.Background(), "verify_email", "hash"); err == nil {
+		t.Errorf("expected an error for an used token")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
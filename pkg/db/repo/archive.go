@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// SetArchived archives a task with its subtasks, or takes them out of the archive when archived is false
+func (t *TodoList) SetArchived(ctx context.Context, id int, archived bool, userId string) (*model.Task, error) {
+	now := time.Now()
+	var archivedAt *time.Time
+	if archived {
+		archivedAt = &now
+	}
+
+	statement := subtree + "UPDATE tasks SET archived_at=$3, modified_at=$4 WHERE id IN (SELECT id FROM subtree)"
+	res, err := t.DB.Exec(statement, id, userId, archivedAt, now)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, ErrNotFound
+	}
+	return t.FetchByID(ctx, id)
+}
+
+// archiveDone archives the done tasks selected by the %s condition with their subtasks, the same way
+// SetArchived does. Tasks with an open subtask, at any depth, are left out so nothing open is hidden.
+const archiveDone = `WITH RECURSIVE open_parents AS (
+	SELECT parent_id AS id FROM tasks WHERE parent_id IS NOT NULL and status NOT IN ('done', 'cancelled') and deleted_at IS NULL
+	UNION SELECT t.parent_id FROM tasks t JOIN open_parents o ON t.id=o.id WHERE t.parent_id IS NOT NULL
+), archived AS (
+	SELECT id FROM tasks WHERE %s and status='done' and archived_at IS NULL and deleted_at IS NULL
+		and id NOT IN (SELECT id FROM open_parents)
+	UNION SELECT t.id FROM tasks t JOIN archived a ON t.parent_id=a.id WHERE t.deleted_at IS NULL
+) UPDATE tasks SET archived_at=$1, modified_at=$1 WHERE id IN (SELECT id FROM archived) and archived_at IS NULL`
+
+// ArchiveCompleted archives the done tasks of a list without open subtasks, with their subtasks,
+// and returns how many were archived
+func (t *TodoList) ArchiveCompleted(ctx context.Context, listId int, userId string) (int64, error) {
+	statement := fmt.Sprintf(archiveDone, "list_id=$2 and created_by=$3")
+	res, err := t.DB.Exec(statement, time.Now(), listId, userId)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ArchiveCompletedBefore archives the tasks of every user which were completed before before, like ArchiveCompleted
+func (t *TodoList) ArchiveCompletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	statement := fmt.Sprintf(archiveDone, "completed_at < $2")
+	res, err := t.DB.Exec(statement, time.Now(), before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+func TestTodo_SetArchived(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	now := time.Now()
+	mock.ExpectExec("WITH RECURSIVE subtree (.+) UPDATE tasks SET archived_at=\\$3, modified_at=\\$4 WHERE id IN \\(SELECT id FROM subtree\\)").
+		WithArgs(1, "7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Task 1", "", "7", 2, "a0", nil, "{}", "{}", false, model.StatusDone, model.PriorityNone, nil, "", 1, now, now, now, nil, now))
+	task, err := todoList.SetArchived(context.Background(), 1, true, "7")
+	if err != nil {
+		t.Fatalf("SetArchived returned an error: %v", err)
+	}
+	if !task.Archived || task.ArchivedAt == nil {
+		t.Errorf("expected an archived task, got archived %v at %v", task.Archived, task.ArchivedAt)
+	}
+
+	// unarchiving clears archived_at
+	mock.ExpectExec("WITH RECURSIVE subtree (.+) UPDATE tasks SET archived_at=\\$3").
+		WithArgs(2, "7", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if _, err := todoList.SetArchived(context.Background(), 2, false, "7"); err == nil || err.Error() != "No record found" {
+		t.Errorf("SetArchived() of another user's task = %v, want No record found", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTodo_ArchiveCompleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	// done tasks with an open subtask are left out, the others are archived with their subtasks
+	mock.ExpectExec("WITH RECURSIVE open_parents AS (.+) SELECT id FROM tasks WHERE list_id=\\$2 and created_by=\\$3 and status='done' (.+) UPDATE tasks SET archived_at=\\$1, modified_at=\\$1 WHERE id IN \\(SELECT id FROM archived\\)").
+		WithArgs(sqlmock.AnyArg(), 2, "7").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	archived, err := todoList.ArchiveCompleted(context.Background(), 2, "7")
+	if err != nil || archived != 3 {
+		t.Errorf("ArchiveCompleted() = %d, %v, want 3 archived", archived, err)
+	}
+
+	before := time.Now().AddDate(0, 0, -14)
+	mock.ExpectExec("WITH RECURSIVE open_parents AS (.+) SELECT id FROM tasks WHERE completed_at < \\$2 and status='done' (.+) UPDATE tasks SET archived_at=\\$1, modified_at=\\$1 WHERE id IN \\(SELECT id FROM archived\\)").
+		WithArgs(sqlmock.AnyArg(), before).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	archived, err = todoList.ArchiveCompletedBefore(context.Background(), before)
+	if err != nil || archived != 5 {
+		t.Errorf("ArchiveCompletedBefore() = %d, %v, want 5 archived", archived, err)
+	}
+
+	// archived tasks are only listed when they are asked for
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE created_by=\\$1 and deleted_at IS NULL and list_id=\\$2 ORDER BY list_id, position, id").
+		WithArgs("7", 2).
+		WillReturnRows(sqlmock.NewRows(taskColumns))
+	if _, err := todoList.FetchAll(context.Background(), "7", model.TaskFilter{ListID: 2, IncludeArchived: true}); err != nil {
+		t.Errorf("FetchAll returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
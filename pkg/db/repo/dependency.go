@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDependencyCycle is returned when a task would block itself, directly or through the tasks it blocks
+	ErrDependencyCycle = errors.New("a task can not block itself or one of its blockers")
+	// ErrTaskBlocked is returned when completing a task whose blockers are not done or cancelled
+	ErrTaskBlocked = errors.New("task is blocked by open tasks")
+)
+
+// AddDependency makes blockerId block blockedId, both tasks have to belong to the user
+func (t *TodoList) AddDependency(ctx context.Context, blockerId int, blockedId int, userId string) error {
+	if blockerId == blockedId {
+		return ErrDependencyCycle
+	}
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the dependencies of a user are changed one at a time, two links can not close a cycle together
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "task_dependencies:"+userId); err != nil {
+		return err
+	}
+
+	var count int
+	statement := "SELECT count(*) FROM tasks WHERE id IN ($1,$2) and created_by=$3 and deleted_at IS NULL"
+	if err := tx.QueryRow(statement, blockerId, blockedId, userId).Scan(&count); err != nil {
+		return err
+	}
+	if count != 2 {
+		return ErrNotFound
+	}
+
+	// the blocked task can not already block the blocker, directly or through other tasks
+	var cycle bool
+	statement = `WITH RECURSIVE downstream AS (
+		SELECT blocked_id FROM task_dependencies WHERE blocker_id=$1
+		UNION SELECT d.blocked_id FROM task_dependencies d JOIN downstream s ON d.blocker_id=s.blocked_id
+	) SELECT EXISTS (SELECT 1 FROM downstream WHERE blocked_id=$2)`
+	if err := tx.QueryRow(statement, blockedId, blockerId).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return ErrDependencyCycle
+	}
+
+	statement = "INSERT INTO task_dependencies (blocker_id, blocked_id, created_at) VALUES ($1,$2,$3) ON CONFLICT DO NOTHING"
+	if _, err := tx.Exec(statement, blockerId, blockedId, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveDependency removes the link between blockerId and blockedId
+func (t *TodoList) RemoveDependency(ctx context.Context, blockerId int, blockedId int, userId string) error {
+	statement := "DELETE FROM task_dependencies WHERE blocker_id=$1 and blocked_id=$2 and blocked_id IN (SELECT id FROM tasks WHERE created_by=$3)"
+	res, err := t.DB.Exec(statement, blockerId, blockedId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// checkBlockers returns ErrTaskBlocked while a blocker of the task is not done or cancelled
+func checkBlockers(q rowQueryer, id int) error {
+	var blocked bool
+	statement := `SELECT EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id
+		WHERE d.blocked_id=$1 and b.status NOT IN ('done', 'cancelled') and b.deleted_at IS NULL)`
+	if err := q.QueryRow(statement, id).Scan(&blocked); err != nil {
+		return err
+	}
+	if blocked {
+		return ErrTaskBlocked
+	}
+	return nil
+}
+
+// checkSubtreeBlockers returns ErrTaskBlocked while an open subtask of the task has a blocker which is not done
+// or cancelled. Blockers inside the subtree are left out, completing the task completes them too.
+func checkSubtreeBlockers(q rowQueryer, id int, userId string) error {
+	var blocked bool
+	statement := subtree + `SELECT EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks t ON t.id=d.blocked_id JOIN tasks b ON b.id=d.blocker_id
+		WHERE t.id IN (SELECT id FROM subtree) and t.id<>$1 and t.status NOT IN ('done', 'cancelled')
+		and b.id NOT IN (SELECT id FROM subtree) and b.status NOT IN ('done', 'cancelled') and b.deleted_at IS NULL)`
+	if err := q.QueryRow(statement, id, userId).Scan(&blocked); err != nil {
+		return err
+	}
+	if blocked {
+		return ErrTaskBlocked
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+func TestTodo_AddDependency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	if err := todoList.AddDependency(context.Background(), 2, 2, "7"); err != ErrDependencyCycle {
+		t.Errorf("AddDependency() on itself = %v, want %v", err, ErrDependencyCycle)
+	}
+
+	// task 3 already blocks task 1 through task 2
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(hashtext\\(\\$1\\)\\)").
+		WithArgs("task_dependencies:7").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM tasks WHERE id IN \\(\\$1,\\$2\\) and created_by=\\$3").
+		WithArgs(1, 3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("WITH RECURSIVE downstream (.+) SELECT EXISTS \\(SELECT 1 FROM downstream WHERE blocked_id=\\$2\\)").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	if err := todoList.AddDependency(context.Background(), 1, 3, "7"); err != ErrDependencyCycle {
+		t.Errorf("AddDependency() closing a cycle = %v, want %v", err, ErrDependencyCycle)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").
+		WithArgs("task_dependencies:7").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM tasks").
+		WithArgs(3, 1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("WITH RECURSIVE downstream").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO task_dependencies \\(blocker_id, blocked_id, created_at\\) VALUES \\(\\$1,\\$2,\\$3\\) ON CONFLICT DO NOTHING").
+		WithArgs(3, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := todoList.AddDependency(context.Background(), 3, 1, "7"); err != nil {
+		t.Errorf("AddDependency returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTodo_MarkCompleteBlocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("", nil, 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if _, err := todoList.MarkComplete(context.Background(), 1, "7", model.CompleteOptions{}); err != ErrTaskBlocked {
+		t.Errorf("MarkComplete() with open blockers = %v, want %v", err, ErrTaskBlocked)
+	}
+
+	// forced, the blockers are not checked
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("", nil, 1))
+	mock.ExpectExec("UPDATE tasks SET status='done'").
+		WithArgs(1, "7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "ship", "", "7", 2, "a0", nil, "{}", "{4}", true, model.StatusDone, model.PriorityNone, nil, "", 1, now, now, now, nil, nil))
+
+	task, err := todoList.MarkComplete(context.Background(), 1, "7", model.CompleteOptions{Force: true})
+	if err != nil {
+		t.Fatalf("MarkComplete returned an error: %v", err)
+	}
+	if !task.Complete || !task.Blocked {
+		t.Errorf("MarkComplete() = %+v, want a complete task still blocked by task 4", task)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTodo_MarkCompleteCascadeBlocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+	now := time.Now()
+
+	// task 1 is not blocked but one of its open subtasks is
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("", nil, 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("WITH RECURSIVE subtree (.+) SELECT EXISTS \\(SELECT 1 FROM task_dependencies d JOIN tasks t ON t.id=d.blocked_id").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if _, err := todoList.MarkComplete(context.Background(), 1, "7", model.CompleteOptions{Cascade: true}); err != ErrTaskBlocked {
+		t.Errorf("MarkComplete() cascading to a blocked subtask = %v, want %v", err, ErrTaskBlocked)
+	}
+
+	// forced, the subtasks are completed anyway
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("", nil, 1))
+	mock.ExpectExec("WITH RECURSIVE subtree (.+) UPDATE tasks SET status='done'").
+		WithArgs(1, "7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "ship", "", "7", 2, "a0", nil, "{}", "{}", false, model.StatusDone, model.PriorityNone, nil, "", 1, now, now, now, nil, nil))
+
+	if _, err := todoList.MarkComplete(context.Background(), 1, "7", model.CompleteOptions{Cascade: true, Force: true}); err != nil {
+		t.Fatalf("MarkComplete returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// selectDeviceCode selects the columns read by scanDeviceCode
+const selectDeviceCode = "SELECT id, device_code_hash, user_code, client_id, poll_interval, last_polled_at, user_id, COALESCE(provider, ''), approved_at, denied_at, used_at, expires_at, created_at FROM device_codes"
+
+// DeviceCodes handles all of the device authorization database actions
+type DeviceCodes struct {
+	DB *sql.DB
+}
+
+// CreateDeviceCode will insert a pending device authorization into the database
+func (d *DeviceCodes) CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error {
+	if code == nil {
+		return errors.New("device code can not be nil")
+	}
+	code.CreatedAt = time.Now()
+	statement := "INSERT INTO device_codes (device_code_hash, user_code, client_id, poll_interval, expires_at, created_at) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id"
+	return d.DB.QueryRow(statement, code.DeviceCodeHash, code.UserCode, code.ClientID, code.Interval, code.ExpiresAt, code.CreatedAt).Scan(&code.ID)
+}
+
+// FetchDeviceCode returns a device authorization by the hash of its device code
+func (d *DeviceCodes) FetchDeviceCode(ctx context.Context, deviceCodeHash string) (*model.DeviceCode, error) {
+	statement := selectDeviceCode + " WHERE device_code_hash=$1"
+	return scanDeviceCode(d.DB.QueryRow(statement, deviceCodeHash))
+}
+
+// FetchDeviceCodeByUserCode returns a device authorization by the code the user entered
+func (d *DeviceCodes) FetchDeviceCodeByUserCode(ctx context.Context, userCode string) (*model.DeviceCode, error) {
+	statement := selectDeviceCode + " WHERE user_code=$1"
+	return scanDeviceCode(d.DB.QueryRow(statement, userCode))
+}
+
+// ApproveDeviceCode grants a pending device authorization to userId, it returns false when
+// the authorization is no longer pending
+func (d *DeviceCodes) ApproveDeviceCode(ctx context.Context, id int, userId int, provider string) (bool, error) {
+	now := time.Now()
+	statement := "UPDATE device_codes SET user_id=$1, provider=$2, approved_at=$3 WHERE id=$4 and approved_at IS NULL and denied_at IS NULL and expires_at > $3"
+	res, err := d.DB.Exec(statement, userId, provider, now, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DenyDeviceCode refuses a pending device authorization
+func (d *DeviceCodes) DenyDeviceCode(ctx context.Context, id int) error {
+	statement := "UPDATE device_codes SET denied_at=$1 WHERE id=$2 and approved_at IS NULL and denied_at IS NULL"
+	_, err := d.DB.Exec(statement, time.Now(), id)
+	return err
+}
+
+// TouchDeviceCode records a poll of the device and the interval it has to wait until the next one
+func (d *DeviceCodes) TouchDeviceCode(ctx context.Context, id int, polledAt time.Time, interval int) error {
+	statement := "UPDATE device_codes SET last_polled_at=$1, poll_interval=$2 WHERE id=$3"
+	_, err := d.DB.Exec(statement, polledAt, interval, id)
+	return err
+}
+
+// UseDeviceCode marks an approved device authorization as exchanged for tokens,
+// it returns false when the device code was already used
+func (d *DeviceCodes) UseDeviceCode(ctx context.Context, id int) (bool, error) {
+	statement := "UPDATE device_codes SET used_at=$1 WHERE id=$2 and used_at IS NULL and approved_at IS NOT NULL"
+	res, err := d.DB.Exec(statement, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// PruneDeviceCodes deletes the expired device authorizations
+func (d *DeviceCodes) PruneDeviceCodes(ctx context.Context) (int64, error) {
+	statement := "DELETE FROM device_codes WHERE expires_at < $1"
+	res, err := d.DB.Exec(statement, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanDeviceCode(row rowScanner) (*model.DeviceCode, error) {
+	code := &model.DeviceCode{}
+	err := row.Scan(&code.ID, &code.DeviceCodeHash, &code.UserCode, &code.ClientID, &code.Interval, &code.LastPolledAt,
+		&code.UserID, &code.Provider, &code.ApprovedAt, &code.DeniedAt, &code.UsedAt, &code.ExpiresAt, &code.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
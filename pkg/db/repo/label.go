@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/lib/pq"
+)
+
+// DefaultLabelColor is the color of labels created without one
+const DefaultLabelColor = "#808080"
+
+var (
+	// ErrLabelNotFound is returned when a label does not exist or belongs to another user
+	ErrLabelNotFound = errors.New("label not found")
+	// ErrLabelExists is returned when the user already has a label with the name
+	ErrLabelExists = errors.New("a label with this name already exists")
+	// ErrSameLabel is returned when merging a label into itself
+	ErrSameLabel = errors.New("a label can not be merged into itself")
+)
+
+// selectLabel selects the columns read by scanLabel
+const selectLabel = "SELECT id, name, color, created_by, created_at, modified_at FROM labels"
+
+// Labels handles all of the label database actions
+type Labels struct {
+	DB *sql.DB
+}
+
+// CreateLabel will insert a label into the database
+func (l *Labels) CreateLabel(ctx context.Context, label *model.Label) (*model.Label, error) {
+	if label == nil {
+		return nil, errors.New("label can not be nil")
+	}
+	if label.Color == "" {
+		label.Color = DefaultLabelColor
+	}
+	if err := checkLabelName(l.DB, label.Name, 0, label.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	statement := "INSERT INTO labels (name, color, created_by, created_at, modified_at) VALUES ($1,$2,$3,$4,$5) RETURNING id"
+	err := l.DB.QueryRow(statement, label.Name, label.Color, label.CreatedBy, now, now).Scan(&label.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	label.CreatedAt = now
+	label.ModifiedAt = now
+	return label, nil
+}
+
+// FetchLabels returns the labels of a user ordered by name
+func (l *Labels) FetchLabels(ctx context.Context, userId string) ([]model.Label, error) {
+	statement := selectLabel + " WHERE created_by=$1 ORDER BY name"
+	rows, err := l.DB.Query(statement, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []model.Label{}
+	for rows.Next() {
+		label, err := scanLabel(rows)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, *label)
+	}
+	return labels, nil
+}
+
+// UpdateLabel renames or recolors a label, the change applies to every task it is attached to.
+// An empty color keeps the current one.
+func (l *Labels) UpdateLabel(ctx context.Context, label *model.Label, userId string) (*model.Label, error) {
+	if err := checkLabelName(l.DB, label.Name, label.ID, userId); err != nil {
+		return nil, err
+	}
+
+	statement := "UPDATE labels SET name=$1, color=COALESCE(NULLIF($2, ''), color), modified_at=$3 WHERE id=$4 and created_by=$5"
+	res, err := l.DB.Exec(statement, label.Name, label.Color, time.Now(), label.ID, userId)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, ErrLabelNotFound
+	}
+
+	statement = selectLabel + " WHERE id=$1"
+	return scanLabel(l.DB.QueryRow(statement, label.ID))
+}
+
+// MergeLabels attaches label intoId to every task of label fromId and deletes fromId
+func (l *Labels) MergeLabels(ctx context.Context, fromId int, intoId int, userId string) error {
+	if fromId == intoId {
+		return ErrSameLabel
+	}
+
+	tx, err := l.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	statement := "SELECT count(*) FROM labels WHERE id IN ($1,$2) and created_by=$3"
+	if err := tx.QueryRow(statement, fromId, intoId, userId).Scan(&count); err != nil {
+		return err
+	}
+	if count != 2 {
+		return ErrLabelNotFound
+	}
+
+	statement = "INSERT INTO task_labels (task_id, label_id) SELECT task_id, $1 FROM task_labels WHERE label_id=$2 ON CONFLICT DO NOTHING"
+	if _, err := tx.Exec(statement, intoId, fromId); err != nil {
+		return err
+	}
+
+	// the task labels of fromId are removed by cascade
+	statement = "DELETE FROM labels WHERE id=$1"
+	if _, err := tx.Exec(statement, fromId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteLabel deletes a label and detaches it from its tasks
+func (l *Labels) DeleteLabel(ctx context.Context, id int, userId string) error {
+	statement := "DELETE FROM labels WHERE id=$1 and created_by=$2"
+	res, err := l.DB.Exec(statement, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrLabelNotFound
+	}
+	return nil
+}
+
+// SetTaskLabels replaces the labels attached to a task of the user
+func (l *Labels) SetTaskLabels(ctx context.Context, taskId int, labelIds []int64, userId string) error {
+	tx, err := l.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement := "UPDATE tasks SET modified_at=$1 WHERE id=$2 and created_by=$3 and deleted_at IS NULL"
+	res, err := tx.Exec(statement, time.Now(), taskId, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+
+	if len(labelIds) > 0 {
+		var count int
+		statement = "SELECT count(*) FROM labels WHERE id = ANY($1) and created_by=$2"
+		if err := tx.QueryRow(statement, pq.Array(labelIds), userId).Scan(&count); err != nil {
+			return err
+		}
+		if count != len(labelIds) {
+			return ErrLabelNotFound
+		}
+	}
+
+	statement = "DELETE FROM task_labels WHERE task_id=$1"
+	if _, err := tx.Exec(statement, taskId); err != nil {
+		return err
+	}
+	if len(labelIds) > 0 {
+		statement = "INSERT INTO task_labels (task_id, label_id) SELECT $1, unnest($2::integer[])"
+		if _, err := tx.Exec(statement, taskId, pq.Array(labelIds)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// checkLabelName makes sure the user has no other label with the name, id is the label being renamed
+func checkLabelName(db *sql.DB, name string, id int, userId string) error {
+	var exists bool
+	statement := "SELECT EXISTS (SELECT 1 FROM labels WHERE created_by=$1 and name=$2 and id<>$3)"
+	if err := db.QueryRow(statement, userId, name, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrLabelExists
+	}
+	return nil
+}
+
+func scanLabel(row rowScanner) (*model.Label, error) {
+	label := &model.Label{}
+	err := row.Scan(&label.ID, &label.Name, &label.Color, &label.CreatedBy, &label.CreatedAt, &label.ModifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return label, nil
+}
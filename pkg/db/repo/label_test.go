@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLabels_MergeLabels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	labels := &Labels{DB: db}
+
+	if err := labels.MergeLabels(context.Background(), 2, 2, "7"); err != ErrSameLabel {
+		t.Errorf("MergeLabels() into itself = %v, want %v", err, ErrSameLabel)
+	}
+
+	// label 3 belongs to another user
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM labels WHERE id IN \\(\\$1,\\$2\\) and created_by=\\$3").
+		WithArgs(2, 3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+	if err := labels.MergeLabels(context.Background(), 2, 3, "7"); err != ErrLabelNotFound {
+		t.Errorf("MergeLabels() into another user's label = %v, want %v", err, ErrLabelNotFound)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM labels WHERE id IN \\(\\$1,\\$2\\) and created_by=\\$3").
+		WithArgs(2, 1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO task_labels \\(task_id, label_id\\) SELECT task_id, \\$1 FROM task_labels WHERE label_id=\\$2 ON CONFLICT DO NOTHING").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("DELETE FROM labels WHERE id=\\$1").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := labels.MergeLabels(context.Background(), 2, 1, "7"); err != nil {
+		t.Errorf("MergeLabels returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLabels_SetTaskLabels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	labels := &Labels{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tasks SET modified_at=\\$1 WHERE id=\\$2 and created_by=\\$3").
+		WithArgs(sqlmock.AnyArg(), 5, "7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM labels WHERE id = ANY\\(\\$1\\) and created_by=\\$2").
+		WithArgs("{1,3}", "7").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectExec("DELETE FROM task_labels WHERE task_id=\\$1").
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO task_labels \\(task_id, label_id\\) SELECT \\$1, unnest").
+		WithArgs(5, "{1,3}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := labels.SetTaskLabels(context.Background(), 5, []int64{1, 3}, "7"); err != nil {
+		t.Errorf("SetTaskLabels returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
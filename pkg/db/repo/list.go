@@ -0,0 +1,249 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// InboxName is the name of the list created for every user
+const InboxName = "Inbox"
+
+var (
+	// ErrListNotFound is returned when a list does not exist or belongs to another user
+	ErrListNotFound = errors.New("list not found")
+	// ErrInboxList is returned when deleting the inbox list of a user
+	ErrInboxList = errors.New("the inbox list can not be deleted")
+)
+
+// selectList selects the columns read by scanList
+const selectList = "SELECT id, name, created_by, inbox, created_at, modified_at FROM lists"
+
+// Lists handles all of the list database actions
+type Lists struct {
+	DB *sql.DB
+}
+
+// CreateList will insert a list into the database
+func (l *Lists) CreateList(ctx context.Context, list *model.List) (*model.List, error) {
+	if list == nil {
+		return nil, errors.New("list can not be nil")
+	}
+
+	now := time.Now()
+	statement := "INSERT INTO lists (name, created_by, created_at, modified_at) VALUES ($1,$2,$3,$4) RETURNING id"
+	err := l.DB.QueryRow(statement, list.Name, list.CreatedBy, now, now).Scan(&list.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	list.Inbox = false
+	list.CreatedAt = now
+	list.ModifiedAt = now
+	return list, nil
+}
+
+// FetchLists returns the lists of a user, the inbox is created when the user has none yet
+func (l *Lists) FetchLists(ctx context.Context, userId string) ([]model.List, error) {
+	if _, err := inboxID(l.DB, userId); err != nil {
+		return nil, err
+	}
+
+	statement := selectList + " WHERE created_by=$1 ORDER BY inbox DESC, id"
+	rows, err := l.DB.Query(statement, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []model.List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *list)
+	}
+	return lists, nil
+}
+
+// FetchList returns a list of the user by the id
+func (l *Lists) FetchList(ctx context.Context, id int, userId string) (*model.List, error) {
+	statement := selectList + " WHERE id=$1 and created_by=$2"
+	list, err := scanList(l.DB.QueryRow(statement, id, userId))
+	if err == sql.ErrNoRows {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateList renames a list
+func (l *Lists) UpdateList(ctx context.Context, list *model.List, userId string) (*model.List, error) {
+	statement := "UPDATE lists SET name=$1, modified_at=$2 WHERE id=$3 and created_by=$4"
+	res, err := l.DB.Exec(statement, list.Name, time.Now(), list.ID, userId)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, ErrListNotFound
+	}
+	return l.FetchList(ctx, list.ID, userId)
+}
+
+// DeleteList deletes a list, the inbox can not be deleted. Its tasks go to the end of the inbox and to
+// the trash, the tasks already in the trash keep the time they were deleted at.
+func (l *Lists) DeleteList(ctx context.Context, id int, userId string) error {
+	inbox, err := inboxID(l.DB, userId)
+	if err != nil {
+		return err
+	}
+	if id == inbox {
+		return ErrInboxList
+	}
+
+	tx, err := l.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var listId int
+	statement := "SELECT id FROM lists WHERE id=$1 and created_by=$2 FOR UPDATE"
+	err = tx.QueryRow(statement, id, userId).Scan(&listId)
+	if err == sql.ErrNoRows {
+		return ErrListNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	ids, err := listTaskIDs(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := appendPositions(tx, ids, inbox); err != nil {
+		return err
+	}
+	now := time.Now()
+	statement = "UPDATE tasks SET list_id=$1, deleted_at=COALESCE(deleted_at, $2), modified_at=$3 WHERE list_id=$4"
+	if _, err := tx.Exec(statement, inbox, now, now, id); err != nil {
+		return err
+	}
+
+	statement = "DELETE FROM lists WHERE id=$1 and created_by=$2"
+	if _, err := tx.Exec(statement, id, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// listTaskIDs returns the ids of the tasks of a list in their order, with the tasks in the trash
+func listTaskIDs(tx *sql.Tx, listId int) ([]int, error) {
+	rows, err := tx.Query("SELECT id FROM tasks WHERE list_id=$1 ORDER BY position, id", listId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// inboxID returns the id of the inbox list of a user, it is created on first use
+func inboxID(db *sql.DB, userId string) (int, error) {
+	var id int
+	statement := "SELECT id FROM lists WHERE created_by=$1 and inbox"
+	err := db.QueryRow(statement, userId).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+
+	// a concurrent request may create the inbox first
+	now := time.Now()
+	statement = "INSERT INTO lists (name, created_by, inbox, created_at, modified_at) VALUES ($1,$2,true,$3,$4) ON CONFLICT (created_by) WHERE inbox DO NOTHING"
+	if _, err := db.Exec(statement, InboxName, userId, now, now); err != nil {
+		return 0, err
+	}
+
+	statement = "SELECT id FROM lists WHERE created_by=$1 and inbox"
+	err = db.QueryRow(statement, userId).Scan(&id)
+	return id, err
+}
+
+// checkList makes sure list id belongs to the user
+func checkList(db *sql.DB, id int, userId string) error {
+	var exists bool
+	statement := "SELECT true FROM lists WHERE id=$1 and created_by=$2"
+	err := db.QueryRow(statement, id, userId).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrListNotFound
+	}
+	return err
+}
+
+// mergeLists hands the lists of fromId over to intoId, the tasks of the inbox of fromId go to the end
+// of the inbox of intoId when it has one. The user ids are the text ids used by created_by.
+func mergeLists(tx *sql.Tx, intoId string, fromId string) error {
+	into, err := userInbox(tx, intoId)
+	if err != nil {
+		return err
+	}
+	if into != 0 {
+		from, err := userInbox(tx, fromId)
+		if err != nil {
+			return err
+		}
+		if from != 0 {
+			// both inboxes have their own keys, the moved tasks get new ones after the tasks of intoId
+			ids, err := listTaskIDs(tx, from)
+			if err != nil {
+				return err
+			}
+			if err := appendPositions(tx, ids, into); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE tasks SET list_id=$1 WHERE list_id=$2", into, from); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM lists WHERE id=$1", from); err != nil {
+				return err
+			}
+		}
+	}
+
+	statement := "UPDATE lists SET created_by=$1 WHERE created_by=$2"
+	_, err = tx.Exec(statement, intoId, fromId)
+	return err
+}
+
+// userInbox returns the id of the inbox list of a user, 0 when the user has none yet
+func userInbox(q rowQueryer, userId string) (int, error) {
+	var id int
+	err := q.QueryRow("SELECT id FROM lists WHERE created_by=$1 and inbox", userId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+func scanList(row rowScanner) (*model.List, error) {
+	list := &model.List{}
+	err := row.Scan(&list.ID, &list.Name, &list.CreatedBy, &list.Inbox, &list.CreatedAt, &list.ModifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectMergeLists expects the statements of mergeLists when intoId has no inbox yet
+func expectMergeLists(mock sqlmock.Sqlmock, intoId string, fromId string) {
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs(intoId).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE lists SET created_by=\\$1 WHERE created_by=\\$2").
+		WithArgs(intoId, fromId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestMergeLists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	// the tasks 4 and 9 of inbox 3 go after the last task of inbox 2, in their order
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("legacy::7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT id FROM tasks WHERE list_id=\\$1 ORDER BY position, id").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(9))
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(max\\(position\\), ''\\) FROM tasks WHERE list_id=\\$1").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("a1"))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1 WHERE id=\\$2").
+		WithArgs("a2", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1 WHERE id=\\$2").
+		WithArgs("a3", 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tasks SET list_id=\\$1 WHERE list_id=\\$2").
+		WithArgs(2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM lists WHERE id=\\$1").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE lists SET created_by=\\$1 WHERE created_by=\\$2").
+		WithArgs("7", "legacy::7").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if err := mergeLists(tx, "7", "legacy::7"); err != nil {
+		t.Fatalf("mergeLists returned an error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLists_FetchLists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	lists := &Lists{DB: db}
+	now := time.Now()
+
+	// the first request of a user creates its inbox
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("^INSERT INTO lists (.+) ON CONFLICT").
+		WithArgs(InboxName, "7", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id, name, created_by, inbox, created_at, modified_at FROM lists WHERE created_by=\\$1").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_by", "inbox", "created_at", "modified_at"}).
+			AddRow(1, InboxName, "7", true, now, now).
+			AddRow(2, "Groceries", "7", false, now, now))
+
+	result, err := lists.FetchLists(context.Background(), "7")
+	if err != nil {
+		t.Fatalf("FetchLists returned an error: %v", err)
+	}
+	if len(result) != 2 || !result[0].Inbox || result[1].Name != "Groceries" {
+		t.Errorf("unexpected lists %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLists_DeleteList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	lists := &Lists{DB: db}
+
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	if err := lists.DeleteList(context.Background(), 1, "7"); err != ErrInboxList {
+		t.Errorf("DeleteList() of the inbox = %v, want %v", err, ErrInboxList)
+	}
+
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM lists WHERE id=\\$1 and created_by=\\$2 FOR UPDATE").
+		WithArgs(3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	if err := lists.DeleteList(context.Background(), 3, "7"); err != ErrListNotFound {
+		t.Errorf("DeleteList() of another user's list = %v, want %v", err, ErrListNotFound)
+	}
+
+	// tasks 4 and 5 go to the end of the inbox and to the trash
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM lists WHERE id=\\$1 and created_by=\\$2 FOR UPDATE").
+		WithArgs(2, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT id FROM tasks WHERE list_id=\\$1 ORDER BY position, id").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(4))
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(max\\(position\\), ''\\) FROM tasks WHERE list_id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("a3"))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1 WHERE id=\\$2").
+		WithArgs("a4", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1 WHERE id=\\$2").
+		WithArgs("a5", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tasks SET list_id=\\$1, deleted_at=COALESCE\\(deleted_at, \\$2\\), modified_at=\\$3 WHERE list_id=\\$4").
+		WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM lists WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(2, "7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := lists.DeleteList(context.Background(), 2, "7"); err != nil {
+		t.Errorf("DeleteList returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// AccessTokens handles all of the personal access token database actions
+type AccessTokens struct {
+	DB *sql.DB
+}
+
+// CreateAccessToken will insert a personal access token into the database
+func (a *AccessTokens) CreateAccessToken(ctx context.Context, token *model.PersonalAccessToken) (*model.PersonalAccessToken, error) {
+	if token == nil {
+		return nil, errors.New("access token can not be nil")
+	}
+	now := time.Now()
+	var lastInsertId int64
+	statement := "INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at, created_at) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id"
+	err := a.DB.QueryRow(statement, token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.ExpiresAt, now).Scan(&lastInsertId)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = int(lastInsertId)
+	token.CreatedAt = now
+
+	return token, nil
+}
+
+// FetchAccessToken returns a personal access token by the hash of its value
+func (a *AccessTokens) FetchAccessToken(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error) {
+	statement := "SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at FROM personal_access_tokens WHERE token_hash=$1"
+	token, err := scanAccessToken(a.DB.QueryRow(statement, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ListAccessTokens returns the personal access tokens of a user, revoked tokens are left out
+func (a *AccessTokens) ListAccessTokens(ctx context.Context, userId int) ([]model.PersonalAccessToken, error) {
+	statement := "SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at, created_at FROM personal_access_tokens WHERE user_id=$1 and revoked_at IS NULL ORDER BY id"
+	rows, err := a.DB.Query(statement, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []model.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, nil
+}
+
+// RevokeAccessToken revokes a personal access token of a user
+func (a *AccessTokens) RevokeAccessToken(ctx context.Context, id int, userId int) error {
+	statement := "UPDATE personal_access_tokens SET revoked_at=$1 WHERE id=$2 and user_id=$3 and revoked_at IS NULL"
+	res, err := a.DB.Exec(statement, time.Now(), id, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeAccessTokensByUser revokes every personal access token of a user
+func (a *AccessTokens) RevokeAccessTokensByUser(ctx context.Context, userId int) error {
+	statement := "UPDATE personal_access_tokens SET revoked_at=$1 WHERE user_id=$2 and revoked_at IS NULL"
+	_, err := a.DB.Exec(statement, time.Now(), userId)
+	return err
+}
+
+// TouchAccessToken records when a personal access token was last used
+func (a *AccessTokens) TouchAccessToken(ctx context.Context, id int) error {
+	statement := "UPDATE personal_access_tokens SET last_used_at=$1 WHERE id=$2"
+	_, err := a.DB.Exec(statement, time.Now(), id)
+	return err
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccessToken(row rowScanner) (*model.PersonalAccessToken, error) {
+	token := &model.PersonalAccessToken{}
+	var scopes string
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	return token, nil
+}
@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/rank"
+)
+
+var (
+	// ErrSiblingNotFound is returned when the task a task is moved next to does not exist or belongs to another user
+	ErrSiblingNotFound = errors.New("sibling task not found")
+	// ErrNotSibling is returned when a task is moved next to a task of another list or another parent
+	ErrNotSibling = errors.New("a task can only be moved next to a task with the same list and parent")
+)
+
+// Reorder moves a task right before siblingId or, with after, right after it. Only the position of the task
+// changes, its subtasks stay under it and the other tasks keep their positions.
+func (t *TodoList) Reorder(ctx context.Context, id int, siblingId int, after bool, userId string) (*model.Task, error) {
+	if id == siblingId {
+		return nil, ErrNotSibling
+	}
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var listId int
+	var parentId sql.NullInt64
+	statement := "SELECT COALESCE(list_id, 0), parent_id FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL"
+	err = tx.QueryRow(statement, id, userId).Scan(&listId, &parentId)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockList(tx, listId); err != nil {
+		return nil, err
+	}
+
+	var siblingList int
+	var siblingParent sql.NullInt64
+	var siblingPosition string
+	statement = "SELECT COALESCE(list_id, 0), parent_id, position FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL"
+	err = tx.QueryRow(statement, siblingId, userId).Scan(&siblingList, &siblingParent, &siblingPosition)
+	if err == sql.ErrNoRows {
+		return nil, ErrSiblingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if siblingList != listId || siblingParent != parentId {
+		return nil, ErrNotSibling
+	}
+
+	// the new key goes between the sibling and the closest key on the other side of it
+	statement = "SELECT COALESCE(max(position), '') FROM tasks WHERE list_id=$1 and position < $2 and id<>$3"
+	if after {
+		statement = "SELECT COALESCE(min(position), '') FROM tasks WHERE list_id=$1 and position > $2 and id<>$3"
+	}
+	var neighbour string
+	if err := tx.QueryRow(statement, listId, siblingPosition, id).Scan(&neighbour); err != nil {
+		return nil, err
+	}
+
+	low, high := neighbour, siblingPosition
+	if after {
+		low, high = siblingPosition, neighbour
+	}
+	position, err := rank.Between(low, high)
+	if err != nil {
+		return nil, err
+	}
+
+	statement = "UPDATE tasks SET position=$1, modified_at=$2 WHERE id=$3"
+	if _, err := tx.Exec(statement, position, time.Now(), id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t.FetchByID(ctx, id)
+}
+
+// lockList locks a list until the end of tx. The positions of a list are given one transaction at a time,
+// so two tasks created or moved at the same time never get the same key.
+func lockList(tx *sql.Tx, listId int) error {
+	_, err := tx.Exec("SELECT id FROM lists WHERE id=$1 FOR UPDATE", listId)
+	return err
+}
+
+// lastPosition returns the largest position of a list, an empty string when the list has no tasks
+func lastPosition(q rowQueryer, listId int) (string, error) {
+	var position string
+	statement := "SELECT COALESCE(max(position), '') FROM tasks WHERE list_id=$1"
+	err := q.QueryRow(statement, listId).Scan(&position)
+	return position, err
+}
+
+// appendSubtree moves the positions of task $1 and its subtasks to the end of listId, in the order they had.
+// It is called before the tasks move to the list, the tasks already in it keep their positions.
+func appendSubtree(tx *sql.Tx, id int, userId string, listId int) error {
+	statement := subtree + "SELECT id FROM tasks WHERE id IN (SELECT id FROM subtree) and list_id IS DISTINCT FROM $3 ORDER BY position, id"
+	rows, err := tx.Query(statement, id, userId, listId)
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var taskId int
+		if err := rows.Scan(&taskId); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, taskId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return appendPositions(tx, ids, listId)
+}
+
+// appendPositions gives the tasks ids the positions after the last task of listId, in the order of ids.
+// The list stays locked until the end of tx.
+func appendPositions(tx *sql.Tx, ids []int, listId int) error {
+	if err := lockList(tx, listId); err != nil {
+		return err
+	}
+	position, err := lastPosition(tx, listId)
+	if err != nil {
+		return err
+	}
+	for _, taskId := range ids {
+		if position, err = rank.Between(position, ""); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE tasks SET position=$1 WHERE id=$2", position, taskId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+func TestTodo_Reorder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	// task 3 is a subtask, task 1 is not
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\), parent_id FROM tasks WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "parent_id"}).AddRow(2, nil))
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\), parent_id, position FROM tasks WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "parent_id", "position"}).AddRow(2, 5, "a2"))
+	mock.ExpectRollback()
+	if _, err := todoList.Reorder(context.Background(), 1, 3, false, "7"); err != ErrNotSibling {
+		t.Errorf("Reorder() next to a subtask = %v, want %v", err, ErrNotSibling)
+	}
+
+	// task 1 goes between task 4 at a1 and task 3 at a2
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\), parent_id FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "parent_id"}).AddRow(2, nil))
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\), parent_id, position FROM tasks").
+		WithArgs(3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "parent_id", "position"}).AddRow(2, nil, "a2"))
+	mock.ExpectQuery("SELECT COALESCE\\(max\\(position\\), ''\\) FROM tasks WHERE list_id=\\$1 and position < \\$2 and id<>\\$3").
+		WithArgs(2, "a2", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("a1"))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1, modified_at=\\$2 WHERE id=\\$3").
+		WithArgs("a1V", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Task 1", "", "7", 2, "a1V", nil, "{}", "{}", false, model.StatusTodo, model.PriorityNone, nil, "", 1, nil, now, now, nil, nil))
+	task, err := todoList.Reorder(context.Background(), 1, 3, false, "7")
+	if err != nil {
+		t.Fatalf("Reorder returned an error: %v", err)
+	}
+	if task.Position != "a1V" {
+		t.Errorf("expected the position a1V, got %s", task.Position)
+	}
+
+	// task 1 goes after task 3, the last task of the list
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\), parent_id FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "parent_id"}).AddRow(2, nil))
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\), parent_id, position FROM tasks").
+		WithArgs(3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "parent_id", "position"}).AddRow(2, nil, "a2"))
+	mock.ExpectQuery("SELECT COALESCE\\(min\\(position\\), ''\\) FROM tasks WHERE list_id=\\$1 and position > \\$2 and id<>\\$3").
+		WithArgs(2, "a2", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(""))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1").
+		WithArgs("a3", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Task 1", "", "7", 2, "a3", nil, "{}", "{}", false, model.StatusTodo, model.PriorityNone, nil, "", 1, nil, now, now, nil, nil))
+	if _, err := todoList.Reorder(context.Background(), 1, 3, true, "7"); err != nil {
+		t.Fatalf("Reorder returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTodo_MoveTaskAppends(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	// task 1 and its subtask 6 go after the last task of list 3 in their order
+	now := time.Now()
+	mock.ExpectQuery("SELECT true FROM lists WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(3, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectQuery("WITH RECURSIVE subtree (.+) SELECT id FROM tasks WHERE id IN \\(SELECT id FROM subtree\\) and list_id IS DISTINCT FROM \\$3 ORDER BY position, id").
+		WithArgs(1, "7", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(6))
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(max\\(position\\), ''\\) FROM tasks WHERE list_id=\\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("a8"))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1 WHERE id=\\$2").
+		WithArgs("a9", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE tasks SET position=\\$1 WHERE id=\\$2").
+		WithArgs("aA", 6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("WITH RECURSIVE subtree (.+) UPDATE tasks SET list_id=\\$3").
+		WithArgs(1, "7", 3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Task 1", "", "7", 3, "a9", nil, "{}", "{}", false, model.StatusTodo, model.PriorityNone, nil, "", 1, nil, now, now, nil, nil))
+	if _, err := todoList.MoveTask(context.Background(), 1, 3, "7"); err != nil {
+		t.Fatalf("MoveTask returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
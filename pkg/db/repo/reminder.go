@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// reminderFireAt is when the reminder r of the task t fires, it is NULL for a relative reminder of a task without due date
+const reminderFireAt = "COALESCE(r.remind_at, t.due_at + r.offset_minutes * interval '1 minute')"
+
+// reminderPending is true while the reminder r has to fire, a relative reminder fires once for every occurrence of the task t
+const reminderPending = `(r.remind_at IS NOT NULL and r.fired_at IS NULL
+	or r.offset_minutes IS NOT NULL and r.fired_occurrence IS DISTINCT FROM t.occurrence)`
+
+// selectReminder selects the columns read by scanReminder
+const selectReminder = `SELECT r.id, r.task_id, r.channel, r.remind_at, r.offset_minutes,
+	CASE WHEN ` + reminderPending + ` THEN ` + reminderFireAt + ` END,
+	r.fired_at, r.attempts, COALESCE(r.last_error, ''), r.created_at FROM reminders r JOIN tasks t ON t.id=r.task_id`
+
+// Reminders handles all of the reminder database actions, it is the store of the reminder scheduler
+type Reminders struct {
+	DB *sql.DB
+}
+
+// CreateReminder adds a reminder to a task of the user
+func (rs *Reminders) CreateReminder(ctx context.Context, reminder *model.Reminder, userId string) (*model.Reminder, error) {
+	if reminder == nil {
+		return nil, errors.New("reminder can not be nil")
+	}
+
+	var id int
+	now := time.Now()
+	statement := `INSERT INTO reminders (task_id, channel, remind_at, offset_minutes, created_at, modified_at)
+		SELECT id, $1, $2, $3, $4, $5 FROM tasks WHERE id=$6 and created_by=$7 and deleted_at IS NULL RETURNING id`
+	err := rs.DB.QueryRow(statement, reminder.Channel, reminder.RemindAt, reminder.OffsetMinutes, now, now, reminder.TaskID, userId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	statement = selectReminder + " WHERE r.id=$1"
+	return scanReminder(rs.DB.QueryRow(statement, id))
+}
+
+// FetchReminders returns the reminders of a task of the user
+func (rs *Reminders) FetchReminders(ctx context.Context, taskId int, userId string) ([]model.Reminder, error) {
+	var exists bool
+	statement := "SELECT EXISTS (SELECT 1 FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL)"
+	if err := rs.DB.QueryRow(statement, taskId, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	statement = selectReminder + " WHERE r.task_id=$1 ORDER BY r.id"
+	rows, err := rs.DB.Query(statement, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []model.Reminder{}
+	for rows.Next() {
+		reminder, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, *reminder)
+	}
+	return reminders, nil
+}
+
+// DeleteReminder removes a reminder of a task of the user
+func (rs *Reminders) DeleteReminder(ctx context.Context, id int, userId string) error {
+	statement := "DELETE FROM reminders WHERE id=$1 and task_id IN (SELECT id FROM tasks WHERE created_by=$2)"
+	res, err := rs.DB.Exec(statement, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimReminders returns up to limit reminders due at now and locks them until lockedUntil,
+// several schedulers can claim at the same time without sending a reminder twice.
+// The reminders of done, cancelled or trashed tasks are not sent.
+func (rs *Reminders) ClaimReminders(ctx context.Context, now time.Time, lockedUntil time.Time, limit int) ([]model.DueReminder, error) {
+	statement := `WITH due AS (
+		SELECT r.id FROM reminders r JOIN tasks t ON t.id=r.task_id
+		WHERE ` + reminderPending + ` and ` + reminderFireAt + ` <= $1
+		and t.status NOT IN ('done', 'cancelled') and t.deleted_at IS NULL and (r.locked_until IS NULL or r.locked_until <= $1)
+		ORDER BY ` + reminderFireAt + ` LIMIT $3 FOR UPDATE OF r SKIP LOCKED
+	) UPDATE reminders r SET locked_until=$2 FROM tasks t LEFT JOIN users u ON u.id::text=t.created_by
+	WHERE r.id IN (SELECT id FROM due) and t.id=r.task_id
+	RETURNING r.id, r.task_id, r.channel, r.remind_at, r.offset_minutes, ` + reminderFireAt + `, r.attempts,
+		t.occurrence, t.name, t.due_at, t.created_by, COALESCE(u.email, ''), COALESCE(u.timezone, 'UTC')`
+	rows, err := rs.DB.Query(statement, now, lockedUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []model.DueReminder{}
+	for rows.Next() {
+		r := model.DueReminder{}
+		err := rows.Scan(&r.ID, &r.TaskID, &r.Channel, &r.RemindAt, &r.OffsetMinutes, &r.FireAt, &r.Attempts,
+			&r.Occurrence, &r.TaskName, &r.DueAt, &r.UserID, &r.Email, &r.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, nil
+}
+
+// MarkReminderFired records that a reminder fired for the occurrence of its task, lastError is set
+// when the reminder was given up after it failed
+func (rs *Reminders) MarkReminderFired(ctx context.Context, id int, occurrence int, firedAt time.Time, lastError string) error {
+	statement := `UPDATE reminders SET fired_at=$1, fired_occurrence=$2, attempts=0, last_error=$3, locked_until=NULL, modified_at=$4
+		WHERE id=$5`
+	_, err := rs.DB.Exec(statement, firedAt, occurrence, nullString(lastError), firedAt, id)
+	return err
+}
+
+// MarkReminderFailed records a failed delivery, the reminder is claimed again after retryAt
+func (rs *Reminders) MarkReminderFailed(ctx context.Context, id int, retryAt time.Time, lastError string) error {
+	statement := "UPDATE reminders SET attempts=attempts+1, last_error=$1, locked_until=$2, modified_at=$3 WHERE id=$4"
+	_, err := rs.DB.Exec(statement, lastError, retryAt, time.Now(), id)
+	return err
+}
+
+func scanReminder(row rowScanner) (*model.Reminder, error) {
+	reminder := &model.Reminder{}
+	err := row.Scan(&reminder.ID, &reminder.TaskID, &reminder.Channel, &reminder.RemindAt, &reminder.OffsetMinutes,
+		&reminder.FireAt, &reminder.FiredAt, &reminder.Attempts, &reminder.LastError, &reminder.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
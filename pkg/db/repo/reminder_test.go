@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// reminderColumns are the columns selected by selectReminder
+var reminderColumns = []string{"id", "task_id", "channel", "remind_at", "offset_minutes", "fire_at", "fired_at", "attempts", "last_error", "created_at"}
+
+func TestReminders_CreateReminder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	reminders := &Reminders{DB: db}
+	offset := -30
+	reminder := &model.Reminder{TaskID: 5, Channel: model.ChannelEmail, OffsetMinutes: &offset}
+
+	// task 5 belongs to another user
+	mock.ExpectQuery("INSERT INTO reminders (.+) SELECT id, \\$1, \\$2, \\$3, \\$4, \\$5 FROM tasks WHERE id=\\$6 and created_by=\\$7 and deleted_at IS NULL RETURNING id").
+		WithArgs(model.ChannelEmail, nil, offset, sqlmock.AnyArg(), sqlmock.AnyArg(), 5, "8").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if _, err := reminders.CreateReminder(context.Background(), reminder, "8"); err == nil || err.Error() != "No record found" {
+		t.Errorf("CreateReminder() on another user's task = %v, want No record found", err)
+	}
+
+	due := time.Now().Add(time.Hour)
+	mock.ExpectQuery("INSERT INTO reminders").
+		WithArgs(model.ChannelEmail, nil, offset, sqlmock.AnyArg(), sqlmock.AnyArg(), 5, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM reminders r JOIN tasks t ON t.id=r.task_id WHERE r.id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(reminderColumns).AddRow(1, 5, model.ChannelEmail, nil, offset, due.Add(-30*time.Minute), nil, 0, "", time.Now()))
+
+	res, err := reminders.CreateReminder(context.Background(), reminder, "7")
+	if err != nil {
+		t.Fatalf("CreateReminder returned an error: %v", err)
+	}
+	if res.ID != 1 || res.FireAt == nil || !res.FireAt.Equal(due.Add(-30*time.Minute)) {
+		t.Errorf("CreateReminder() = %+v, want it to fire 30 minutes before the due date", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReminders_ClaimReminders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	reminders := &Reminders{DB: db}
+	now := time.Now()
+	remindAt := now.Add(-time.Minute)
+
+	mock.ExpectQuery("WITH due AS \\((.+) FOR UPDATE OF r SKIP LOCKED \\) UPDATE reminders r SET locked_until=\\$2 (.+) RETURNING").
+		WithArgs(now, now.Add(5*time.Minute), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "task_id", "channel", "remind_at", "offset_minutes", "fire_at", "attempts",
+			"occurrence", "name", "due_at", "created_by", "email", "timezone"}).
+			AddRow(1, 5, model.ChannelEmail, remindAt, nil, remindAt, 0, 1, "pay the rent", nil, "7", "octocat@github.com", "Europe/Berlin"))
+
+	due, err := reminders.ClaimReminders(context.Background(), now, now.Add(5*time.Minute), 100)
+	if err != nil {
+		t.Fatalf("ClaimReminders returned an error: %v", err)
+	}
+	if len(due) != 1 || due[0].TaskName != "pay the rent" || due[0].Email != "octocat@github.com" || due[0].Timezone != "Europe/Berlin" {
+		t.Errorf("ClaimReminders() = %+v", due)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
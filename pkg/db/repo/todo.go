@@ -8,8 +8,40 @@ import (
 	"time"
 
 	"github.com/cfthoo/todo-app/pkg/db/model"
+	"github.com/cfthoo/todo-app/pkg/rank"
+	"github.com/cfthoo/todo-app/pkg/rrule"
+	"github.com/lib/pq"
 )
 
+var (
+	// ErrNotFound is returned when a record does not exist or belongs to another user
+	ErrNotFound = errors.New("No record found")
+	// ErrParentNotFound is returned when the parent of a subtask does not exist or belongs to another user
+	ErrParentNotFound = errors.New("parent task not found")
+	// ErrTaskCycle is returned when a task would become a subtask of itself or of one of its subtasks
+	ErrTaskCycle = errors.New("a task can not be a subtask of itself or of its subtasks")
+	// ErrNotRecurring is returned when skipping an occurrence or ending the series of a task without recurrence
+	ErrNotRecurring = errors.New("task is not recurring")
+	// ErrRecurringDone is returned when an update sets a recurring task to done instead of completing the occurrence
+	ErrRecurringDone = errors.New("a recurring task is completed with PATCH /todolist/{id}, one occurrence at a time")
+)
+
+// selectTask selects the columns read by scanTask, blockers in the trash are left out
+const selectTask = `SELECT id, name, description, created_by, COALESCE(list_id, 0), position, parent_id,
+	ARRAY(SELECT label_id FROM task_labels WHERE task_id=tasks.id ORDER BY label_id),
+	ARRAY(SELECT d.blocker_id FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id
+		WHERE d.blocked_id=tasks.id and b.deleted_at IS NULL ORDER BY d.blocker_id),
+	EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id
+		WHERE d.blocked_id=tasks.id and b.status NOT IN ('done', 'cancelled') and b.deleted_at IS NULL),
+	status, priority, due_at, COALESCE(recurrence, ''), occurrence, completed_at, created_at, modified_at, deleted_at, archived_at FROM tasks`
+
+// subtree selects the ids of task $1 of user $2 and of all of its subtasks which are not in the trash,
+// statements starting with it take the task and the user as their first two arguments
+const subtree = `WITH RECURSIVE subtree AS (
+	SELECT id FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL
+	UNION SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id=s.id WHERE t.deleted_at IS NULL
+) `
+
 // TodoList handles all of the database actions
 type TodoList struct {
 	DB *sql.DB
@@ -20,15 +52,64 @@ func (t *TodoList) Create(ctx context.Context, task *model.Task) (*model.Task, e
 	if task == nil {
 		return nil, errors.New("task can not be nil")
 	}
-	completed := false
+	if task.Status == "" {
+		task.Status = model.StatusTodo
+	}
+	if task.Priority == "" {
+		task.Priority = model.PriorityNone
+	}
 	now := time.Now()
+	task.CompletedAt = nil
+	if task.Status == model.StatusDone {
+		task.CompletedAt = &now
+	}
+	task.Complete = task.Status == model.StatusDone
+	task.LabelIDs = []int64{}
+	task.BlockedBy = []int64{}
+	task.Blocked = false
+	task.Occurrence = 1
+
+	// subtasks are in the list of their parent, tasks without a list go to the inbox
+	var err error
+	if task.ParentID != nil {
+		task.ListID, err = parentList(t.DB, *task.ParentID, task.CreatedBy)
+	} else if task.ListID == 0 {
+		task.ListID, err = inboxID(t.DB, task.CreatedBy)
+	} else {
+		err = checkList(t.DB, task.ListID, task.CreatedBy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// new tasks go to the end of their list
+	if err := lockList(tx, task.ListID); err != nil {
+		return nil, err
+	}
+	last, err := lastPosition(tx, task.ListID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Position, err = rank.Between(last, ""); err != nil {
+		return nil, err
+	}
+
 	var lastInsertId int64
-	statement := "INSERT INTO tasks ( name, created_by,complete, created_at, modified_at) VALUES ($1, $2,$3,$4,$5) RETURNING id"
-	err := t.DB.QueryRow(statement, task.Name, task.CreatedBy, completed, now, now).Scan(&lastInsertId)
+	statement := "INSERT INTO tasks (name, description, created_by, list_id, position, parent_id, status, priority, due_at, recurrence, completed_at, created_at, modified_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING id"
+	err = tx.QueryRow(statement, task.Name, task.Description, task.CreatedBy, task.ListID, task.Position, task.ParentID, task.Status, task.Priority, task.DueAt, nullString(task.Recurrence), task.CompletedAt, now, now).Scan(&lastInsertId)
 	if err != nil {
 		fmt.Println("sss:", err)
 		return nil, err
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	task.ID = int(lastInsertId)
 	task.CreatedAt = now
@@ -37,13 +118,32 @@ func (t *TodoList) Create(ctx context.Context, task *model.Task) (*model.Task, e
 	return task, nil
 }
 
-// FetchAll returns all tasks
+// FetchAll returns all tasks matching the filter
 // Here i implmented the select by created_by/userId , due to we have to login with
 // google/fb/github. Therefore each user can only select their own task.
-func (t *TodoList) FetchAll(ctx context.Context, userId string) ([]model.Task, error) {
+func (t *TodoList) FetchAll(ctx context.Context, userId string, filter model.TaskFilter) ([]model.Task, error) {
 
-	statement := "SELECT * FROM tasks WHERE created_by=$1"
-	rows, err := t.DB.Query(statement, userId)
+	statement := selectTask + " WHERE created_by=$1 and deleted_at IS NULL"
+	args := []interface{}{userId}
+	if filter.ListID != 0 {
+		args = append(args, filter.ListID)
+		statement += fmt.Sprintf(" and list_id=$%d", len(args))
+	}
+	if !filter.IncludeArchived {
+		statement += " and archived_at IS NULL"
+	}
+	if len(filter.LabelIDs) > 0 {
+		args = append(args, pq.Array(filter.LabelIDs))
+		labels := fmt.Sprintf("SELECT task_id FROM task_labels WHERE label_id = ANY($%d)", len(args))
+		if filter.AllLabels {
+			// the task has as many of the labels as there are labels
+			args = append(args, len(filter.LabelIDs))
+			labels += fmt.Sprintf(" GROUP BY task_id HAVING count(*)=$%d", len(args))
+		}
+		statement += " and id IN (" + labels + ")"
+	}
+	statement += " ORDER BY list_id, position, id"
+	rows, err := t.DB.Query(statement, args...)
 
 	if err != nil {
 		return nil, err
@@ -54,12 +154,11 @@ func (t *TodoList) FetchAll(ctx context.Context, userId string) ([]model.Task, e
 	tasks := []model.Task{}
 
 	for rows.Next() {
-		var t model.Task
-		err := rows.Scan(&t.ID, &t.Name, &t.Complete, &t.CreatedBy, &t.CreatedAt, &t.ModifiedAt)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, t)
+		tasks = append(tasks, *task)
 	}
 
 	return tasks, nil
@@ -67,33 +166,72 @@ func (t *TodoList) FetchAll(ctx context.Context, userId string) ([]model.Task, e
 
 // FetchByID returns an task by the id
 func (t *TodoList) FetchByID(ctx context.Context, id int) (*model.Task, error) {
-	task := &model.Task{}
-	statement := "SELECT * FROM tasks WHERE id=$1"
+	var task *model.Task
+	statement := selectTask + " WHERE id=$1 and deleted_at IS NULL"
 	rows, err := t.DB.Query(statement, id)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	if rows.Next() {
-		err = rows.Scan(&task.ID, &task.Name, &task.Complete, &task.CreatedBy, &task.CreatedAt, &task.ModifiedAt)
+		task, err = scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		return nil, errors.New("No record found")
+		return nil, ErrNotFound
 	}
 
 	return task, nil
 }
 
-// Update will update task
+// Update will update task, an empty status or priority keeps the current one.
+// A new recurrence starts a new series from the first occurrence.
+// A task with open blockers can not be set to done, MarkComplete has to force it. A recurring task
+// can not be set to done either, MarkComplete records the occurrence and moves on to the next one.
 func (t *TodoList) Update(ctx context.Context, task *model.Task, userId string) (*model.Task, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if task.Status == model.StatusDone {
+		var status string
+		statement := "SELECT status FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL FOR UPDATE"
+		err := tx.QueryRow(statement, task.ID, userId).Scan(&status)
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if task.Recurrence != "" {
+			return nil, ErrRecurringDone
+		}
+		if status != model.StatusDone {
+			if err := checkBlockers(tx, task.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	now := time.Now()
-	statement := "UPDATE tasks SET name=$1 , modified_at=$2 WHERE id=$3 and created_by=$4"
-	_, err := t.DB.Exec(statement, task.Name, now, task.ID)
+	statement := `UPDATE tasks SET name=$1, description=$2, due_at=$3, priority=COALESCE(NULLIF($4, ''), priority),
+		status=COALESCE(NULLIF($5, ''), status),
+		completed_at=CASE WHEN COALESCE(NULLIF($5, ''), status)='done' THEN COALESCE(completed_at, $6) ELSE NULL END,
+		occurrence=CASE WHEN recurrence IS DISTINCT FROM $7 THEN 1 ELSE occurrence END, recurrence=$7,
+		modified_at=$8 WHERE id=$9 and created_by=$10 and deleted_at IS NULL`
+	result, err := tx.Exec(statement, task.Name, task.Description, task.DueAt, task.Priority, task.Status, now, nullString(task.Recurrence), now, task.ID, userId)
 	if err != nil {
 		return nil, err
 	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, ErrNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	res, err := t.FetchByID(context.Background(), task.ID)
 	if err != nil {
@@ -103,35 +241,299 @@ func (t *TodoList) Update(ctx context.Context, task *model.Task, userId string)
 	return res, nil
 }
 
-// Update will update task
-func (t *TodoList) MarkComplete(ctx context.Context, id int, userId string) (*model.Task, error) {
+// MarkComplete sets the status of a task to done, with the cascade option its open subtasks are done too.
+// A task with open blockers is only completed with the force option.
+// A recurring task records the completion and moves on to its next occurrence, it is only done once
+// its series ends. The skip option records the occurrence as skipped and the end series option ends it.
+func (t *TodoList) MarkComplete(ctx context.Context, id int, userId string, opts model.CompleteOptions) (*model.Task, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var recurrence string
+	var dueAt *time.Time
+	var occurrence int
+	statement := "SELECT COALESCE(recurrence, ''), due_at, occurrence FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL FOR UPDATE"
+	err = tx.QueryRow(statement, id, userId).Scan(&recurrence, &dueAt, &occurrence)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if recurrence == "" && (opts.Skip || opts.EndSeries) {
+		return nil, ErrNotRecurring
+	}
+	// skipping an occurrence does not complete it
+	if !opts.Force && !opts.Skip {
+		if err := checkBlockers(tx, id); err != nil {
+			return nil, err
+		}
+	}
 
 	now := time.Now()
-	statement := "UPDATE tasks SET complete=true , modified_at=$1 WHERE id=$2 and created_by=$3"
-	_, err := t.DB.Exec(statement, now, id, userId)
+	if recurrence != "" {
+		statement = "INSERT INTO task_completions (task_id, occurrence, due_at, completed_at, skipped) VALUES ($1,$2,$3,$4,$5)"
+		if _, err := tx.Exec(statement, id, occurrence, dueAt, now, opts.Skip); err != nil {
+			return nil, err
+		}
+
+		if !opts.EndSeries {
+			next, ok, err := nextOccurrence(tx, recurrence, dueAt, occurrence, userId, now)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				statement = "UPDATE tasks SET due_at=$1, occurrence=occurrence+1, status='todo', completed_at=NULL, modified_at=$2 WHERE id=$3"
+				if _, err := tx.Exec(statement, next, now, id); err != nil {
+					return nil, err
+				}
+				if err := tx.Commit(); err != nil {
+					return nil, err
+				}
+				return t.FetchByID(ctx, id)
+			}
+		}
+	}
+
+	// the task is done, a series which ended is not recurring anymore
+	statement = "UPDATE tasks SET status='done', completed_at=COALESCE(completed_at, $3), recurrence=NULL, modified_at=$4 WHERE id=$1 and created_by=$2"
+	args := []interface{}{id, userId, now, now}
+	if opts.Skip {
+		// the last occurrence was skipped, the task is not done
+		statement = "UPDATE tasks SET status='cancelled', completed_at=NULL, recurrence=NULL, modified_at=$3 WHERE id=$1 and created_by=$2"
+		args = args[:3]
+	} else if opts.Cascade {
+		// the open subtasks are completed too, none of them may be blocked either
+		if !opts.Force {
+			if err := checkSubtreeBlockers(tx, id, userId); err != nil {
+				return nil, err
+			}
+		}
+		statement = subtree + `UPDATE tasks SET status='done', completed_at=COALESCE(completed_at, $3),
+			recurrence=CASE WHEN id=$1 THEN NULL ELSE recurrence END, modified_at=$4
+			WHERE id IN (SELECT id FROM subtree) and (id=$1 or status NOT IN ('done', 'cancelled'))`
+	}
+	if _, err := tx.Exec(statement, args...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return t.FetchByID(ctx, id)
+}
+
+// Completions returns the completed and skipped occurrences of a recurring task
+func (t *TodoList) Completions(ctx context.Context, id int, userId string) ([]model.TaskCompletion, error) {
+	var exists bool
+	statement := "SELECT EXISTS (SELECT 1 FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL)"
+	if err := t.DB.QueryRow(statement, id, userId).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	statement = "SELECT id, task_id, occurrence, due_at, completed_at, skipped FROM task_completions WHERE task_id=$1 ORDER BY id"
+	rows, err := t.DB.Query(statement, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions := []model.TaskCompletion{}
+	for rows.Next() {
+		completion := model.TaskCompletion{}
+		err := rows.Scan(&completion.ID, &completion.TaskID, &completion.Occurrence, &completion.DueAt, &completion.CompletedAt, &completion.Skipped)
+		if err != nil {
+			return nil, err
+		}
+		completions = append(completions, completion)
+	}
+	return completions, nil
+}
+
+// MoveTask moves a task with its subtasks to the end of another list of the user, a subtask is taken out of its parent
+func (t *TodoList) MoveTask(ctx context.Context, id int, listId int, userId string) (*model.Task, error) {
+	if err := checkList(t.DB, listId, userId); err != nil {
+		return nil, err
+	}
+
+	tx, err := t.DB.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	res, err := t.FetchByID(context.Background(), id)
+	if err := appendSubtree(tx, id, userId, listId); err != nil {
+		return nil, err
+	}
+	statement := subtree + `UPDATE tasks SET list_id=$3, parent_id=CASE WHEN id=$1 THEN NULL ELSE parent_id END, modified_at=$4
+		WHERE id IN (SELECT id FROM subtree)`
+	res, err := tx.Exec(statement, id, userId, listId, time.Now())
 	if err != nil {
 		return nil, err
 	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, ErrNotFound
+	}
 
-	return res, nil
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t.FetchByID(ctx, id)
 }
 
-// Delete will delete a task
-func (t *TodoList) Delete(ctx context.Context, id int, userId string) error {
+// SetParent makes a task a subtask of parentId, a nil parentId makes it a top level task again.
+// The task and its subtasks move to the end of the list of the new parent when it is another list.
+func (t *TodoList) SetParent(ctx context.Context, id int, parentId *int, userId string) (*model.Task, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if parentId == nil {
+		statement := "UPDATE tasks SET parent_id=NULL, modified_at=$1 WHERE id=$2 and created_by=$3 and deleted_at IS NULL"
+		res, err := tx.Exec(statement, now, id, userId)
+		if err != nil {
+			return nil, err
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return nil, ErrNotFound
+		}
+	} else {
+		listId, err := parentList(tx, *parentId, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		// the task can not be the new parent or one of its ancestors
+		var cycle bool
+		statement := `WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM tasks WHERE id=$1
+			UNION SELECT t.id, t.parent_id FROM tasks t JOIN ancestors a ON t.id=a.parent_id
+		) SELECT EXISTS (SELECT 1 FROM ancestors WHERE id=$2)`
+		if err := tx.QueryRow(statement, *parentId, id).Scan(&cycle); err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, ErrTaskCycle
+		}
+
+		if err := appendSubtree(tx, id, userId, listId); err != nil {
+			return nil, err
+		}
+		statement = subtree + `UPDATE tasks SET list_id=$3, parent_id=CASE WHEN id=$1 THEN $4 ELSE parent_id END, modified_at=$5
+			WHERE id IN (SELECT id FROM subtree)`
+		res, err := tx.Exec(statement, id, userId, listId, *parentId, now)
+		if err != nil {
+			return nil, err
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return nil, ErrNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t.FetchByID(ctx, id)
+}
+
+// Delete will move a task to the trash, with the cascade option its subtasks go to the trash too,
+// otherwise they are moved up to the parent of the task
+func (t *TodoList) Delete(ctx context.Context, id int, userId string, opts model.DeleteOptions) error {
 	if id < 0 {
 		return fmt.Errorf("invalid id")
 	}
 
-	statement := "DELETE from tasks WHERE id=$1 and created_by=$2"
-	_, err := t.DB.Exec(statement, id, userId)
+	now := time.Now()
+	if opts.Cascade {
+		// the subtasks get the same deleted_at, they are restored with the task
+		statement := subtree + "UPDATE tasks SET deleted_at=$3 WHERE id IN (SELECT id FROM subtree)"
+		_, err := t.DB.Exec(statement, id, userId, now)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	tx, err := t.DB.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	statement := "UPDATE tasks SET parent_id=(SELECT parent_id FROM tasks WHERE id=$1 and created_by=$2) WHERE parent_id=$1 and created_by=$2 and deleted_at IS NULL"
+	if _, err := tx.Exec(statement, id, userId); err != nil {
+		return err
+	}
+	statement = "UPDATE tasks SET deleted_at=$3 WHERE id=$1 and created_by=$2 and deleted_at IS NULL"
+	if _, err := tx.Exec(statement, id, userId, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rowQueryer is implemented by *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// parentList returns the list of the parent task of a subtask
+func parentList(q rowQueryer, parentId int, userId string) (int, error) {
+	var listId int
+	statement := "SELECT COALESCE(list_id, 0) FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NULL"
+	err := q.QueryRow(statement, parentId, userId).Scan(&listId)
+	if err == sql.ErrNoRows {
+		return 0, ErrParentNotFound
+	}
+	return listId, err
+}
+
+// nextOccurrence returns the due date of the occurrence after dueAt and false when the series ended.
+// The rule is evaluated in the timezone of the user, a task without a due date recurs from now.
+func nextOccurrence(q rowQueryer, recurrence string, dueAt *time.Time, occurrence int, userId string, now time.Time) (time.Time, bool, error) {
+	loc := userLocation(q, userId)
+	rule, err := rrule.Parse(recurrence, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	prev := now
+	if dueAt != nil {
+		prev = *dueAt
+	}
+	next, ok := rule.Next(prev.In(loc), occurrence)
+	return next, ok, nil
+}
+
+// userLocation returns the timezone of a user, UTC when it is not known
+func userLocation(q rowQueryer, userId string) *time.Location {
+	var timezone string
+	statement := "SELECT timezone FROM users WHERE id::text=$1"
+	if err := q.QueryRow(statement, userId).Scan(&timezone); err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
 
+func scanTask(row rowScanner) (*model.Task, error) {
+	task := &model.Task{}
+	err := row.Scan(&task.ID, &task.Name, &task.Description, &task.CreatedBy, &task.ListID, &task.Position, &task.ParentID, pq.Array(&task.LabelIDs), pq.Array(&task.BlockedBy), &task.Blocked, &task.Status, &task.Priority,
+		&task.DueAt, &task.Recurrence, &task.Occurrence, &task.CompletedAt, &task.CreatedAt, &task.ModifiedAt, &task.DeletedAt, &task.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	task.Complete = task.Status == model.StatusDone
+	task.Archived = task.ArchivedAt != nil
+	return task, nil
 }
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"database/sql/driver"
 	"reflect"
 	"testing"
 	"time"
@@ -10,6 +11,9 @@ import (
 	"github.com/cfthoo/todo-app/pkg/db/model"
 )
 
+// taskColumns are the columns selected by selectTask
+var taskColumns = []string{"id", "name", "description", "created_by", "list_id", "position", "parent_id", "label_ids", "blocked_by", "blocked", "status", "priority", "due_at", "recurrence", "occurrence", "completed_at", "created_at", "modified_at", "deleted_at", "archived_at"}
+
 func TestTodo_Create(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -22,9 +26,20 @@ func TestTodo_Create(t *testing.T) {
 
 	// mock the database response
 	task := &model.Task{Name: "test task", CreatedBy: "123"}
+	mock.ExpectQuery("SELECT id FROM lists WHERE created_by=\\$1 and inbox").
+		WithArgs(task.CreatedBy).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT id FROM lists WHERE id=\\$1 FOR UPDATE").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT COALESCE\\(max\\(position\\), ''\\) FROM tasks WHERE list_id=\\$1").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("a4"))
 	mock.ExpectQuery("^INSERT INTO tasks").
-		WithArgs(task.Name, task.CreatedBy, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(task.Name, "", task.CreatedBy, 4, "a5", nil, model.StatusTodo, model.PriorityNone, nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
 
 	// call the Create method
 	result, err := todoList.Create(context.Background(), task)
@@ -47,6 +62,18 @@ func TestTodo_Create(t *testing.T) {
 		t.Error("expected ModifiedAt field to be set, but it's zero")
 	}
 
+	// new tasks are to do without a priority in the inbox
+	if result.ListID != 4 {
+		t.Errorf("expected the task in the inbox list 4, got %d", result.ListID)
+	}
+	// at the end of the list
+	if result.Position != "a5" {
+		t.Errorf("expected the position a5 after the last task, got %s", result.Position)
+	}
+	if result.Status != model.StatusTodo || result.Priority != model.PriorityNone || result.Complete {
+		t.Errorf("expected a task to do, got status %s priority %s", result.Status, result.Priority)
+	}
+
 	// check if all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -61,6 +88,7 @@ func TestTodo_FetchAll(t *testing.T) {
 			ID:         1,
 			Name:       "Task 1",
 			CreatedBy:  userId,
+			ListID:     2,
 			CreatedAt:  time.Now(),
 			ModifiedAt: time.Now(),
 		},
@@ -68,6 +96,7 @@ func TestTodo_FetchAll(t *testing.T) {
 			ID:         2,
 			Name:       "Task 2",
 			CreatedBy:  userId,
+			ListID:     2,
 			CreatedAt:  time.Now(),
 			ModifiedAt: time.Now(),
 		},
@@ -83,18 +112,17 @@ func TestTodo_FetchAll(t *testing.T) {
 		DB: db,
 	}
 
-	columns := []string{"id", "name", "created_by", "created_at", "modified_at"}
-	rows := sqlmock.NewRows(columns)
+	rows := sqlmock.NewRows(taskColumns)
 
 	for _, task := range tasks {
-		rows.AddRow(task.ID, task.Name, task.CreatedBy, task.CreatedAt, task.ModifiedAt)
+		rows.AddRow(task.ID, task.Name, task.Description, task.CreatedBy, task.ListID, "a0", task.ParentID, "{}", "{}", false, task.Status, task.Priority, task.DueAt, "", 1, task.CompletedAt, task.CreatedAt, task.ModifiedAt, nil, nil)
 	}
 
-	mock.ExpectQuery("SELECT \\* FROM tasks WHERE created_by=\\$1").
-		WithArgs(userId).
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE created_by=\\$1 and deleted_at IS NULL and list_id=\\$2 and archived_at IS NULL ORDER BY list_id, position, id").
+		WithArgs(userId, 2).
 		WillReturnRows(rows)
 
-	result, err := todoList.FetchAll(context.Background(), userId)
+	result, err := todoList.FetchAll(context.Background(), userId, model.TaskFilter{ListID: 2})
 
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
@@ -122,12 +150,17 @@ func TestTodo_FetchByID(t *testing.T) {
 	defer db.Close()
 
 	// Set up the expected rows to be returned by the mock
-	expectedTask := &model.Task{ID: 1, Name: "Task 1", CreatedBy: "user1", CreatedAt: time.Now(), ModifiedAt: time.Now()}
-	rows := sqlmock.NewRows([]string{"id", "name", "created_by", "created_at", "modified_at"}).
-		AddRow(expectedTask.ID, expectedTask.Name, expectedTask.CreatedBy, expectedTask.CreatedAt, expectedTask.ModifiedAt)
+	due := time.Date(2023, 5, 1, 18, 0, 0, 0, time.FixedZone("+08", 8*60*60))
+	completed := time.Now()
+	parent := 4
+	expectedTask := &model.Task{ID: 1, Name: "Task 1", Description: "**bold**", CreatedBy: "user1", ListID: 2, Position: "a0", ParentID: &parent, LabelIDs: []int64{1, 3}, BlockedBy: []int64{2}, Blocked: true, Status: model.StatusDone, Priority: model.PriorityHigh,
+		DueAt: &due, Recurrence: "FREQ=WEEKLY", Occurrence: 3, Complete: true, CompletedAt: &completed, CreatedAt: time.Now(), ModifiedAt: time.Now()}
+	rows := sqlmock.NewRows(taskColumns).
+		AddRow(expectedTask.ID, expectedTask.Name, expectedTask.Description, expectedTask.CreatedBy, expectedTask.ListID, expectedTask.Position, expectedTask.ParentID, "{1,3}", "{2}", true, expectedTask.Status, expectedTask.Priority,
+			expectedTask.DueAt, expectedTask.Recurrence, expectedTask.Occurrence, expectedTask.CompletedAt, expectedTask.CreatedAt, expectedTask.ModifiedAt, nil, nil)
 
 	// Set up the mock query and result
-	mock.ExpectQuery("SELECT \\* FROM tasks WHERE id=\\$1").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").WithArgs(1).WillReturnRows(rows)
 
 	// Call the function being tested
 	list := &TodoList{DB: db}
@@ -156,18 +189,21 @@ func TestTodo_Update(t *testing.T) {
 
 	// Set up the expected task and mock query result
 	now := time.Now()
-	expectedTask := &model.Task{ID: 1, Name: "Updated Task 1", CreatedBy: "user1", CreatedAt: now, ModifiedAt: now}
-	mock.ExpectExec("UPDATE tasks SET name=\\$1 , modified_at=\\$2 WHERE id=\\$3").
-		WithArgs(expectedTask.Name, now, expectedTask.ID).
+	expectedTask := &model.Task{ID: 1, Name: "Updated Task 1", Description: "notes", CreatedBy: "user1", ListID: 2, Position: "a1", LabelIDs: []int64{}, BlockedBy: []int64{}, Status: model.StatusInProgress, Priority: model.PriorityLow, Occurrence: 1, CreatedAt: now, ModifiedAt: now}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tasks SET name=\\$1, description=\\$2, due_at=\\$3, (.+) WHERE id=\\$9 and created_by=\\$10").
+		WithArgs(expectedTask.Name, expectedTask.Description, nil, expectedTask.Priority, expectedTask.Status, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), expectedTask.ID, expectedTask.CreatedBy).
 		WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectQuery("SELECT \\* FROM tasks WHERE id=\\$1").
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
 		WithArgs(expectedTask.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_by", "created_at", "modified_at"}).
-			AddRow(expectedTask.ID, expectedTask.Name, expectedTask.CreatedBy, expectedTask.CreatedAt, expectedTask.ModifiedAt))
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(expectedTask.ID, expectedTask.Name, expectedTask.Description, expectedTask.CreatedBy, expectedTask.ListID, expectedTask.Position, expectedTask.ParentID, "{}", "{}", false, expectedTask.Status, expectedTask.Priority,
+				nil, "", 1, nil, expectedTask.CreatedAt, expectedTask.ModifiedAt, nil, nil))
 
 	// Call the function being tested
 	list := &TodoList{DB: db}
-	res, err := list.Update(context.Background(), expectedTask)
+	res, err := list.Update(context.Background(), expectedTask, "user1")
 	if err != nil {
 		t.Fatalf("Update returned an error: %v", err)
 	}
@@ -181,6 +217,44 @@ func TestTodo_Update(t *testing.T) {
 	if !reflect.DeepEqual(res, expectedTask) {
 		t.Fatalf("Returned task %+v does not match expected task %+v", res, expectedTask)
 	}
+
+	// the task of another user is not updated and not returned
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tasks SET name=\\$1").
+		WithArgs(expectedTask.Name, expectedTask.Description, nil, expectedTask.Priority, expectedTask.Status, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), expectedTask.ID, "user2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	if _, err := list.Update(context.Background(), expectedTask, "user2"); err == nil || err.Error() != "No record found" {
+		t.Errorf("Update of the task of another user = %v, want No record found", err)
+	}
+
+	// setting a blocked task to done is refused like completing it
+	done := &model.Task{ID: 1, Name: "Updated Task 1", Status: model.StatusDone}
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM tasks WHERE id=\\$1 and created_by=\\$2 and deleted_at IS NULL FOR UPDATE").
+		WithArgs(1, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.StatusTodo))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	if _, err := list.Update(context.Background(), done, "user1"); err != ErrTaskBlocked {
+		t.Errorf("Update() of a blocked task to done = %v, want %v", err, ErrTaskBlocked)
+	}
+
+	// a recurring task completes one occurrence at a time
+	recurring := &model.Task{ID: 1, Name: "Updated Task 1", Status: model.StatusDone, Recurrence: "FREQ=DAILY"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM tasks WHERE id=\\$1").
+		WithArgs(1, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.StatusTodo))
+	mock.ExpectRollback()
+	if _, err := list.Update(context.Background(), recurring, "user1"); err != ErrRecurringDone {
+		t.Errorf("Update() of a recurring task to done = %v, want %v", err, ErrRecurringDone)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unfulfilled expectations: %s", err)
+	}
 }
 
 func TestTodo_Delete(t *testing.T) {
@@ -196,12 +270,12 @@ func TestTodo_Delete(t *testing.T) {
 
 	// set up test case
 	id := 1
-	mock.ExpectExec("DELETE from tasks WHERE id=\\$1").
-		WithArgs(id).
+	mock.ExpectExec("WITH RECURSIVE subtree (.+) UPDATE tasks SET deleted_at=\\$3 WHERE id IN \\(SELECT id FROM subtree\\)").
+		WithArgs(id, "user1", sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	// call method
-	err = todoList.Delete(context.Background(), id)
+	err = todoList.Delete(context.Background(), id, "user1", model.DeleteOptions{Cascade: true})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -212,3 +286,200 @@ func TestTodo_Delete(t *testing.T) {
 		t.Fatalf("unfulfilled expectations: %v", err)
 	}
 }
+
+func TestTodo_FetchAllByLabels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+	now := time.Now()
+
+	// tasks with all of the labels
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE created_by=\\$1 and deleted_at IS NULL and archived_at IS NULL and id IN \\(SELECT task_id FROM task_labels WHERE label_id = ANY\\(\\$2\\) GROUP BY task_id HAVING count\\(\\*\\)=\\$3\\) ORDER BY list_id, position, id").
+		WithArgs("123", "{1,3}", 2).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Task 1", "", "123", 1, "a0", nil, "{1,2,3}", "{}", false, model.StatusTodo, model.PriorityNone, nil, "", 1, nil, now, now, nil, nil))
+
+	result, err := todoList.FetchAll(context.Background(), "123", model.TaskFilter{LabelIDs: []int64{1, 3}, AllLabels: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 1 || !reflect.DeepEqual(result[0].LabelIDs, []int64{1, 2, 3}) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	// tasks with any of the labels
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE created_by=\\$1 and deleted_at IS NULL and archived_at IS NULL and id IN \\(SELECT task_id FROM task_labels WHERE label_id = ANY\\(\\$2\\)\\) ORDER BY list_id, position, id").
+		WithArgs("123", "{1,3}").
+		WillReturnRows(sqlmock.NewRows(taskColumns))
+
+	if _, err := todoList.FetchAll(context.Background(), "123", model.TaskFilter{LabelIDs: []int64{1, 3}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTodo_DeleteMovesSubtasksUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tasks SET parent_id=\\(SELECT parent_id FROM tasks WHERE id=\\$1 and created_by=\\$2\\) WHERE parent_id=\\$1 and created_by=\\$2").
+		WithArgs(1, "user1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE tasks SET deleted_at=\\$3 WHERE id=\\$1 and created_by=\\$2 and deleted_at IS NULL").
+		WithArgs(1, "user1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := todoList.Delete(context.Background(), 1, "user1", model.DeleteOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestTodo_SetParent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+	parent := 3
+
+	// task 3 is a subtask of task 1
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\) FROM tasks WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(3, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id"}).AddRow(2))
+	mock.ExpectQuery("WITH RECURSIVE ancestors (.+) SELECT EXISTS").
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if _, err := todoList.SetParent(context.Background(), 1, &parent, "user1"); err != ErrTaskCycle {
+		t.Errorf("SetParent() under its own subtask = %v, want %v", err, ErrTaskCycle)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(list_id, 0\\) FROM tasks WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(3, "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"list_id"}))
+	mock.ExpectRollback()
+
+	if _, err := todoList.SetParent(context.Background(), 1, &parent, "user1"); err != ErrParentNotFound {
+		t.Errorf("SetParent() under another user's task = %v, want %v", err, ErrParentNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
+
+// timeArg matches a time argument at the same instant
+type timeArg time.Time
+
+func (a timeArg) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Equal(time.Time(a))
+}
+
+func TestTodo_MarkCompleteRecurring(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+
+	// daylight saving time starts on 2023-03-26 in Berlin, the task stays due at 09:00
+	due := time.Date(2023, 3, 25, 9, 0, 0, 0, berlin)
+	next := time.Date(2023, 3, 26, 9, 0, 0, 0, berlin)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks WHERE id=\\$1 and created_by=\\$2 and deleted_at IS NULL FOR UPDATE").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("FREQ=DAILY;COUNT=3", due.UTC(), 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id=d.blocker_id").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO task_completions \\(task_id, occurrence, due_at, completed_at, skipped\\)").
+		WithArgs(1, 1, timeArg(due), sqlmock.AnyArg(), false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT timezone FROM users WHERE id::text=\\$1").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Berlin"))
+	mock.ExpectExec("UPDATE tasks SET due_at=\\$1, occurrence=occurrence\\+1, status='todo', completed_at=NULL, modified_at=\\$2 WHERE id=\\$3").
+		WithArgs(timeArg(next), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Water the plants", "", "7", 2, "a0", nil, "{}", "{}", false, model.StatusTodo, model.PriorityNone, next, "FREQ=DAILY;COUNT=3", 2, nil, due, due, nil, nil))
+
+	task, err := todoList.MarkComplete(context.Background(), 1, "7", model.CompleteOptions{})
+	if err != nil {
+		t.Fatalf("MarkComplete returned an error: %v", err)
+	}
+	if task.Complete || task.Occurrence != 2 {
+		t.Errorf("MarkComplete() = %+v, want the second occurrence to do", task)
+	}
+
+	// the third occurrence is the last one of the series
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("FREQ=DAILY;COUNT=3", next.UTC(), 3))
+	mock.ExpectExec("INSERT INTO task_completions").
+		WithArgs(1, 3, timeArg(next), sqlmock.AnyArg(), true).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectQuery("SELECT timezone FROM users").
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"timezone"}).AddRow("Europe/Berlin"))
+	mock.ExpectExec("UPDATE tasks SET status='cancelled', completed_at=NULL, recurrence=NULL, modified_at=\\$3 WHERE id=\\$1 and created_by=\\$2").
+		WithArgs(1, "7", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Water the plants", "", "7", 2, "a0", nil, "{}", "{}", false, model.StatusCancelled, model.PriorityNone, next, "", 3, nil, due, due, nil, nil))
+
+	if _, err := todoList.MarkComplete(context.Background(), 1, "7", model.CompleteOptions{Skip: true}); err != nil {
+		t.Fatalf("MarkComplete returned an error: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(recurrence, ''\\), due_at, occurrence FROM tasks").
+		WithArgs(2, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "due_at", "occurrence"}).AddRow("", nil, 1))
+	mock.ExpectRollback()
+
+	if _, err := todoList.MarkComplete(context.Background(), 2, "7", model.CompleteOptions{EndSeries: true}); err != ErrNotRecurring {
+		t.Errorf("MarkComplete() ending the series of a task without recurrence = %v, want %v", err, ErrNotRecurring)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unfulfilled expectations: %v", err)
+	}
+}
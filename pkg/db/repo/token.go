@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// RefreshTokens handles all of the refresh token database actions
+type RefreshTokens struct {
+	DB *sql.DB
+}
+
+// CreateRefreshToken will insert a refresh token into the database
+func (t *RefreshTokens) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) (*model.RefreshToken, error) {
+	if token == nil {
+		return nil, errors.New("refresh token can not be nil")
+	}
+	now := time.Now()
+	var lastInsertId int64
+	statement := "INSERT INTO refresh_tokens (token_hash, family_id, user_id, provider, expires_at, created_at) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id"
+	err := t.DB.QueryRow(statement, token.TokenHash, token.FamilyID, token.UserID, token.Provider, token.ExpiresAt, now).Scan(&lastInsertId)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = int(lastInsertId)
+	token.CreatedAt = now
+
+	return token, nil
+}
+
+// FetchRefreshToken returns a refresh token by the hash of its value
+func (t *RefreshTokens) FetchRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	token := &model.RefreshToken{}
+	statement := "SELECT id, token_hash, family_id, user_id, provider, expires_at, used_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash=$1"
+	err := t.DB.QueryRow(statement, tokenHash).Scan(&token.ID, &token.TokenHash, &token.FamilyID, &token.UserID,
+		&token.Provider, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// UseRefreshToken marks a refresh token as used, it returns false when the
+// token was already used or revoked
+func (t *RefreshTokens) UseRefreshToken(ctx context.Context, id int) (bool, error) {
+	statement := "UPDATE refresh_tokens SET used_at=$1 WHERE id=$2 and used_at IS NULL and revoked_at IS NULL"
+	res, err := t.DB.Exec(statement, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token issued for the same login
+func (t *RefreshTokens) RevokeRefreshTokenFamily(ctx context.Context, familyId string) error {
+	statement := "UPDATE refresh_tokens SET revoked_at=$1 WHERE family_id=$2 and revoked_at IS NULL"
+	_, err := t.DB.Exec(statement, time.Now(), familyId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeRefreshTokensByUser revokes every refresh token of a user
+func (t *RefreshTokens) RevokeRefreshTokensByUser(ctx context.Context, userId string) error {
+	statement := "UPDATE refresh_tokens SET revoked_at=$1 WHERE user_id=$2 and revoked_at IS NULL"
+	_, err := t.DB.Exec(statement, time.Now(), userId)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Revocations handles the token deny-list database actions
+type Revocations struct {
+	DB *sql.DB
+}
+
+// RevokeToken adds a single token to the deny-list until it expires
+func (t *Revocations) RevokeToken(ctx context.Context, jti string, userId string, expiresAt time.Time) error {
+	statement := "INSERT INTO token_revocations (jti, user_id, revoked_at, expires_at) VALUES ($1,$2,$3,$4) ON CONFLICT (jti) DO NOTHING"
+	_, err := t.DB.Exec(statement, jti, userId, time.Now(), expiresAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// RevokeUser denies every token of a user issued up to now, until expiresAt
+func (t *Revocations) RevokeUser(ctx context.Context, userId string, expiresAt time.Time) error {
+	statement := "INSERT INTO token_revocations (user_id, revoked_at, expires_at) VALUES ($1,$2,$3)"
+	_, err := t.DB.Exec(statement, userId, time.Now(), expiresAt)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsRevoked checks if the token jti, or every token of userId issued at issuedAt, was revoked.
+// The revocations of a user deny the tokens issued up to and including revoked_at.
+func (t *Revocations) IsRevoked(ctx context.Context, jti string, userId string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	statement := "SELECT EXISTS (SELECT 1 FROM token_revocations WHERE jti=$1 OR (jti IS NULL and user_id=$2 and revoked_at >= $3))"
+	err := t.DB.QueryRow(statement, jti, userId, issuedAt).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// PruneRevocations deletes the deny-list entries of tokens that expired anyway
+func (t *Revocations) PruneRevocations(ctx context.Context) (int64, error) {
+	statement := "DELETE FROM token_revocations WHERE expires_at < $1"
+	res, err := t.DB.Exec(statement, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
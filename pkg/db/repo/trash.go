@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// Trash returns the tasks of the user in the trash, the last deleted first
+func (t *TodoList) Trash(ctx context.Context, userId string) ([]model.Task, error) {
+	statement := selectTask + " WHERE created_by=$1 and deleted_at IS NOT NULL ORDER BY deleted_at DESC, id"
+	rows, err := t.DB.Query(statement, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []model.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, nil
+}
+
+// Restore takes a task out of the trash together with the subtasks which went to the trash with it.
+// The task keeps its position, it becomes a top level task when its parent is still in the trash.
+func (t *TodoList) Restore(ctx context.Context, id int, userId string) (*model.Task, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	statement := "SELECT deleted_at FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NOT NULL FOR UPDATE"
+	err = tx.QueryRow(statement, id, userId).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	statement = `WITH RECURSIVE restored AS (
+		SELECT id FROM tasks WHERE id=$1
+		UNION SELECT t.id FROM tasks t JOIN restored r ON t.parent_id=r.id WHERE t.deleted_at=$2
+	) UPDATE tasks SET deleted_at=NULL, modified_at=$3 WHERE id IN (SELECT id FROM restored)`
+	if _, err := tx.Exec(statement, id, deletedAt, time.Now()); err != nil {
+		return nil, err
+	}
+	statement = "UPDATE tasks SET parent_id=NULL WHERE id=$1 and parent_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL)"
+	if _, err := tx.Exec(statement, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t.FetchByID(ctx, id)
+}
+
+// Purge permanently deletes a task in the trash, the subtasks which went to the trash with it are deleted by cascade
+func (t *TodoList) Purge(ctx context.Context, id int, userId string) error {
+	statement := "DELETE FROM tasks WHERE id=$1 and created_by=$2 and deleted_at IS NOT NULL"
+	res, err := t.DB.Exec(statement, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// EmptyTrash permanently deletes every task of the user in the trash and returns how many were deleted
+func (t *TodoList) EmptyTrash(ctx context.Context, userId string) (int64, error) {
+	statement := "DELETE FROM tasks WHERE created_by=$1 and deleted_at IS NOT NULL"
+	res, err := t.DB.Exec(statement, userId)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeTrash permanently deletes the tasks of every user which went to the trash before before
+func (t *TodoList) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	statement := "DELETE FROM tasks WHERE deleted_at < $1"
+	res, err := t.DB.Exec(statement, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
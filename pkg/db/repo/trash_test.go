@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+func TestTodo_Restore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	// task 2 is not in the trash
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at FROM tasks WHERE id=\\$1 and created_by=\\$2 and deleted_at IS NOT NULL FOR UPDATE").
+		WithArgs(2, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}))
+	mock.ExpectRollback()
+	if _, err := todoList.Restore(context.Background(), 2, "7"); err == nil || err.Error() != "No record found" {
+		t.Errorf("Restore() of a task outside the trash = %v, want No record found", err)
+	}
+
+	// task 1 comes back with the subtasks deleted with it
+	now := time.Now()
+	deleted := now.Add(-time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at FROM tasks").
+		WithArgs(1, "7").
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deleted))
+	mock.ExpectExec("WITH RECURSIVE restored (.+) UPDATE tasks SET deleted_at=NULL, modified_at=\\$3 WHERE id IN \\(SELECT id FROM restored\\)").
+		WithArgs(1, deleted, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE tasks SET parent_id=NULL WHERE id=\\$1 and parent_id IN \\(SELECT id FROM tasks WHERE deleted_at IS NOT NULL\\)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM tasks WHERE id=\\$1 and deleted_at IS NULL").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskColumns).
+			AddRow(1, "Task 1", "", "7", 2, "a0", nil, "{}", "{}", false, model.StatusTodo, model.PriorityNone, nil, "", 1, nil, now, now, nil, nil))
+	task, err := todoList.Restore(context.Background(), 1, "7")
+	if err != nil {
+		t.Fatalf("Restore returned an error: %v", err)
+	}
+	if task.DeletedAt != nil {
+		t.Errorf("expected the restored task out of the trash, deleted at %v", task.DeletedAt)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTodo_Purge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	todoList := &TodoList{DB: db}
+
+	mock.ExpectExec("DELETE FROM tasks WHERE id=\\$1 and created_by=\\$2 and deleted_at IS NOT NULL").
+		WithArgs(1, "7").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := todoList.Purge(context.Background(), 1, "7"); err == nil || err.Error() != "No record found" {
+		t.Errorf("Purge() of a task outside the trash = %v, want No record found", err)
+	}
+
+	// the tasks deleted before the retention are purged
+	before := time.Now().Add(-30 * 24 * time.Hour)
+	mock.ExpectExec("DELETE FROM tasks WHERE deleted_at < \\$1").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	purged, err := todoList.PurgeTrash(context.Background(), before)
+	if err != nil || purged != 4 {
+		t.Errorf("PurgeTrash() = %d, %v, want 4 purged", purged, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// ErrTOTPConfirmed is returned when replacing the secret of a confirmed authenticator
+var ErrTOTPConfirmed = errors.New("two-factor authentication is already enabled")
+
+// TwoFactors handles all of the TOTP and recovery code database actions
+type TwoFactors struct {
+	DB *sql.DB
+}
+
+// FetchTOTP returns the TOTP factor of a user, or nil when the user never enrolled
+func (t *TwoFactors) FetchTOTP(ctx context.Context, userId int) (*model.TOTPFactor, error) {
+	factor := &model.TOTPFactor{}
+	statement := "SELECT user_id, secret, confirmed_at, last_used_step, failed_attempts, locked_until, created_at FROM totp_factors WHERE user_id=$1"
+	err := t.DB.QueryRow(statement, userId).Scan(&factor.UserID, &factor.Secret, &factor.ConfirmedAt,
+		&factor.LastUsedStep, &factor.FailedAttempts, &factor.LockedUntil, &factor.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return factor, nil
+}
+
+// SaveTOTP stores a new unconfirmed secret for a user, replacing an earlier unconfirmed one
+func (t *TwoFactors) SaveTOTP(ctx context.Context, userId int, secret string) error {
+	statement := `INSERT INTO totp_factors (user_id, secret, created_at) VALUES ($1,$2,$3)
+		ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, created_at=EXCLUDED.created_at,
+		last_used_step=0, failed_attempts=0, locked_until=NULL WHERE totp_factors.confirmed_at IS NULL`
+	res, err := t.DB.Exec(statement, userId, secret, time.Now())
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrTOTPConfirmed
+	}
+	return nil
+}
+
+// ConfirmTOTP enables the TOTP factor of a user after the first valid code of time step
+func (t *TwoFactors) ConfirmTOTP(ctx context.Context, userId int, step int64) error {
+	statement := "UPDATE totp_factors SET confirmed_at=$1, last_used_step=$2, failed_attempts=0 WHERE user_id=$3 and confirmed_at IS NULL"
+	res, err := t.DB.Exec(statement, time.Now(), step, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UseTOTPStep records an accepted code of time step, it returns false when a code of
+// this or a later step was already used
+func (t *TwoFactors) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	statement := "UPDATE totp_factors SET last_used_step=$1, failed_attempts=0, locked_until=NULL WHERE user_id=$2 and last_used_step < $1"
+	res, err := t.DB.Exec(statement, step, userId)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// SetTOTPAttempts stores the number of failed codes in a row and until when the factor is locked
+func (t *TwoFactors) SetTOTPAttempts(ctx context.Context, userId int, attempts int, lockedUntil *time.Time) error {
+	statement := "UPDATE totp_factors SET failed_attempts=$1, locked_until=$2 WHERE user_id=$3"
+	_, err := t.DB.Exec(statement, attempts, lockedUntil, userId)
+	return err
+}
+
+// DeleteTOTP removes the TOTP factor and the recovery codes of a user
+func (t *TwoFactors) DeleteTOTP(ctx context.Context, userId int) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement := "DELETE FROM recovery_codes WHERE user_id=$1"
+	if _, err := tx.Exec(statement, userId); err != nil {
+		return err
+	}
+	statement = "DELETE FROM totp_factors WHERE user_id=$1"
+	res, err := tx.Exec(statement, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces every recovery code of a user by the given hashes
+func (t *TwoFactors) ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement := "DELETE FROM recovery_codes WHERE user_id=$1"
+	if _, err := tx.Exec(statement, userId); err != nil {
+		return err
+	}
+	now := time.Now()
+	statement = "INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1,$2,$3)"
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(statement, userId, hash, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks a recovery code of a user as used, it returns false for unknown or used codes
+func (t *TwoFactors) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	statement := "UPDATE recovery_codes SET used_at=$1 WHERE user_id=$2 and code_hash=$3 and used_at IS NULL"
+	res, err := t.DB.Exec(statement, time.Now(), userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,301 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+var (
+	// ErrLastIdentity is returned when removing the only login of a user
+	ErrLastIdentity = errors.New("can not remove the last login of a user")
+	// ErrIdentityLinked is returned when linking a provider identity which already belongs to another user
+	ErrIdentityLinked = errors.New("this login already belongs to another account")
+)
+
+// selectUser selects the columns read by scanUser
+const selectUser = "SELECT id, COALESCE(email, ''), role, disabled_at, timezone, created_at, modified_at FROM users"
+
+// Users handles all of the user and identity database actions
+type Users struct {
+	DB *sql.DB
+}
+
+// FindOrCreateUser returns the user of a provider identity, the user is created on the first login
+func (u *Users) FindOrCreateUser(ctx context.Context, identity *model.Identity) (*model.User, error) {
+	if identity == nil {
+		return nil, errors.New("identity can not be nil")
+	}
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userId int
+	statement := "SELECT user_id FROM identities WHERE provider=$1 and provider_user_id=$2"
+	err = tx.QueryRow(statement, identity.Provider, identity.ProviderUserID).Scan(&userId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if err == sql.ErrNoRows {
+		now := time.Now()
+		statement = "INSERT INTO users (email, created_at, modified_at) VALUES ($1,$2,$3) RETURNING id"
+		err = tx.QueryRow(statement, nullString(identity.Email), now, now).Scan(&userId)
+		if err != nil {
+			return nil, err
+		}
+		if err := insertIdentity(tx, userId, identity); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return u.FetchUser(ctx, userId)
+}
+
+// LinkIdentity attaches a provider identity to userId. An identity which already belongs to
+// another user is not moved, ErrIdentityLinked is returned instead.
+func (u *Users) LinkIdentity(ctx context.Context, userId int, identity *model.Identity) error {
+	if identity == nil {
+		return errors.New("identity can not be nil")
+	}
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerId int
+	statement := "SELECT user_id FROM identities WHERE provider=$1 and provider_user_id=$2"
+	err = tx.QueryRow(statement, identity.Provider, identity.ProviderUserID).Scan(&ownerId)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := insertIdentity(tx, userId, identity); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case ownerId != userId:
+		return ErrIdentityLinked
+	}
+
+	return tx.Commit()
+}
+
+// FetchUser returns a user by the id
+func (u *Users) FetchUser(ctx context.Context, id int) (*model.User, error) {
+	statement := selectUser + " WHERE id=$1"
+	user, err := scanUser(u.DB.QueryRow(statement, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListUsers returns every user ordered by id
+func (u *Users) ListUsers(ctx context.Context) ([]model.User, error) {
+	statement := selectUser + " ORDER BY id"
+	rows, err := u.DB.Query(statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []model.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+// SetUserRole changes the role of a user
+func (u *Users) SetUserRole(ctx context.Context, id int, role string) error {
+	statement := "UPDATE users SET role=$1, modified_at=$2 WHERE id=$3"
+	res, err := u.DB.Exec(statement, role, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetUserDisabled disables or enables a user, a disabled user can not login
+func (u *Users) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	var disabledAt *time.Time
+	now := time.Now()
+	if disabled {
+		disabledAt = &now
+	}
+	statement := "UPDATE users SET disabled_at=$1, modified_at=$2 WHERE id=$3"
+	res, err := u.DB.Exec(statement, disabledAt, now, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetUserTimezone changes the timezone of a user
+func (u *Users) SetUserTimezone(ctx context.Context, id int, timezone string) error {
+	statement := "UPDATE users SET timezone=$1, modified_at=$2 WHERE id=$3"
+	res, err := u.DB.Exec(statement, timezone, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListIdentities returns the provider identities linked to a user
+func (u *Users) ListIdentities(ctx context.Context, userId int) ([]model.Identity, error) {
+	statement := "SELECT id, user_id, provider, provider_user_id, COALESCE(email, ''), created_at FROM identities WHERE user_id=$1 ORDER BY id"
+	rows, err := u.DB.Query(statement, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []model.Identity{}
+	for rows.Next() {
+		var i model.Identity
+		err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.ProviderUserID, &i.Email, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	return identities, nil
+}
+
+// DeleteIdentity unlinks a provider identity from a user, the last identity can not be removed
+func (u *Users) DeleteIdentity(ctx context.Context, id int, userId int) error {
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	statement := "SELECT count(*) FROM identities WHERE user_id=$1"
+	if err := tx.QueryRow(statement, userId).Scan(&count); err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastIdentity
+	}
+
+	statement = "DELETE FROM identities WHERE id=$1 and user_id=$2"
+	res, err := tx.Exec(statement, id, userId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// DeleteUser removes a user with its tasks, lists, labels, logins and tokens
+func (u *Users) DeleteUser(ctx context.Context, id int) error {
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// tasks, lists, labels and refresh tokens refer to the user by its id as text, without a foreign key
+	statements := []string{
+		"DELETE FROM tasks WHERE created_by=$1",
+		"DELETE FROM lists WHERE created_by=$1",
+		"DELETE FROM labels WHERE created_by=$1",
+		"DELETE FROM refresh_tokens WHERE user_id=$1",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, strconv.Itoa(id)); err != nil {
+			return err
+		}
+	}
+
+	// identities, accounts, personal access tokens and the second factor are removed by cascade
+	statement := "DELETE FROM users WHERE id=$1"
+	res, err := tx.Exec(statement, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// insertIdentity links identity to userId. Tasks created before users existed were owned by the raw
+// provider user id, they are handed over to the user with their lists. The migration marked them
+// legacy:<provider>: when their provider was known from the refresh tokens and legacy:: otherwise.
+// Tasks without a provider go to the first login with the id, unless another provider has a user
+// with the same id.
+func insertIdentity(tx *sql.Tx, userId int, identity *model.Identity) error {
+	statement := "INSERT INTO identities (user_id, provider, provider_user_id, email, created_at) VALUES ($1,$2,$3,$4,$5)"
+	_, err := tx.Exec(statement, userId, identity.Provider, identity.ProviderUserID, nullString(identity.Email), time.Now())
+	if err != nil {
+		return err
+	}
+
+	legacyOwners := []string{"legacy:" + identity.Provider + ":" + identity.ProviderUserID}
+	var shared bool
+	statement = "SELECT EXISTS (SELECT 1 FROM identities WHERE provider_user_id=$1 and provider<>$2)"
+	if err := tx.QueryRow(statement, identity.ProviderUserID, identity.Provider).Scan(&shared); err != nil {
+		return err
+	}
+	if !shared {
+		legacyOwners = append(legacyOwners, "legacy::"+identity.ProviderUserID)
+	}
+
+	for _, legacyOwner := range legacyOwners {
+		statement = "UPDATE tasks SET created_by=$1 WHERE created_by=$2"
+		if _, err := tx.Exec(statement, strconv.Itoa(userId), legacyOwner); err != nil {
+			return err
+		}
+		if err := mergeLists(tx, strconv.Itoa(userId), legacyOwner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanUser(row rowScanner) (*model.User, error) {
+	user := &model.User{}
+	err := row.Scan(&user.ID, &user.Email, &user.Role, &user.DisabledAt, &user.Timezone, &user.CreatedAt, &user.ModifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,224 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// expectHandover expects the legacy tasks of the provider user to be handed over to userId,
+// the tasks of a baseline upgrade are claimed when no other provider has a user with the id
+func expectHandover(mock sqlmock.Sqlmock, userId string, provider string, providerUserId string, shared bool) {
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM identities WHERE provider_user_id=\\$1 and provider<>\\$2\\)").
+		WithArgs(providerUserId, provider).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(shared))
+	legacyOwners := []string{"legacy:" + provider + ":" + providerUserId}
+	if !shared {
+		legacyOwners = append(legacyOwners, "legacy::"+providerUserId)
+	}
+	for _, legacyOwner := range legacyOwners {
+		mock.ExpectExec("UPDATE tasks SET created_by=\\$1 WHERE created_by=\\$2").
+			WithArgs(userId, legacyOwner).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		expectMergeLists(mock, userId, legacyOwner)
+	}
+}
+
+func TestUsers_FindOrCreateUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	users := &Users{DB: db}
+	identity := &model.Identity{Provider: "github", ProviderUserID: "583231", Email: "octocat@github.com"}
+	now := time.Now()
+
+	// first login creates the user, links the identity and hands over the legacy tasks
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities WHERE provider=\\$1 and provider_user_id=\\$2").
+		WithArgs("github", "583231").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery("^INSERT INTO users").
+		WithArgs("octocat@github.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("^INSERT INTO identities").
+		WithArgs(7, "github", "583231", "octocat@github.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHandover(mock, "7", "github", "583231", false)
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id, COALESCE\\(email, ''\\), role, disabled_at, timezone, created_at, modified_at FROM users WHERE id=\\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "role", "disabled_at", "timezone", "created_at", "modified_at"}).AddRow(7, "octocat@github.com", "user", nil, "UTC", now, now))
+
+	user, err := users.FindOrCreateUser(context.Background(), identity)
+	if err != nil {
+		t.Fatalf("FindOrCreateUser returned an error: %v", err)
+	}
+	if user.ID != 7 {
+		t.Errorf("expected user 7, got %d", user.ID)
+	}
+
+	// the next login finds the same user
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities WHERE provider=\\$1 and provider_user_id=\\$2").
+		WithArgs("github", "583231").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id, COALESCE\\(email, ''\\), role, disabled_at, timezone, created_at, modified_at FROM users WHERE id=\\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "role", "disabled_at", "timezone", "created_at", "modified_at"}).AddRow(7, "octocat@github.com", "user", nil, "UTC", now, now))
+
+	user, err = users.FindOrCreateUser(context.Background(), identity)
+	if err != nil {
+		t.Fatalf("FindOrCreateUser returned an error: %v", err)
+	}
+	if user.ID != 7 {
+		t.Errorf("expected user 7, got %d", user.ID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUsers_LinkIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	users := &Users{DB: db}
+	identity := &model.Identity{Provider: "google", ProviderUserID: "1053015"}
+
+	// the google identity already has its own user 9, it is not taken over
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities WHERE provider=\\$1 and provider_user_id=\\$2").
+		WithArgs("google", "1053015").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(9))
+	mock.ExpectRollback()
+	if err := users.LinkIdentity(context.Background(), 7, identity); err != ErrIdentityLinked {
+		t.Errorf("LinkIdentity() of another user's identity = %v, want %v", err, ErrIdentityLinked)
+	}
+
+	// a new identity is linked to user 7
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities WHERE provider=\\$1 and provider_user_id=\\$2").
+		WithArgs("google", "1053015").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectExec("^INSERT INTO identities").
+		WithArgs(7, "google", "1053015", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHandover(mock, "7", "google", "1053015", false)
+	mock.ExpectCommit()
+	if err := users.LinkIdentity(context.Background(), 7, identity); err != nil {
+		t.Fatalf("LinkIdentity returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUsers_FindOrCreateUserBaselineTasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	users := &Users{DB: db}
+	identity := &model.Identity{Provider: "fb", ProviderUserID: "583231"}
+	now := time.Now()
+
+	// a github user already has the id 583231, the tasks marked legacy::583231 by a baseline upgrade
+	// could be its tasks, so the facebook user only gets the tasks marked with facebook
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id FROM identities WHERE provider=\\$1 and provider_user_id=\\$2").
+		WithArgs("fb", "583231").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectQuery("^INSERT INTO users").
+		WithArgs(nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec("^INSERT INTO identities").
+		WithArgs(8, "fb", "583231", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectHandover(mock, "8", "fb", "583231", true)
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT id, COALESCE\\(email, ''\\), role, disabled_at, timezone, created_at, modified_at FROM users WHERE id=\\$1").
+		WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "role", "disabled_at", "timezone", "created_at", "modified_at"}).AddRow(8, "", "user", nil, "UTC", now, now))
+
+	if _, err := users.FindOrCreateUser(context.Background(), identity); err != nil {
+		t.Fatalf("FindOrCreateUser returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUsers_DeleteIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	users := &Users{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM identities WHERE user_id=\\$1").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	if err := users.DeleteIdentity(context.Background(), 1, 7); err != ErrLastIdentity {
+		t.Errorf("DeleteIdentity() = %v, want %v", err, ErrLastIdentity)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUsers_DeleteUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock db: %v", err)
+	}
+	defer db.Close()
+
+	users := &Users{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM tasks WHERE created_by=\\$1").
+		WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM lists WHERE created_by=\\$1").
+		WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM labels WHERE created_by=\\$1").
+		WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM refresh_tokens WHERE user_id=\\$1").
+		WithArgs("7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM users WHERE id=\\$1").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := users.DeleteUser(context.Background(), 7); err != nil {
+		t.Errorf("DeleteUser returned an error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+// Archiver archives the tasks which were completed for too long
+type Archiver interface {
+	ArchiveCompletedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AutoArchive returns the job archiving the tasks which were completed more than conf.AfterDays days ago
+func AutoArchive(archiver Archiver, conf *config.ArchiveConfig) Job {
+	return Job{
+		Name:     "archive completed tasks",
+		Interval: conf.Interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			archived, err := archiver.ArchiveCompletedBefore(ctx, now.AddDate(0, 0, -conf.AfterDays))
+			if err != nil {
+				return err
+			}
+			if archived > 0 {
+				log.Printf("Archived %d completed tasks", archived)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a task of the server which runs in the background every Interval
+type Job struct {
+	// Name says what the job does in the logs, ie purge the trash
+	Name     string
+	Interval time.Duration
+	// Run does the work which was due at now
+	Run func(ctx context.Context, now time.Time) error
+}
+
+// Start runs job in the background until ctx is done. The first run is at once, so the work which
+// was due while the server was down is done at startup.
+func Start(ctx context.Context, job Job) {
+	go func() {
+		ticker := time.NewTicker(job.Interval)
+		defer ticker.Stop()
+		for {
+			if err := job.Run(ctx, time.Now()); err != nil {
+				log.Printf("Failed to %s: %v", job.Name, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+type mockTrashPurger struct {
+	before []time.Time
+}
+
+func (m *mockTrashPurger) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	m.before = append(m.before, before)
+	return 1, nil
+}
+
+type mockArchiver struct {
+	before []time.Time
+}
+
+func (m *mockArchiver) ArchiveCompletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.before = append(m.before, before)
+	return 2, nil
+}
+
+type mockReminderSender struct {
+	batches []int
+}
+
+func (m *mockReminderSender) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	sent := m.batches[0]
+	m.batches = m.batches[1:]
+	return sent, nil
+}
+
+type mockRevocationPruner struct {
+	runs int
+}
+
+func (m *mockRevocationPruner) PruneRevocations(ctx context.Context) (int64, error) {
+	m.runs++
+	return 3, nil
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan time.Time, 1)
+	Start(ctx, Job{
+		Name:     "count",
+		Interval: time.Hour,
+		Run: func(ctx context.Context, now time.Time) error {
+			runs <- now
+			return nil
+		},
+	})
+
+	// the first run does not wait for the interval
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("Start() did not run the job at once")
+	}
+	cancel()
+}
+
+func TestPurgeTrash(t *testing.T) {
+	purger := &mockTrashPurger{}
+	job := PurgeTrash(purger, &config.TrashConfig{Retention: 24 * time.Hour, Interval: time.Minute})
+	if job.Interval != time.Minute {
+		t.Errorf("PurgeTrash() interval = %v, want %v", job.Interval, time.Minute)
+	}
+
+	now := time.Date(2023, 6, 5, 9, 0, 0, 0, time.UTC)
+	if err := job.Run(context.Background(), now); err != nil {
+		t.Fatalf("PurgeTrash() run failed: %v", err)
+	}
+	if len(purger.before) != 1 || !purger.before[0].Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("PurgeTrash() purged before %v, want %v", purger.before, now.Add(-24*time.Hour))
+	}
+}
+
+func TestAutoArchive(t *testing.T) {
+	archiver := &mockArchiver{}
+	job := AutoArchive(archiver, &config.ArchiveConfig{AfterDays: 7, Interval: time.Hour})
+
+	now := time.Date(2023, 6, 5, 9, 0, 0, 0, time.UTC)
+	if err := job.Run(context.Background(), now); err != nil {
+		t.Fatalf("AutoArchive() run failed: %v", err)
+	}
+	want := time.Date(2023, 5, 29, 9, 0, 0, 0, time.UTC)
+	if len(archiver.before) != 1 || !archiver.before[0].Equal(want) {
+		t.Errorf("AutoArchive() archived before %v, want %v", archiver.before, want)
+	}
+}
+
+func TestSendReminders(t *testing.T) {
+	// two full batches mean there may be more, the third one is not full
+	sender := &mockReminderSender{batches: []int{10, 10, 4, 10}}
+	job := SendReminders(sender, &config.ReminderConfig{Interval: time.Minute, BatchSize: 10})
+	if err := job.Run(context.Background(), time.Now()); err != nil {
+		t.Fatalf("SendReminders() run failed: %v", err)
+	}
+	if len(sender.batches) != 1 {
+		t.Errorf("SendReminders() sent %d batches, want 3", 4-len(sender.batches))
+	}
+}
+
+func TestPruneRevocations(t *testing.T) {
+	pruner := &mockRevocationPruner{}
+	job := PruneRevocations(pruner, time.Hour)
+	if err := job.Run(context.Background(), time.Now()); err != nil {
+		t.Fatalf("PruneRevocations() run failed: %v", err)
+	}
+	if pruner.runs != 1 {
+		t.Errorf("PruneRevocations() pruned %d times, want 1", pruner.runs)
+	}
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+// ReminderSender sends a batch of the reminders due at now and returns how many were claimed
+type ReminderSender interface {
+	RunOnce(ctx context.Context, now time.Time) (int, error)
+}
+
+// SendReminders returns the job sending the due reminders. A full batch means there are more due
+// reminders, so batches are sent until one is not full.
+func SendReminders(sender ReminderSender, conf *config.ReminderConfig) Job {
+	return Job{
+		Name:     "send reminders",
+		Interval: conf.Interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			for {
+				sent, err := sender.RunOnce(ctx, now)
+				if err != nil || sent < conf.BatchSize {
+					return err
+				}
+				now = time.Now()
+			}
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RevocationPruner deletes the deny-list entries of tokens which expired anyway
+type RevocationPruner interface {
+	PruneRevocations(ctx context.Context) (int64, error)
+}
+
+// PruneRevocations returns the job deleting the expired deny-list entries every interval
+func PruneRevocations(pruner RevocationPruner, interval time.Duration) Job {
+	return Job{
+		Name:     "prune token revocations",
+		Interval: interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			pruned, err := pruner.PruneRevocations(ctx)
+			if err != nil {
+				return err
+			}
+			if pruned > 0 {
+				log.Printf("Pruned %d expired token revocations", pruned)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+// TrashPurger permanently deletes the tasks which are in the trash for too long
+type TrashPurger interface {
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+}
+
+// PurgeTrash returns the job deleting the tasks which are in the trash for longer than the retention
+func PurgeTrash(purger TrashPurger, conf *config.TrashConfig) Job {
+	return Job{
+		Name:     "purge the trash",
+		Interval: conf.Interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			purged, err := purger.PurgeTrash(ctx, now.Add(-conf.Retention))
+			if err != nil {
+				return err
+			}
+			if purged > 0 {
+				log.Printf("Purged %d tasks from the trash", purged)
+			}
+			return nil
+		},
+	}
+}
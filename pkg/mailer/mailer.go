@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New returns a SMTP mailer, or a Log mailer when no SMTP host is configured
+func New(conf *config.MailerConfig) Mailer {
+	if conf == nil || conf.Host == "" {
+		return &Log{}
+	}
+	return NewSMTP(conf)
+}
+
+// SMTP sends email through a SMTP server, STARTTLS is used when the server supports it
+type SMTP struct {
+	conf *config.MailerConfig
+}
+
+// NewSMTP returns a mailer sending through the SMTP server of conf
+func NewSMTP(conf *config.MailerConfig) *SMTP {
+	return &SMTP{conf: conf}
+}
+
+// Send delivers msg to the SMTP server
+func (s *SMTP) Send(ctx context.Context, msg *Message) error {
+	data, err := format(s.conf.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.conf.Username != "" {
+		auth = smtp.PlainAuth("", s.conf.Username, s.conf.Password, s.conf.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(s.conf.Host, s.conf.Port), auth, s.conf.From, []string{msg.To}, data)
+}
+
+// format builds the RFC 5322 message sent to the SMTP server
+func format(from string, msg *Message) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("email headers can not contain line breaks")
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
+
+// Log writes email to the log instead of sending it, it is meant for local development
+type Log struct{}
+
+// Send logs msg
+func (l *Log) Send(ctx context.Context, msg *Message) error {
+	log.Printf("Email to %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// Memory keeps the sent email in memory, it stands in for a real mailer in tests
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send records msg
+func (m *Memory) Send(ctx context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, *msg)
+	return nil
+}
+
+// Messages returns the email sent so far
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
+
+// Last returns the latest email sent to the address to
+func (m *Memory) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
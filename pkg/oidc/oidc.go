@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/oauth2"
+)
+
+// reservedNames can not be used as provider names, they are taken by other routes.
+// Keep it in sync with the first path segments of the routes in main.
+var reservedNames = map[string]bool{
+	"google": true, "fb": true, "github": true, "local": true, "auth": true, "todolist": true,
+	"lists": true, "labels": true, "trash": true, "reminders": true, "admin": true, ".well-known": true,
+}
+
+// validName limits provider names to what can be used in a url path
+var validName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Provider is an OpenID Connect provider set up from its discovery document
+type Provider struct {
+	Name         string
+	Issuer       string
+	Config       *oauth2.Config
+	SupportsPKCE bool
+
+	jwksURI string
+	client  *http.Client
+
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+// IDTokenClaims are the claims of a verified ID token
+type IDTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type discoveryDocument struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]*Provider{}
+)
+
+// Discover sets up a provider from {issuer}/.well-known/openid-configuration
+func Discover(ctx context.Context, conf *config.OIDCConfig) (*Provider, error) {
+	if !validName.MatchString(conf.Name) {
+		return nil, fmt.Errorf("oidc provider name %s may only contain a-z, 0-9 and -", conf.Name)
+	}
+	if reservedNames[conf.Name] {
+		return nil, fmt.Errorf("oidc provider name %s is reserved", conf.Name)
+	}
+	if conf.Issuer == "" || conf.ClientID == "" {
+		return nil, fmt.Errorf("oidc provider %s needs an issuer and a client id", conf.Name)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	issuer := strings.TrimSuffix(conf.Issuer, "/")
+	doc := &discoveryDocument{}
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", doc); err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider %s: %w", conf.Name, err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("oidc provider %s returned issuer %s, expected %s", conf.Name, doc.Issuer, issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc provider %s has an incomplete discovery document", conf.Name)
+	}
+
+	p := &Provider{
+		Name:   conf.Name,
+		Issuer: doc.Issuer,
+		Config: &oauth2.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			RedirectURL:  conf.RedirectURL,
+			Scopes:       conf.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  doc.AuthorizationEndpoint,
+				TokenURL: doc.TokenEndpoint,
+			},
+		},
+		jwksURI: doc.JWKSURI,
+		client:  client,
+		keys:    map[string]interface{}{},
+	}
+	for _, method := range doc.CodeChallengeMethodsSupported {
+		if method == "S256" {
+			p.SupportsPKCE = true
+		}
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Register makes the provider available to the login and callback handlers
+func Register(p *Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name] = p
+}
+
+// Lookup returns the registered provider by name
+func Lookup(name string) (*Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Providers returns all of the registered providers sorted by name
+func Providers() []*Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := []*Provider{}
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// VerifyIDToken verifies the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*IDTokenClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(p.Issuer, true) {
+		return nil, errors.New("invalid issuer")
+	}
+	if !claims.VerifyAudience(p.Config.ClientID, true) {
+		return nil, errors.New("invalid audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("token has no expiry")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.Config.ClientID {
+		return nil, errors.New("invalid authorized party")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce != "" && tokenNonce != nonce {
+		return nil, errors.New("invalid nonce")
+	}
+
+	idClaims := &IDTokenClaims{}
+	idClaims.Subject, _ = claims["sub"].(string)
+	idClaims.Email, _ = claims["email"].(string)
+	idClaims.EmailVerified, _ = claims["email_verified"].(bool)
+	if idClaims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return idClaims, nil
+}
+
+// key returns the verification key for kid, the key set is fetched again for unknown kids
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	if key, ok := p.cachedKey(kid); ok {
+		return key, nil
+	}
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := p.cachedKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %s", kid)
+}
+
+// cachedKey returns the key for kid, an empty kid is only accepted when there is one key
+func (p *Provider) cachedKey(kid string) (interface{}, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refreshKeys fetches the provider's signing keys from its jwks_uri
+func (p *Provider) refreshKeys(ctx context.Context) error {
+	set := &struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := getJSON(ctx, p.client, p.jwksURI, set); err != nil {
+		return fmt.Errorf("failed to fetch keys of oidc provider %s: %w", p.Name, err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// skip key types we do not support, ie symmetric keys
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+	return nil
+}
+
+// publicKey converts a RSA or EC JWK to a public key
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+// getJSON fetches url and decodes the json response into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,175 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/golang-jwt/jwt"
+)
+
+// newTestIssuer starts a fake OpenID Connect provider serving discovery and keys
+func newTestIssuer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                           server.URL,
+			"authorization_endpoint":           server.URL + "/auth",
+			"token_endpoint":                   server.URL + "/token",
+			"jwks_uri":                         server.URL + "/keys",
+			"code_challenge_methods_supported": []string{"plain", "S256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "key-1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	return server
+}
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key-1"
+	tokenStr, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign id token: %v", err)
+	}
+	return tokenStr
+}
+
+func TestDiscover(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newTestIssuer(t, key)
+
+	p, err := Discover(context.Background(), &config.OIDCConfig{
+		Name:     "keycloak",
+		Issuer:   server.URL,
+		ClientID: "todo-list",
+	})
+	if err != nil {
+		t.Fatalf("failed to discover provider: %v", err)
+	}
+	if p.Config.Endpoint.AuthURL != server.URL+"/auth" || p.Config.Endpoint.TokenURL != server.URL+"/token" {
+		t.Errorf("unexpected endpoints %+v", p.Config.Endpoint)
+	}
+	if !p.SupportsPKCE {
+		t.Errorf("expected S256 PKCE to be supported")
+	}
+
+	for _, name := range []string{"google", "lists", "trash", "admin", ".well-known"} {
+		if _, err := Discover(context.Background(), &config.OIDCConfig{Name: name, Issuer: server.URL, ClientID: "x"}); err == nil {
+			t.Errorf("expected reserved provider name %s to be rejected", name)
+		}
+	}
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := newTestIssuer(t, key)
+
+	p, err := Discover(context.Background(), &config.OIDCConfig{
+		Name:     "dex",
+		Issuer:   server.URL,
+		ClientID: "todo-list",
+	})
+	if err != nil {
+		t.Fatalf("failed to discover provider: %v", err)
+	}
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   server.URL,
+			"aud":   []string{"todo-list"},
+			"sub":   "CgNib2I",
+			"email": "bob@example.com",
+			"nonce": "n-1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"iat":   time.Now().Unix(),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			token: signIDToken(t, key, valid()),
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := valid()
+				claims["aud"] = "another-client"
+				return signIDToken(t, key, claims)
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := valid()
+				claims["iss"] = "https://evil.example.com"
+				return signIDToken(t, key, claims)
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "wrong nonce",
+			token: func() string {
+				claims := valid()
+				claims["nonce"] = "n-2"
+				return signIDToken(t, key, claims)
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := valid()
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return signIDToken(t, key, claims)
+			}(),
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			token:   signIDToken(t, otherKey, valid()),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := p.VerifyIDToken(context.Background(), tt.token, "n-1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyIDToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && (claims.Subject != "CgNib2I" || claims.Email != "bob@example.com") {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
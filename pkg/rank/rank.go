@@ -0,0 +1,213 @@
+// Package rank generates the sort keys of manually ordered tasks. Keys compare as strings byte by byte,
+// a key between two others is always found without changing them, so moving a task only rewrites its own key.
+//
+// A key is an integer part followed by a fraction. The first character of the integer part tells its length,
+// a to z for 2 to 27 characters and Z to A for the negative integers, so appending or prepending a key
+// increments or decrements the integer and keys stay short. A fraction is only added to put a key
+// between two consecutive integers, it never ends with 0.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+// digits are the base 62 digits in byte order
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// smallestInteger is the smallest integer part, nothing can be put before it
+const smallestInteger = "A00000000000000000000000000"
+
+var (
+	// ErrInvalidKey is returned for a key which was not generated by this package
+	ErrInvalidKey = errors.New("invalid rank key")
+	// ErrOrder is returned when the lower key is not before the upper key
+	ErrOrder = errors.New("rank keys are not in order")
+	// ErrExhausted is returned when there is no key before the smallest or after the largest integer
+	ErrExhausted = errors.New("rank keys are exhausted")
+)
+
+// Between returns a key sorting after a and before b. An empty a is the start and an empty b is the end,
+// Between("", "") is the first key.
+func Between(a string, b string) (string, error) {
+	if a != "" {
+		if err := validate(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := validate(b); err != nil {
+			return "", err
+		}
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrOrder
+	}
+
+	switch {
+	case a == "" && b == "":
+		return "a0", nil
+	case a == "":
+		ib := integerPart(b)
+		if ib == smallestInteger {
+			return ib + midpoint("", b[len(ib):]), nil
+		}
+		if ib < b {
+			return ib, nil
+		}
+		return decrement(ib)
+	case b == "":
+		ia := integerPart(a)
+		i, err := increment(ia)
+		if err == ErrExhausted {
+			return ia + midpoint(a[len(ia):], ""), nil
+		}
+		return i, err
+	}
+
+	ia, ib := integerPart(a), integerPart(b)
+	if ia == ib {
+		return ia + midpoint(a[len(ia):], b[len(ib):]), nil
+	}
+	i, err := increment(ia)
+	if err != nil {
+		return "", err
+	}
+	if i < b {
+		return i, nil
+	}
+	return ia + midpoint(a[len(ia):], ""), nil
+}
+
+// midpoint returns a fraction between the fractions a and b, an empty b is 1
+func midpoint(a string, b string) string {
+	if b != "" {
+		// the common prefix is kept
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	da := 0
+	if a != "" {
+		da = strings.IndexByte(digits, a[0])
+	}
+	db := len(digits)
+	if b != "" {
+		db = strings.IndexByte(digits, b[0])
+	}
+	if db-da > 1 {
+		return string(digits[(da+db+1)/2])
+	}
+	// the digits are consecutive
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[da]) + midpoint(rest, "")
+}
+
+// digitAt returns the nth digit of the fraction a, a is padded with zeros
+func digitAt(a string, n int) byte {
+	if n < len(a) {
+		return a[n]
+	}
+	return digits[0]
+}
+
+// integerLength returns the length of the integer part starting with head, 0 when head is invalid
+func integerLength(head byte) int {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2
+	}
+	return 0
+}
+
+func integerPart(key string) string {
+	return key[:integerLength(key[0])]
+}
+
+func validate(key string) error {
+	n := integerLength(key[0])
+	if n == 0 || len(key) < n || key == smallestInteger {
+		return ErrInvalidKey
+	}
+	for i := 1; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return ErrInvalidKey
+		}
+	}
+	if len(key) > n && key[len(key)-1] == digits[0] {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// increment returns the integer after x, the integer part grows by a digit when its digits overflow
+func increment(x string) (string, error) {
+	head, digs := x[0], []byte(x[1:])
+	for i := len(digs) - 1; i >= 0; i-- {
+		d := strings.IndexByte(digits, digs[i]) + 1
+		if d < len(digits) {
+			digs[i] = digits[d]
+			return string(head) + string(digs), nil
+		}
+		digs[i] = digits[0]
+	}
+
+	switch head {
+	case 'Z':
+		return "a0", nil
+	case 'z':
+		return "", ErrExhausted
+	}
+	head++
+	if head > 'a' {
+		digs = append(digs, digits[0])
+	} else {
+		digs = digs[:len(digs)-1]
+	}
+	return string(head) + string(digs), nil
+}
+
+// decrement returns the integer before x, the integer part grows by a digit when its digits underflow
+func decrement(x string) (string, error) {
+	head, digs := x[0], []byte(x[1:])
+	last := digits[len(digits)-1]
+	for i := len(digs) - 1; i >= 0; i-- {
+		d := strings.IndexByte(digits, digs[i]) - 1
+		if d >= 0 {
+			digs[i] = digits[d]
+			return string(head) + string(digs), nil
+		}
+		digs[i] = last
+	}
+
+	switch head {
+	case 'a':
+		return "Z" + string(last), nil
+	case 'A':
+		return "", ErrExhausted
+	}
+	head--
+	if head < 'Z' {
+		digs = append(digs, last)
+	} else {
+		digs = digs[:len(digs)-1]
+	}
+	return string(head) + string(digs), nil
+}
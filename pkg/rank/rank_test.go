@@ -0,0 +1,65 @@
+package rank
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+		err  error
+	}{
+		{a: "", b: "", want: "a0"},
+		{a: "a0", b: "", want: "a1"},
+		{a: "", b: "a0", want: "Zz"},
+		{a: "a0", b: "a1", want: "a0V"},
+		{a: "a1", b: "a2", want: "a1V"},
+		{a: "a0V", b: "a1", want: "a0l"},
+		{a: "az", b: "", want: "b00"},
+		{a: "Zz", b: "", want: "a0"},
+		{a: "", b: "b00", want: "az"},
+		{a: "a0", b: "a0V", want: "a0G"},
+		{a: "a0", b: "a01", want: "a00V"},
+		{a: "a0", b: "b00", want: "a1"},
+		{a: "d0000", b: "d0001", want: "d0000V"},
+		{a: "a1", b: "a0", err: ErrOrder},
+		{a: "a0", b: "a0", err: ErrOrder},
+		{a: "a00", b: "", err: ErrInvalidKey},
+		{a: "a", b: "", err: ErrInvalidKey},
+		{a: "", b: "a-", err: ErrInvalidKey},
+		{a: "", b: "1", err: ErrInvalidKey},
+	}
+	for _, test := range tests {
+		got, err := Between(test.a, test.b)
+		if !errors.Is(err, test.err) || got != test.want {
+			t.Errorf("Between(%q, %q) = %q, %v, want %q, %v", test.a, test.b, got, err, test.want, test.err)
+		}
+	}
+}
+
+func TestBetween_Repeated(t *testing.T) {
+	// appending keeps the keys short
+	key := ""
+	for i := 0; i < 10000; i++ {
+		next, err := Between(key, "")
+		if err != nil || next <= key {
+			t.Fatalf("Between(%q, \"\") = %q, %v", key, next, err)
+		}
+		key = next
+	}
+	if len(key) > 4 {
+		t.Errorf("key after 10000 appends is %q", key)
+	}
+
+	// inserting before the same key keeps the order
+	low, high := "a0", "a1"
+	for i := 0; i < 100; i++ {
+		key, err := Between(low, high)
+		if err != nil || key <= low || key >= high {
+			t.Fatalf("Between(%q, %q) = %q, %v", low, high, key, err)
+		}
+		high = key
+	}
+}
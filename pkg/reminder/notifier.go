@@ -0,0 +1,134 @@
+// Package reminder sends the reminders of tasks, a Scheduler claims the due reminders from the database
+// and sends each one through the Notifier of its channel.
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/mailer"
+)
+
+// webhookTimeout is how long the webhook receiver has to answer
+const webhookTimeout = 10 * time.Second
+
+var (
+	// ErrNoEmail is returned when a reminder is sent by email to a user without an email address
+	ErrNoEmail = errors.New("user has no email address")
+	// ErrNoNotifier is returned for a reminder of a channel which is not configured anymore
+	ErrNoNotifier = errors.New("no notifier for the channel")
+)
+
+// Notification is what a reminder tells its user
+type Notification struct {
+	ReminderID int        `json:"reminder_id"`
+	TaskID     int        `json:"task_id"`
+	TaskName   string     `json:"task_name"`
+	DueAt      *time.Time `json:"due_at"`
+	UserID     string     `json:"user_id"`
+	Email      string     `json:"-"`
+	// Location is the timezone of the user, the due date is shown in it
+	Location *time.Location `json:"-"`
+}
+
+// Notifier sends notifications
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Email sends the notification to the email address of the user
+type Email struct {
+	Mailer mailer.Mailer
+}
+
+// Notify emails n
+func (e *Email) Notify(ctx context.Context, n *Notification) error {
+	if n.Email == "" {
+		return ErrNoEmail
+	}
+	msg := &mailer.Message{
+		To:      n.Email,
+		Subject: "Reminder: " + n.TaskName,
+		Body:    fmt.Sprintf("This is a reminder of your task %q.\n", n.TaskName),
+	}
+	if n.DueAt != nil {
+		msg.Body += fmt.Sprintf("It is due %s.\n", dueDate(n))
+	}
+	return e.Mailer.Send(ctx, msg)
+}
+
+// Webhook posts the notification as json to URL, the body is signed with Secret when it is set
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// NewWebhook returns a webhook notifier posting to url
+func NewWebhook(url string, secret string) *Webhook {
+	return &Webhook{URL: url, Secret: secret, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Notify posts n, any status but 2xx is an error
+func (wh *Webhook) Notify(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Secret != "" {
+		req.Header.Set("X-Todo-Signature", "sha256="+Sign(wh.Secret, body))
+	}
+
+	resp, err := wh.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body, receivers compare it with the X-Todo-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Log writes the notification to the log, it is meant for local development
+type Log struct{}
+
+// Notify logs n
+func (l *Log) Notify(ctx context.Context, n *Notification) error {
+	if n.DueAt != nil {
+		log.Printf("Reminder %d for user %s: task %q is due %s", n.ReminderID, n.UserID, n.TaskName, dueDate(n))
+		return nil
+	}
+	log.Printf("Reminder %d for user %s: task %q", n.ReminderID, n.UserID, n.TaskName)
+	return nil
+}
+
+// dueDate formats the due date in the timezone of the user
+func dueDate(n *Notification) string {
+	loc := n.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return n.DueAt.In(loc).Format("Mon, 02 Jan 2006 15:04 MST")
+}
@@ -0,0 +1,158 @@
+package reminder
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/mailer"
+)
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP is a local SMTP server keeping the messages it receives
+type fakeSMTP struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeSMTP{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost fake smtp")
+
+	msg := smtpMessage{}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			tp.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			tp.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			tp.PrintfLine("250 OK")
+		case command == "DATA":
+			tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = smtpMessage{}
+			tp.PrintfLine("250 OK")
+		case command == "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTP) Messages() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage{}, s.messages...)
+}
+
+func TestEmail_Notify(t *testing.T) {
+	server := startFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	notifier := &Email{Mailer: mailer.NewSMTP(&config.MailerConfig{Host: host, Port: port, From: "todo-list@localhost"})}
+
+	due := time.Date(2023, 6, 5, 7, 0, 0, 0, time.UTC)
+	n := &Notification{ReminderID: 1, TaskID: 2, TaskName: "pay the rent", DueAt: &due, UserID: "7", Email: "octocat@github.com", Location: time.FixedZone("CEST", 2*60*60)}
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() returned an error: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("the smtp server received %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != "todo-list@localhost" || len(msg.To) != 1 || msg.To[0] != "octocat@github.com" {
+		t.Errorf("message from %s to %v", msg.From, msg.To)
+	}
+	for _, want := range []string{"Subject: Reminder: pay the rent", `"pay the rent"`, "Mon, 05 Jun 2023 09:00 CEST"} {
+		if !strings.Contains(msg.Data, want) {
+			t.Errorf("message %q does not contain %q", msg.Data, want)
+		}
+	}
+
+	n.Email = ""
+	if err := notifier.Notify(context.Background(), n); err != ErrNoEmail {
+		t.Errorf("Notify() without email = %v, want %v", err, ErrNoEmail)
+	}
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	status := http.StatusNoContent
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Todo-Signature"), "sha256="+Sign("secret", body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		json.Unmarshal(body, &received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhook(server.URL, "secret")
+	n := &Notification{ReminderID: 1, TaskID: 2, TaskName: "pay the rent", UserID: "7", Email: "octocat@github.com"}
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() returned an error: %v", err)
+	}
+	if received.TaskID != 2 || received.TaskName != "pay the rent" || received.Email != "" {
+		t.Errorf("the webhook received %+v", received)
+	}
+
+	status = http.StatusInternalServerError
+	if err := notifier.Notify(context.Background(), n); err == nil {
+		t.Errorf("Notify() should fail when the webhook answers %d", status)
+	}
+}
@@ -0,0 +1,112 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+// lease is how long a claimed reminder is locked while it is sent, a crashed scheduler releases it this way
+const lease = 5 * time.Minute
+
+// maxRetryDelay caps the delay between two attempts of a failed reminder
+const maxRetryDelay = time.Hour
+
+// Store keeps the reminders and the state of their delivery
+type Store interface {
+	ClaimReminders(ctx context.Context, now time.Time, lockedUntil time.Time, limit int) ([]model.DueReminder, error)
+	MarkReminderFired(ctx context.Context, id int, occurrence int, firedAt time.Time, lastError string) error
+	MarkReminderFailed(ctx context.Context, id int, retryAt time.Time, lastError string) error
+}
+
+// Scheduler sends the due reminders through the notifier of their channel, it runs as a background job
+type Scheduler struct {
+	store     Store
+	notifiers map[string]Notifier
+	conf      config.ReminderConfig
+}
+
+// NewScheduler returns a scheduler sending the reminders of store, notifiers maps a channel to its notifier
+func NewScheduler(store Store, notifiers map[string]Notifier, conf *config.ReminderConfig) *Scheduler {
+	return &Scheduler{store: store, notifiers: notifiers, conf: *conf}
+}
+
+// Channels returns the channels with a notifier, reminders can only be created for them
+func (s *Scheduler) Channels() []string {
+	channels := []string{}
+	for channel := range s.notifiers {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// RunOnce sends a batch of the reminders due at now and returns how many were claimed
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	reminders, err := s.store.ClaimReminders(ctx, now, now.Add(lease), s.conf.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i := range reminders {
+		if err := s.send(ctx, &reminders[i], now); err != nil {
+			log.Printf("Failed to record reminder %d: %v", reminders[i].ID, err)
+		}
+	}
+	return len(reminders), nil
+}
+
+// send notifies the user of a reminder and records the delivery, a failed reminder is retried
+// with a growing delay until it reaches the maximum number of attempts
+func (s *Scheduler) send(ctx context.Context, r *model.DueReminder, now time.Time) error {
+	var err error
+	if notifier, ok := s.notifiers[r.Channel]; ok {
+		err = notifier.Notify(ctx, notification(r))
+	} else {
+		err = fmt.Errorf("%w %s", ErrNoNotifier, r.Channel)
+	}
+	if err == nil {
+		return s.store.MarkReminderFired(ctx, r.ID, r.Occurrence, now, "")
+	}
+
+	attempts := r.Attempts + 1
+	log.Printf("Reminder %d failed on attempt %d: %v", r.ID, attempts, err)
+	// retrying does not help without an email address or a notifier
+	if errors.Is(err, ErrNoEmail) || errors.Is(err, ErrNoNotifier) || attempts >= s.conf.MaxAttempts {
+		return s.store.MarkReminderFired(ctx, r.ID, r.Occurrence, now, err.Error())
+	}
+	return s.store.MarkReminderFailed(ctx, r.ID, now.Add(retryDelay(attempts)), err.Error())
+}
+
+// retryDelay doubles the delay after every failed attempt, starting at a minute
+func retryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+func notification(r *model.DueReminder) *Notification {
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return &Notification{
+		ReminderID: r.ID,
+		TaskID:     r.TaskID,
+		TaskName:   r.TaskName,
+		DueAt:      r.DueAt,
+		UserID:     r.UserID,
+		Email:      r.Email,
+		Location:   loc,
+	}
+}
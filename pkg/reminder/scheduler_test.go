@@ -0,0 +1,89 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/db/model"
+)
+
+type mockStore struct {
+	due    []model.DueReminder
+	fired  map[int]string
+	failed map[int]time.Time
+}
+
+func (m *mockStore) ClaimReminders(ctx context.Context, now time.Time, lockedUntil time.Time, limit int) ([]model.DueReminder, error) {
+	due := m.due
+	m.due = nil
+	return due, nil
+}
+
+func (m *mockStore) MarkReminderFired(ctx context.Context, id int, occurrence int, firedAt time.Time, lastError string) error {
+	m.fired[id] = lastError
+	return nil
+}
+
+func (m *mockStore) MarkReminderFailed(ctx context.Context, id int, retryAt time.Time, lastError string) error {
+	m.failed[id] = retryAt
+	return nil
+}
+
+type mockNotifier struct {
+	err  error
+	sent []int
+}
+
+func (m *mockNotifier) Notify(ctx context.Context, n *Notification) error {
+	m.sent = append(m.sent, n.ReminderID)
+	return m.err
+}
+
+func TestScheduler_RunOnce(t *testing.T) {
+	store := &mockStore{
+		due: []model.DueReminder{
+			{Reminder: model.Reminder{ID: 1, Channel: model.ChannelLog}},
+			{Reminder: model.Reminder{ID: 2, Channel: model.ChannelWebhook}},
+			{Reminder: model.Reminder{ID: 3, Channel: model.ChannelWebhook, Attempts: 2}},
+			{Reminder: model.Reminder{ID: 4, Channel: model.ChannelEmail}},
+		},
+		fired:  map[int]string{},
+		failed: map[int]time.Time{},
+	}
+	log := &mockNotifier{}
+	webhook := &mockNotifier{err: errors.New("connection refused")}
+	scheduler := NewScheduler(store, map[string]Notifier{model.ChannelLog: log, model.ChannelWebhook: webhook}, &config.ReminderConfig{BatchSize: 10, MaxAttempts: 3})
+
+	if got := scheduler.Channels(); !reflect.DeepEqual(got, []string{model.ChannelLog, model.ChannelWebhook}) {
+		t.Errorf("Channels() = %v", got)
+	}
+
+	now := time.Now()
+	claimed, err := scheduler.RunOnce(context.Background(), now)
+	if err != nil || claimed != 4 {
+		t.Fatalf("RunOnce() = %v, %v, want 4 claimed", claimed, err)
+	}
+
+	// 1 was sent, 3 failed for the last time and 4 has no notifier
+	want := map[int]string{1: "", 3: "connection refused", 4: "no notifier for the channel email"}
+	if !reflect.DeepEqual(store.fired, want) {
+		t.Errorf("fired reminders = %v, want %v", store.fired, want)
+	}
+	// 2 is retried after its first failure
+	if retryAt, ok := store.failed[2]; !ok || !retryAt.Equal(now.Add(time.Minute)) || len(store.failed) != 1 {
+		t.Errorf("failed reminders = %v, want 2 retried in a minute", store.failed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 10: time.Hour}
+	for attempts, want := range tests {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Package rrule evaluates the recurrence rules of RFC 5545 (section 3.3.10) used by recurring tasks.
+// It supports the DAILY, WEEKLY, MONTHLY and YEARLY frequencies with INTERVAL, COUNT, UNTIL, BYDAY,
+// BYMONTHDAY, BYMONTH and WKST, ie FREQ=MONTHLY;BYDAY=-1FR for the last friday of every month.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// searchDays is how far Next looks for the next occurrence, it covers a leap day with a yearly interval of 25
+const searchDays = 100 * 366
+
+// ErrInvalidRule is wrapped by every parse error
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Weekday is a BYDAY value, N is the nth weekday of the month or the year, 0 for every weekday
+// and negative values count from the end ie -1FR is the last friday
+type Weekday struct {
+	N   int
+	Day time.Weekday
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count is the number of occurrences of the series including the first one, 0 when unlimited
+	Count int
+	// Until is the last time an occurrence can have, zero when unlimited
+	Until      time.Time
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// Parse parses a rule like FREQ=WEEKLY;INTERVAL=2;BYDAY=MO, the RRULE: prefix is optional.
+// A date or a local time of UNTIL is read in loc.
+func Parse(s string, loc *time.Location) (*Rule, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q is not a NAME=VALUE pair", ErrInvalidRule, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s is repeated", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("the frequency %s is not supported", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positive(value)
+		case "COUNT":
+			rule.Count, err = positive(value)
+		case "UNTIL":
+			rule.Until, err = parseUntil(value, loc)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseByMonthDay(value)
+		case "BYMONTH":
+			rule.ByMonth, err = parseByMonth(value)
+		case "WKST":
+			day, ok := weekdays[value]
+			if !ok {
+				err = fmt.Errorf("%s is not a weekday", value)
+			}
+			rule.WeekStart = day
+		default:
+			err = fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL can not be used together", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, fmt.Errorf("%w: BYDAY can only have a number with a monthly or yearly frequency", ErrInvalidRule)
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == Weekly {
+		return nil, fmt.Errorf("%w: BYMONTHDAY can not be used with a weekly frequency", ErrInvalidRule)
+	}
+	return rule, nil
+}
+
+// Next returns the first occurrence after prev, prev is an occurrence of the series and n is the number of
+// occurrences up to and including prev. The occurrence keeps the wall clock time of prev in its location,
+// so a daily task due at 09:00 stays due at 09:00 across daylight saving changes.
+// It returns false when the series has ended.
+func (r *Rule) Next(prev time.Time, n int) (time.Time, bool) {
+	if r.Count > 0 && n >= r.Count {
+		return time.Time{}, false
+	}
+
+	year, month, day := prev.Date()
+	hour, min, sec := prev.Clock()
+	for i := 1; i <= searchDays; i++ {
+		next := time.Date(year, month, day+i, hour, min, sec, prev.Nanosecond(), prev.Location())
+		if !r.Until.IsZero() && next.After(r.Until) {
+			return time.Time{}, false
+		}
+		if r.inPeriod(prev, next) && r.matches(prev, next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// inPeriod reports whether t is in a period of the interval counted from the period of prev
+func (r *Rule) inPeriod(prev time.Time, t time.Time) bool {
+	var periods int
+	switch r.Freq {
+	case Daily:
+		periods = days(prev, t)
+	case Weekly:
+		periods = days(r.weekStart(prev), r.weekStart(t)) / 7
+	case Monthly:
+		periods = (t.Year()-prev.Year())*12 + int(t.Month()) - int(prev.Month())
+	case Yearly:
+		periods = t.Year() - prev.Year()
+	}
+	return periods%r.Interval == 0
+}
+
+// matches reports whether the day of t is selected by the BY rules, without them the day of prev is repeated
+func (r *Rule) matches(prev time.Time, t time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, t.Month()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(t) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !r.matchesDay(t) {
+		return false
+	}
+	if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+		return true
+	}
+
+	switch r.Freq {
+	case Weekly:
+		return t.Weekday() == prev.Weekday()
+	case Monthly:
+		return t.Day() == prev.Day()
+	case Yearly:
+		if len(r.ByMonth) == 0 && t.Month() != prev.Month() {
+			return false
+		}
+		return t.Day() == prev.Day()
+	}
+	return true
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	last := daysIn(t.Year(), t.Month())
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || day < 0 && last+day+1 == t.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesDay(t time.Time) bool {
+	for _, day := range r.ByDay {
+		if day.Day != t.Weekday() {
+			continue
+		}
+		if day.N == 0 {
+			return true
+		}
+
+		// the nth weekday is counted in the month, or in the year for a yearly rule without BYMONTH
+		position, length := t.Day(), daysIn(t.Year(), t.Month())
+		if r.Freq == Yearly && len(r.ByMonth) == 0 {
+			position, length = t.YearDay(), time.Date(t.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		}
+		if day.N > 0 && (position-1)/7+1 == day.N {
+			return true
+		}
+		if day.N < 0 && (length-position)/7+1 == -day.N {
+			return true
+		}
+	}
+	return false
+}
+
+// weekStart returns the first day of the week of t
+func (r *Rule) weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) - int(r.WeekStart) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// days returns the number of calendar days from a to b
+func days(a time.Time, b time.Time) int {
+	from := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func positive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s is not a positive number", value)
+	}
+	return n, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	if len(value) == len("20060102") {
+		// the whole day is included
+		until, err := time.ParseInLocation("20060102", value, loc)
+		return until.AddDate(0, 0, 1).Add(-time.Nanosecond), err
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	days := []Weekday{}
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("%s is not a weekday", item)
+		}
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a weekday", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("%s is not a weekday", item)
+			}
+		}
+		days = append(days, Weekday{N: n, Day: day})
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	monthDays := []int{}
+	for _, item := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(item)
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return nil, fmt.Errorf("%s is not a day of the month", item)
+		}
+		monthDays = append(monthDays, day)
+	}
+	return monthDays, nil
+}
+
+func parseByMonth(value string) ([]time.Month, error) {
+	months := []time.Month{}
+	for _, item := range strings.Split(value, ",") {
+		month, err := strconv.Atoi(item)
+		if err != nil || month < 1 || month > 12 {
+			return nil, fmt.Errorf("%s is not a month", item)
+		}
+		months = append(months, time.Month(month))
+	}
+	return months, nil
+}
@@ -0,0 +1,158 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRule_Next(t *testing.T) {
+	utc := time.UTC
+	tests := []struct {
+		name  string
+		rule  string
+		prev  time.Time
+		n     int
+		want  []time.Time
+		ended bool
+	}{
+		{
+			name: "daily",
+			rule: "FREQ=DAILY",
+			prev: time.Date(2023, 5, 30, 9, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2023, 5, 31, 9, 0, 0, 0, utc), time.Date(2023, 6, 1, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "weekdays",
+			rule: "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			prev: time.Date(2023, 6, 1, 9, 0, 0, 0, utc), // thursday
+			want: []time.Time{time.Date(2023, 6, 2, 9, 0, 0, 0, utc), time.Date(2023, 6, 5, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "every other monday",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+			prev: time.Date(2023, 6, 5, 9, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2023, 6, 19, 9, 0, 0, 0, utc), time.Date(2023, 7, 3, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "second monday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=2MO",
+			prev: time.Date(2023, 6, 12, 9, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2023, 7, 10, 9, 0, 0, 0, utc), time.Date(2023, 8, 14, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "last friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR",
+			prev: time.Date(2023, 6, 30, 17, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2023, 7, 28, 17, 0, 0, 0, utc), time.Date(2023, 8, 25, 17, 0, 0, 0, utc)},
+		},
+		{
+			name: "monthly on the 31st skips shorter months",
+			rule: "FREQ=MONTHLY",
+			prev: time.Date(2023, 5, 31, 9, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2023, 7, 31, 9, 0, 0, 0, utc), time.Date(2023, 8, 31, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "last day of the month",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			prev: time.Date(2024, 1, 31, 9, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2024, 2, 29, 9, 0, 0, 0, utc), time.Date(2024, 3, 31, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "yearly on a leap day",
+			rule: "FREQ=YEARLY",
+			prev: time.Date(2024, 2, 29, 9, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2028, 2, 29, 9, 0, 0, 0, utc)},
+		},
+		{
+			name: "thanksgiving",
+			rule: "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH",
+			prev: time.Date(2023, 11, 23, 12, 0, 0, 0, utc),
+			want: []time.Time{time.Date(2024, 11, 28, 12, 0, 0, 0, utc)},
+		},
+		{
+			name:  "count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			prev:  time.Date(2023, 6, 1, 9, 0, 0, 0, utc),
+			n:     2,
+			want:  []time.Time{time.Date(2023, 6, 2, 9, 0, 0, 0, utc)},
+			ended: true,
+		},
+		{
+			name:  "until",
+			rule:  "FREQ=WEEKLY;UNTIL=20230615",
+			prev:  time.Date(2023, 6, 1, 9, 0, 0, 0, utc),
+			want:  []time.Time{time.Date(2023, 6, 8, 9, 0, 0, 0, utc), time.Date(2023, 6, 15, 9, 0, 0, 0, utc)},
+			ended: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule, utc)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			prev, n := tt.prev, tt.n
+			if n == 0 {
+				n = 1
+			}
+			for _, want := range tt.want {
+				next, ok := rule.Next(prev, n)
+				if !ok || !next.Equal(want) {
+					t.Fatalf("Next(%v) = %v, %v, want %v", prev, next, ok, want)
+				}
+				prev, n = next, n+1
+			}
+			if _, ok := rule.Next(prev, n); ok == tt.ended {
+				t.Errorf("Next(%v) after the last expected occurrence ok = %v, want %v", prev, ok, !tt.ended)
+			}
+		})
+	}
+}
+
+func TestRule_NextKeepsTheWallClock(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	rule, _ := Parse("FREQ=DAILY", loc)
+
+	// daylight saving time starts on 2023-03-12 in New York
+	prev := time.Date(2023, 3, 11, 9, 0, 0, 0, loc)
+	next, _ := rule.Next(prev, 1)
+	if next.Hour() != 9 || next.Day() != 12 {
+		t.Errorf("Next() = %v, want 9:00 on the 12th", next)
+	}
+	if next.Sub(prev) != 23*time.Hour {
+		t.Errorf("Next() is %v after prev, want 23h", next.Sub(prev))
+	}
+}
+
+func TestParse(t *testing.T) {
+	invalid := []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20230601",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYSETPOS=-1",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	}
+	for _, rule := range invalid {
+		if _, err := Parse(rule, time.UTC); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want %v", rule, err, ErrInvalidRule)
+		}
+	}
+
+	rule, err := Parse("freq=monthly;interval=3;byday=mo,-1fr;wkst=su", time.UTC)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if rule.Freq != Monthly || rule.Interval != 3 || rule.WeekStart != time.Sunday ||
+		len(rule.ByDay) != 2 || rule.ByDay[1] != (Weekday{N: -1, Day: time.Friday}) {
+		t.Errorf("Parse() = %+v", rule)
+	}
+}
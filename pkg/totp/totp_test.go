@@ -0,0 +1,72 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcKey is the SHA1 key of the RFC 6238 appendix B test vectors
+var rfcKey = []byte("12345678901234567890")
+
+func TestHOTP_RFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		if got := hotp(rfcKey, uint64(tt.unix/Period), 8); got != tt.want {
+			t.Errorf("hotp(%d) = %v, want %v", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString(rfcKey)
+	now := time.Unix(1111111109, 0)
+
+	code, err := Code(secret, Step(now))
+	if err != nil {
+		t.Fatalf("failed to create code: %v", err)
+	}
+	if code != "081804" {
+		t.Errorf("Code() = %v, want %v", code, "081804")
+	}
+
+	if step, ok := Validate(secret, code, now, 1); !ok || step != Step(now) {
+		t.Errorf("Validate() = %v, %v, want %v, true", step, ok, Step(now))
+	}
+	// a code of the previous step is accepted for clock drift, older ones are not
+	if _, ok := Validate(secret, code, now.Add(Period*time.Second), 1); !ok {
+		t.Errorf("Validate() of the previous step should pass")
+	}
+	if _, ok := Validate(secret, code, now.Add(2*Period*time.Second), 1); ok {
+		t.Errorf("Validate() two steps later should fail")
+	}
+	if _, ok := Validate(secret, "000000", now, 1); ok {
+		t.Errorf("Validate() with wrong code should fail")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("expected a 32 character secret, got %s", secret)
+	}
+
+	uri := ProvisioningURI("todo-list", "me@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/todo-list:me@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("unexpected provisioning uri %s", uri)
+	}
+}
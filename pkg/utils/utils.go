@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/cfthoo/todo-app/pkg/config"
+	"github.com/cfthoo/todo-app/pkg/oidc"
 	"golang.org/x/oauth2"
 )
 
@@ -43,12 +46,34 @@ func SetOuathConfigByLoginType(loginType string) (*oauth2.Config, string, error)
 		configs = config.SetupGithubConfig()
 		oauthUrl = config.GithubOauthUrl
 	default:
-		return nil, "", errors.New("Unknown login type")
+		// generic OpenID Connect providers registered at startup
+		provider, ok := oidc.Lookup(loginType)
+		if !ok {
+			return nil, "", errors.New("Unknown login type")
+		}
+		configs = provider.Config
 	}
 
 	return configs, oauthUrl, nil
 }
 
+// SupportsPKCE reports whether the loginType accepts PKCE (S256) code challenges
+func SupportsPKCE(loginType string) bool {
+	switch loginType {
+	case "google", "fb":
+		return true
+	default:
+		provider, ok := oidc.Lookup(loginType)
+		return ok && provider.SupportsPKCE
+	}
+}
+
+// PKCEChallenge returns the S256 code challenge of a PKCE code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 // FetchUserData fetches user data based on loginType from different provider ie google/fb/github
 func FetchUserData(loginType string, oauthUrl string, token string) (*http.Response, error) {
 